@@ -1,11 +1,81 @@
 package account
 
 import (
+	"fmt"
+	"os"
+	"strconv"
 	"time"
 
+	"github.com/Jasonasante/bankAPI.git/kyc"
 	"github.com/Jasonasante/bankAPI.git/misc"
 )
 
+// Types of account. Interest rates are set per type. Internal accounts belong to the bank
+// itself and cannot be opened through the API.
+const (
+	TypeCurrent  = "current"
+	TypeSavings  = "savings"
+	TypeInternal = "internal"
+)
+
+// Roles an account can hold. Every account is a customer unless granted another role.
+// Card processors are the integration accounts that place and settle card holds. Tellers
+// are support staff who can reverse transfers. Compliance staff review held transfers.
+// Auditors can read the audit log and nothing else. Integrations are downstream services
+// that subscribe to webhooks.
+const (
+	RoleCustomer      = "customer"
+	RoleAdmin         = "admin"
+	RoleCardProcessor = "card-processor"
+	RoleTeller        = "teller"
+	RoleCompliance    = "compliance"
+	RoleAuditor       = "auditor"
+	RoleIntegration   = "integration"
+)
+
+// Statuses an account moves through. Closed is final: the account is kept for our records
+// but nothing more can be done with it. Accounts whose holder's name matched the sanctions
+// list when they were opened stay in compliance review until compliance decide on the match.
+const (
+	StatusActive           = "active"
+	StatusFrozen           = "frozen"
+	StatusDormant          = "dormant"
+	StatusClosed           = "closed"
+	StatusComplianceReview = "compliance-review"
+)
+
+// Operations that depend on the status of the account. Debits are anything taking money out
+// of the account, credits anything paying money in.
+const (
+	OperationDebit  = "debit"
+	OperationCredit = "credit"
+	OperationLogin  = "login"
+	OperationUpdate = "update"
+)
+
+// allowedOperations is what each status lets the account holder do. Frozen and dormant
+// accounts can still receive money and be looked at, but nothing can leave them. An account
+// in compliance review can only be signed in to.
+var allowedOperations = map[string][]string{
+	StatusActive:           {OperationDebit, OperationCredit, OperationLogin, OperationUpdate},
+	StatusFrozen:           {OperationCredit, OperationLogin},
+	StatusDormant:          {OperationCredit, OperationLogin, OperationUpdate},
+	StatusClosed:           {},
+	StatusComplianceReview: {OperationLogin},
+}
+
+// transitions is the statuses each status can move to. Nothing moves into compliance review
+// except a new account, and it leaves it cleared or, on a true match, frozen.
+var transitions = map[string][]string{
+	StatusActive:           {StatusFrozen, StatusDormant, StatusClosed},
+	StatusFrozen:           {StatusActive, StatusClosed},
+	StatusDormant:          {StatusActive, StatusClosed},
+	StatusClosed:           {},
+	StatusComplianceReview: {StatusActive, StatusFrozen},
+}
+
+const defaultDormantAfterDays = 365
+
 type LoginResponse struct {
 	Username string `json:"username"`
 	Token    string `json:"token"`
@@ -16,12 +86,16 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// CreateAccountRequest opens an account. The identity details in Profile can be given now or
+// later, but the account is limited until they have been verified.
 type CreateAccountRequest struct {
-	FirstName string    `json:"first-name"`
-	LastName  string    `json:"last-name"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created-at"`
+	FirstName   string              `json:"first-name"`
+	LastName    string              `json:"last-name"`
+	Username    string              `json:"username"`
+	Password    string              `json:"password"`
+	AccountType string              `json:"account-type"`
+	CreatedAt   time.Time           `json:"created-at"`
+	Profile     *kyc.ProfileRequest `json:"profile"`
 }
 
 type UpdateAccountRequest struct {
@@ -34,17 +108,43 @@ type UpdateAccountRequest struct {
 }
 
 type Account struct {
-	ID         int       `json:"id"`
-	FirstName  string    `json:"first-name"`
-	LastName   string    `json:"last-name"`
-	Username   string    `json:"username"`
-	Password   string    `json:"password"`
-	BankNumber int64     `json:"bank-number"`
-	Balance    int64     `json:"balance"`
-	CreatedAt  time.Time `json:"created-at"`
+	ID             int       `json:"id"`
+	FirstName      string    `json:"first-name"`
+	LastName       string    `json:"last-name"`
+	Username       string    `json:"username"`
+	Password       string    `json:"password"`
+	BankNumber     int64     `json:"bank-number"`
+	Balance        int64     `json:"balance"`
+	CreatedAt      time.Time `json:"created-at"`
+	Type           string    `json:"account-type"`
+	Role           string    `json:"role"`
+	OverdraftLimit int64     `json:"overdraft-limit"`
+	Status         string    `json:"status"`
+	StatusChanged  time.Time `json:"status-changed-at"`
+}
+
+// StatusRequest moves an account to another status. Closing goes through CloseRequest instead.
+type StatusRequest struct {
+	Status string `json:"status"`
+}
+
+// CloseRequest closes an account. Any money left in it is paid out to the account with the
+// given bank number or username, which is required unless the balance is already zero.
+type CloseRequest struct {
+	PayoutBankNumber int64  `json:"payout-bank-number"`
+	PayoutUsername   string `json:"payout-username"`
+}
+
+type OverdraftRequest struct {
+	OverdraftLimit int64 `json:"overdraft-limit"`
+}
+
+// Available is how much can be taken from the account, including its arranged overdraft.
+func (a *Account) Available() int64 {
+	return a.Balance + a.OverdraftLimit
 }
 
-func CreateAccount(firstName, lastName, username, password string) *Account {
+func CreateAccount(firstName, lastName, username, password, accountType string) *Account {
 	return &Account{
 		FirstName:  firstName,
 		LastName:   lastName,
@@ -53,5 +153,63 @@ func CreateAccount(firstName, lastName, username, password string) *Account {
 		BankNumber: misc.RangeIn(10000000, 99999999),
 		Balance:    0,
 		CreatedAt:  time.Now().UTC(),
+		Type:       misc.DefaultValue(accountType, TypeCurrent),
+		Role:       RoleCustomer,
+		Status:     StatusActive,
+	}
+}
+
+// Allows reports whether the account's status lets operation go ahead.
+func (a *Account) Allows(operation string) error {
+	for _, allowed := range allowedOperations[a.Status] {
+		if allowed == operation {
+			return nil
+		}
+	}
+	if a.Status == StatusClosed {
+		return fmt.Errorf("account %v is closed", a.BankNumber)
+	}
+	return fmt.Errorf("account %v is %s : %s is not allowed", a.BankNumber, a.Status, operation)
+}
+
+func ValidateStatus(status string) error {
+	if _, ok := transitions[status]; !ok {
+		return fmt.Errorf("invalid status %q : must be one of %s, %s, %s, %s or %s", status, StatusActive, StatusFrozen, StatusDormant, StatusClosed, StatusComplianceReview)
+	}
+	return nil
+}
+
+func ValidateTransition(from, to string) error {
+	for _, next := range transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change a %s account to %s", from, to)
+}
+
+// DormantAfterDays is how long an account can go without any activity before it becomes
+// dormant, read from the dormantAfterDays environment variable.
+func DormantAfterDays() int {
+	days, err := strconv.Atoi(os.Getenv("dormantAfterDays"))
+	if err != nil || days < 1 {
+		return defaultDormantAfterDays
+	}
+	return days
+}
+
+func ValidateRole(role string) error {
+	switch role {
+	case RoleCustomer, RoleAdmin, RoleCardProcessor, RoleTeller, RoleCompliance, RoleAuditor, RoleIntegration:
+		return nil
+	}
+	return fmt.Errorf("invalid role %q", role)
+}
+
+func ValidateType(accountType string) error {
+	switch accountType {
+	case "", TypeCurrent, TypeSavings:
+		return nil
 	}
+	return fmt.Errorf("invalid account type %q : must be %s or %s", accountType, TypeCurrent, TypeSavings)
 }
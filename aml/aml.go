@@ -0,0 +1,238 @@
+package aml
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+// Patterns the monitoring job raises alerts for. A large cash alert covers one cash deposit
+// at or above the threshold. A structuring alert covers a run of cash deposits just under it.
+const (
+	RuleLargeCash   = "large-cash"
+	RuleStructuring = "structuring"
+)
+
+// Statuses an alert moves through. Closed is final.
+const (
+	StatusOpen          = "open"
+	StatusInvestigating = "investigating"
+	StatusClosed        = "closed"
+)
+
+// Dispositions an alert can be closed with. Only a sar-filed alert has been reported.
+const (
+	DispositionSARFiled      = "sar-filed"
+	DispositionNoAction      = "no-action"
+	DispositionFalsePositive = "false-positive"
+)
+
+const (
+	defaultCashThreshold     = 1000000
+	defaultStructuringMargin = 0.1
+	defaultStructuringCount  = 3
+	defaultStructuringDays   = 7
+)
+
+// transitions is the statuses each status can move to.
+var transitions = map[string][]string{
+	StatusOpen:          {StatusInvestigating, StatusClosed},
+	StatusInvestigating: {StatusOpen, StatusClosed},
+	StatusClosed:        {},
+}
+
+// Alert is a pattern of activity on an account that compliance need to look at. Amount is
+// the total of the transfers it covers.
+type Alert struct {
+	ID          int       `json:"id"`
+	AccountID   int       `json:"account-id"`
+	Rule        string    `json:"rule"`
+	Amount      int       `json:"amount"`
+	Details     string    `json:"details"`
+	TransferIDs []int     `json:"transfer-ids"`
+	Status      string    `json:"status"`
+	Disposition string    `json:"disposition"`
+	AssigneeID  int       `json:"assignee-id"`
+	Comment     string    `json:"comment"`
+	CreatedAt   time.Time `json:"created-at"`
+	UpdatedAt   time.Time `json:"updated-at"`
+}
+
+// UpdateAlertRequest moves an alert on. Closing it needs a disposition and a comment.
+type UpdateAlertRequest struct {
+	Status      string `json:"status"`
+	Disposition string `json:"disposition"`
+	Comment     string `json:"comment"`
+}
+
+// Report is what a suspicious activity report is written from.
+type Report struct {
+	Alert       *Alert
+	Subject     *account.Account
+	Transfers   []*transfer.Transfer
+	GeneratedAt time.Time
+}
+
+func CreateAlert(accountID int, rule, details string, transfers []*transfer.Transfer, now time.Time) *Alert {
+	alert := &Alert{
+		AccountID:   accountID,
+		Rule:        rule,
+		Details:     details,
+		TransferIDs: []int{},
+		Status:      StatusOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, t := range transfers {
+		alert.Amount += t.Amount
+		alert.TransferIDs = append(alert.TransferIDs, t.ID)
+	}
+	return alert
+}
+
+// LargeCash raises an alert for each of deposits at or above threshold.
+func LargeCash(deposits []*transfer.Transfer, threshold int, now time.Time) []*Alert {
+	alerts := []*Alert{}
+	for _, deposit := range deposits {
+		if deposit.Amount < threshold {
+			continue
+		}
+		details := fmt.Sprintf("cash deposit of %v at or above the threshold of %v", deposit.Amount, threshold)
+		alerts = append(alerts, CreateAlert(deposit.To, RuleLargeCash, details, []*transfer.Transfer{deposit}, now))
+	}
+	return alerts
+}
+
+// Structuring raises an alert for each account with at least StructuringCount of deposits
+// falling within StructuringMargin under threshold. Deposits should already be limited to the
+// last StructuringDays days.
+func Structuring(deposits []*transfer.Transfer, threshold int, now time.Time) []*Alert {
+	floor := int(float64(threshold) * (1 - StructuringMargin()))
+	byAccount := map[int][]*transfer.Transfer{}
+	accounts := []int{}
+	for _, deposit := range deposits {
+		if deposit.Amount < floor || deposit.Amount >= threshold {
+			continue
+		}
+		if _, ok := byAccount[deposit.To]; !ok {
+			accounts = append(accounts, deposit.To)
+		}
+		byAccount[deposit.To] = append(byAccount[deposit.To], deposit)
+	}
+	alerts := []*Alert{}
+	for _, id := range accounts {
+		run := byAccount[id]
+		if len(run) < StructuringCount() {
+			continue
+		}
+		details := fmt.Sprintf("%d cash deposits between %v and %v within %d days", len(run), floor, threshold, StructuringDays())
+		alerts = append(alerts, CreateAlert(id, RuleStructuring, details, run, now))
+	}
+	return alerts
+}
+
+// ValidateUpdate checks request can be applied to alert.
+func ValidateUpdate(alert *Alert, request *UpdateAlertRequest) error {
+	if _, ok := transitions[request.Status]; !ok {
+		return fmt.Errorf("invalid status %q : must be %s, %s or %s", request.Status, StatusOpen, StatusInvestigating, StatusClosed)
+	}
+	allowed := false
+	for _, next := range transitions[alert.Status] {
+		allowed = allowed || next == request.Status
+	}
+	if !allowed {
+		return fmt.Errorf("cannot move a %s alert to %s", alert.Status, request.Status)
+	}
+	if request.Status != StatusClosed {
+		if request.Disposition != "" {
+			return fmt.Errorf("a disposition is only given when closing an alert")
+		}
+		return nil
+	}
+	switch request.Disposition {
+	case DispositionSARFiled, DispositionNoAction, DispositionFalsePositive:
+	default:
+		return fmt.Errorf("invalid disposition %q : must be %s, %s or %s", request.Disposition, DispositionSARFiled, DispositionNoAction, DispositionFalsePositive)
+	}
+	if request.Comment == "" {
+		return fmt.Errorf("comment is required")
+	}
+	return nil
+}
+
+// WriteSAR writes a suspicious activity report for the alert in r as plain text.
+func WriteSAR(w io.Writer, r *Report) error {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+	line("SUSPICIOUS ACTIVITY REPORT")
+	line("Generated:        %s", r.GeneratedAt.Format(time.RFC3339))
+	line("Alert:            %d", r.Alert.ID)
+	line("Rule:             %s", r.Alert.Rule)
+	line("Raised:           %s", r.Alert.CreatedAt.Format(time.RFC3339))
+	line("Status:           %s", r.Alert.Status)
+	line("Disposition:      %s", r.Alert.Disposition)
+	line("")
+	line("SUBJECT")
+	line("Name:             %s %s", r.Subject.FirstName, r.Subject.LastName)
+	line("Username:         %s", r.Subject.Username)
+	line("Bank number:      %d", r.Subject.BankNumber)
+	line("Account type:     %s", r.Subject.Type)
+	line("Account opened:   %s", r.Subject.CreatedAt.Format(time.RFC3339))
+	line("Account status:   %s", r.Subject.Status)
+	line("")
+	line("ACTIVITY")
+	line("Summary:          %s", r.Alert.Details)
+	line("Total amount:     %d", r.Alert.Amount)
+	for _, t := range r.Transfers {
+		line("%-8d %s  %-10s %-14s %12d  %s", t.ID, t.CompletedAt.Format(time.RFC3339), t.Action, t.Channel, t.Amount, t.Reference)
+	}
+	line("")
+	line("NARRATIVE")
+	line("%s", r.Alert.Comment)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CashThreshold is the cash deposit amount that must be reported, read from the
+// amlCashThreshold environment variable.
+func CashThreshold() int {
+	return envInt("amlCashThreshold", defaultCashThreshold)
+}
+
+// StructuringMargin is how far under the threshold, as a share of it, a deposit can be and
+// still count towards structuring, read from the amlStructuringMargin environment variable.
+func StructuringMargin() float64 {
+	margin, err := strconv.ParseFloat(os.Getenv("amlStructuringMargin"), 64)
+	if err != nil || margin <= 0 || margin >= 1 {
+		return defaultStructuringMargin
+	}
+	return margin
+}
+
+// StructuringCount is how many deposits just under the threshold make a structuring alert,
+// read from the amlStructuringCount environment variable.
+func StructuringCount() int {
+	return envInt("amlStructuringCount", defaultStructuringCount)
+}
+
+// StructuringDays is the window deposits are counted over for structuring, read from the
+// amlStructuringDays environment variable.
+func StructuringDays() int {
+	return envInt("amlStructuringDays", defaultStructuringDays)
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 1 {
+		return fallback
+	}
+	return value
+}
@@ -1,17 +1,41 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/aml"
+	"github.com/Jasonasante/bankAPI.git/audit"
+	"github.com/Jasonasante/bankAPI.git/beneficiary"
+	"github.com/Jasonasante/bankAPI.git/event"
+	"github.com/Jasonasante/bankAPI.git/export"
+	"github.com/Jasonasante/bankAPI.git/fee"
+	"github.com/Jasonasante/bankAPI.git/hold"
+	"github.com/Jasonasante/bankAPI.git/joint"
+	"github.com/Jasonasante/bankAPI.git/kyc"
+	"github.com/Jasonasante/bankAPI.git/limit"
 	"github.com/Jasonasante/bankAPI.git/misc"
+	"github.com/Jasonasante/bankAPI.git/reversal"
+	"github.com/Jasonasante/bankAPI.git/review"
+	"github.com/Jasonasante/bankAPI.git/sanctions"
+	"github.com/Jasonasante/bankAPI.git/standingorder"
+	"github.com/Jasonasante/bankAPI.git/statement"
+	"github.com/Jasonasante/bankAPI.git/stream"
 	"github.com/Jasonasante/bankAPI.git/transfer"
+	"github.com/Jasonasante/bankAPI.git/webhook"
 
 	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
 )
 
 func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
@@ -31,6 +55,11 @@ type apiError struct {
 func makeHttpHandler(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
+			// errors that know their own status are sent as they are, so clients can read their fields
+			if statusErr, ok := err.(interface{ StatusCode() int }); ok {
+				WriteJSON(w, statusErr.StatusCode(), statusErr)
+				return
+			}
 			WriteJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
 		}
 	}
@@ -52,29 +81,193 @@ func NewAPIServer(listenAddr string, store Storage) *APIServer {
 
 func (s *APIServer) Run() {
 	router := mux.NewRouter()
-	router.HandleFunc("/login", makeHttpHandler(s.handleLogin))
-	router.HandleFunc("/account", makeHttpHandler(s.handleAccount))
-	router.HandleFunc("/account/{id}", withJWTAuth(makeHttpHandler(s.handleGetAccountbyID), s.store))
-	router.HandleFunc("/transfer", makeHttpHandler(s.handleTransfers))
-	router.HandleFunc("/transfer/{id}", withJWTAuth(makeHttpHandler(s.handleTransferAccount), s.store))
+	router.HandleFunc("/login", makeHttpHandler(s.handleLogin)).Methods("POST")
+	router.HandleFunc("/account", makeHttpHandler(s.handleGetAccounts)).Methods("GET")
+	router.HandleFunc("/account", makeHttpHandler(s.handleCreateAccount)).Methods("POST")
+	router.HandleFunc("/account/{id}", withJWTAuth(makeHttpHandler(s.handleGetAccountbyID), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}", withJWTAuth(makeHttpHandler(s.handleUpdateAccount), s.store)).Methods("PATCH")
+	router.HandleFunc("/account/{id}", withJWTAuth(makeHttpHandler(s.handleCloseAccount), s.store)).Methods("DELETE")
+	router.HandleFunc("/account/{id}/balance", withJWTAuth(makeHttpHandler(s.handleMyBalance), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/statement", withJWTAuth(makeHttpHandler(s.handleGetStatement), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/export", withJWTAuth(makeHttpHandler(s.handleExportTransfers), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/monthly-statement", withJWTAuth(makeHttpHandler(s.handleGetStoredStatements), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/monthly-statement/{statement}", withJWTAuth(makeHttpHandler(s.handleDownloadStoredStatement), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/stream", withJWTAuth(makeHttpHandler(s.handleStream), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/state", withJWTAuth(makeHttpHandler(s.handleGetAccountAt), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/deposit", withJWTAuth(makeHttpHandler(s.handleDeposit), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/withdrawal", withJWTAuth(makeHttpHandler(s.handleWithdrawal), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/transfer", withJWTAuth(makeHttpHandler(s.handleTransfer), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/transfer/{transfer}", withJWTAuth(makeHttpHandler(s.handleUpdateTransferCategory), s.store)).Methods("PATCH")
+	router.HandleFunc("/account/{id}/fee-quote", withJWTAuth(makeHttpHandler(s.handleQuoteFee), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/payee", withJWTAuth(makeHttpHandler(s.handleConfirmPayee), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/beneficiary", withJWTAuth(makeHttpHandler(s.handleGetBeneficiaries), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/beneficiary", withJWTAuth(makeHttpHandler(s.handleCreateBeneficiary), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/beneficiary/{payee}", withJWTAuth(makeHttpHandler(s.handleRenameBeneficiary), s.store)).Methods("PATCH")
+	router.HandleFunc("/account/{id}/beneficiary/{payee}", withJWTAuth(makeHttpHandler(s.handleDeleteBeneficiary), s.store)).Methods("DELETE")
+	router.HandleFunc("/account/{id}/standing-order", withJWTAuth(makeHttpHandler(s.handleGetStandingOrders), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/standing-order", withJWTAuth(makeHttpHandler(s.handleCreateStandingOrder), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/standing-order/{order}", withJWTAuth(makeHttpHandler(s.handleUpdateStandingOrder), s.store)).Methods("PATCH")
+	router.HandleFunc("/account/{id}/interest", withJWTAuth(makeHttpHandler(s.handleGetInterest), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/notification", withJWTAuth(makeHttpHandler(s.handleGetNotifications), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/limits", withJWTAuth(makeHttpHandler(s.handleGetLimits), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/limits", withJWTAuth(makeHttpHandler(s.handleLowerLimits), s.store)).Methods("PUT")
+	router.HandleFunc("/admin/account/{id}/limits", withRoleAuth(makeHttpHandler(s.handleSetLimits), s.store, account.RoleAdmin)).Methods("PUT")
+	router.HandleFunc("/admin/account/{id}/status", withRoleAuth(makeHttpHandler(s.handleSetAccountStatus), s.store, account.RoleAdmin)).Methods("PUT")
+	router.HandleFunc("/admin/account/{id}/close", withRoleAuth(makeHttpHandler(s.handleCloseAccount), s.store, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/admin/account/{id}/overdraft", withRoleAuth(makeHttpHandler(s.handleSetOverdraft), s.store, account.RoleAdmin)).Methods("PUT")
+	router.HandleFunc("/account/{id}/owner", withJWTAuth(makeHttpHandler(s.handleGetOwners), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/owner", withJWTAuth(makeHttpHandler(s.handleAddOwner), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/signing-rule", withJWTAuth(makeHttpHandler(s.handleGetSigningRule), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/signing-rule", withJWTAuth(makeHttpHandler(s.handleSetSigningRule), s.store)).Methods("PUT")
+	router.HandleFunc("/account/{id}/pending-transfer", withJWTAuth(makeHttpHandler(s.handleGetPendingTransfers), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/pending-transfer/{pending}/approve", withJWTAuth(makeHttpHandler(s.handleApprovePendingTransfer), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/pending-transfer/{pending}/reject", withJWTAuth(makeHttpHandler(s.handleRejectPendingTransfer), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/review", withJWTAuth(makeHttpHandler(s.handleGetAccountReviewItems), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/hold", withJWTAuth(makeHttpHandler(s.handleGetHolds), s.store)).Methods("GET")
+	router.HandleFunc("/card/account/{id}/hold", withRoleAuth(makeHttpHandler(s.handleCreateHold), s.store, account.RoleCardProcessor, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/card/hold/{hold}/capture", withRoleAuth(makeHttpHandler(s.handleCaptureHold), s.store, account.RoleCardProcessor, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/card/hold/{hold}/release", withRoleAuth(makeHttpHandler(s.handleReleaseHold), s.store, account.RoleCardProcessor, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/admin/transfer/{transfer}/reversal", withRoleAuth(makeHttpHandler(s.handleReverseTransfer), s.store, account.RoleAdmin, account.RoleTeller)).Methods("POST")
+	router.HandleFunc("/review/transfer", withRoleAuth(makeHttpHandler(s.handleGetReviewItems), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/transfer/{review}/approve", withRoleAuth(makeHttpHandler(s.handleApproveReviewItem), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/review/transfer/{review}/reject", withRoleAuth(makeHttpHandler(s.handleRejectReviewItem), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/review/account/{id}/fraud-decision", withRoleAuth(makeHttpHandler(s.handleGetFraudDecisions), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/sanctions", withRoleAuth(makeHttpHandler(s.handleGetSanctionsHits), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/sanctions/{hit}/clear", withRoleAuth(makeHttpHandler(s.handleClearSanctionsHit), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/review/sanctions/{hit}/confirm", withRoleAuth(makeHttpHandler(s.handleConfirmSanctionsHit), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/account/{id}/kyc", withJWTAuth(makeHttpHandler(s.handleGetKYC), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/kyc", withJWTAuth(makeHttpHandler(s.handleSaveKYCProfile), s.store)).Methods("PUT")
+	router.HandleFunc("/account/{id}/kyc/document", withJWTAuth(makeHttpHandler(s.handleUploadKYCDocument), s.store)).Methods("POST")
+	router.HandleFunc("/account/{id}/kyc/document/{document}", withJWTAuth(makeHttpHandler(s.handleDownloadKYCDocument), s.store)).Methods("GET")
+	router.HandleFunc("/account/{id}/kyc/submit", withJWTAuth(makeHttpHandler(s.handleSubmitKYC), s.store)).Methods("POST")
+	router.HandleFunc("/review/kyc", withRoleAuth(makeHttpHandler(s.handleGetKYCProfiles), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/kyc/{id}", withRoleAuth(makeHttpHandler(s.handleGetKYC), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/kyc/{id}/document/{document}", withRoleAuth(makeHttpHandler(s.handleDownloadKYCDocument), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/kyc/{id}/verify", withRoleAuth(makeHttpHandler(s.handleVerifyKYC), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/review/kyc/{id}/reject", withRoleAuth(makeHttpHandler(s.handleRejectKYC), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/review/aml/alert", withRoleAuth(makeHttpHandler(s.handleGetAMLAlerts), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/aml/alert/{alert}", withRoleAuth(makeHttpHandler(s.handleGetAMLAlert), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/review/aml/alert/{alert}", withRoleAuth(makeHttpHandler(s.handleUpdateAMLAlert), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("PUT")
+	router.HandleFunc("/review/aml/alert/{alert}/sar", withRoleAuth(makeHttpHandler(s.handleExportSAR), s.store, account.RoleCompliance, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/admin/sanctions", withRoleAuth(makeHttpHandler(s.handleGetSanctionsList), s.store, account.RoleAdmin, account.RoleCompliance)).Methods("GET")
+	router.HandleFunc("/admin/sanctions/reload", withRoleAuth(makeHttpHandler(s.handleReloadSanctionsList), s.store, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/webhook/subscription", withRoleAuth(makeHttpHandler(s.handleGetWebhookSubscriptions), s.store, account.RoleIntegration, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/webhook/subscription", withRoleAuth(makeHttpHandler(s.handleCreateWebhookSubscription), s.store, account.RoleIntegration, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/webhook/subscription/{subscription}", withRoleAuth(makeHttpHandler(s.handleGetWebhookSubscription), s.store, account.RoleIntegration, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/webhook/subscription/{subscription}", withRoleAuth(makeHttpHandler(s.handleDisableWebhookSubscription), s.store, account.RoleIntegration, account.RoleAdmin)).Methods("DELETE")
+	router.HandleFunc("/webhook/subscription/{subscription}/delivery", withRoleAuth(makeHttpHandler(s.handleGetWebhookDeliveries), s.store, account.RoleIntegration, account.RoleAdmin)).Methods("GET")
+	router.HandleFunc("/webhook/delivery/{delivery}/redeliver", withRoleAuth(makeHttpHandler(s.handleRedeliverWebhook), s.store, account.RoleIntegration, account.RoleAdmin)).Methods("POST")
+	router.HandleFunc("/admin/event", withRoleAuth(makeHttpHandler(s.handleGetEvents), s.store, account.RoleAdmin, account.RoleAuditor)).Methods("GET")
+	router.HandleFunc("/audit", withRoleAuth(makeHttpHandler(s.handleGetAuditLog), s.store, account.RoleAuditor)).Methods("GET")
+	router.HandleFunc("/transfer", makeHttpHandler(s.handleTransfers)).Methods("GET")
+	registerOptions(router)
+	router.MethodNotAllowedHandler = methodNotAllowed(router)
 	log.Println("server opened http://localhost" + s.listenAddr)
-	http.ListenAndServe(s.listenAddr, router)
+	http.ListenAndServe(s.listenAddr, withRequestID(router))
 }
 
-// CRUD
+//
+// Method Routing
+//
+
+// allowedMethods collects the methods of every route whose path matches the request,
+// whatever method the request itself was made with.
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	allowed := []string{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		match := mux.RouteMatch{}
+		if route.Match(r, &match) || match.MatchErr == mux.ErrMethodMismatch {
+			allowed = append(allowed, methods...)
+		}
+		return nil
+	})
+	return allowed
+}
+
+// registerOptions adds an OPTIONS route for every path registered so far,
+// so it has to be called once all the other routes are in place.
+func registerOptions(router *mux.Router) {
+	paths := []string{}
+	seen := map[string]bool{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || seen[path] {
+			return nil
+		}
+		seen[path] = true
+		paths = append(paths, path)
+		return nil
+	})
+	for _, path := range paths {
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", strings.Join(allowedMethods(router, r), ", "))
+			w.WriteHeader(http.StatusNoContent)
+		}).Methods("OPTIONS")
+	}
+}
+
+// methodNotAllowed answers with a 405 listing the methods the path does support.
+func methodNotAllowed(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(router, r), ", "))
+		WriteJSON(w, http.StatusMethodNotAllowed, apiError{Error: fmt.Sprintf("method not allowed %s", r.Method)})
+	}
+}
 
-func (s *APIServer) handleAccount(w http.ResponseWriter, r *http.Request) error {
-	switch r.Method {
-	case "GET":
-		return s.handleGetAccounts(w, r)
-	case "POST":
-		return s.handleCreateAccount(w, r)
+//
+// Request Metadata
+//
+
+// requestIDKey is the request context key the request id is stored under.
+type requestIDKey struct{}
+
+// withRequestID gives every request an id, taken from its X-Request-ID header when the client
+// sent one, and echoes it back so a response can be matched to the audit log.
+func withRequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewV4().String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+// auditMeta is who made the request and where from, for the audit log.
+func auditMeta(r *http.Request) audit.Meta {
+	meta := audit.Meta{}
+	if actor := actorFrom(r); actor != nil {
+		meta.ActorID = actor.ID
+	}
+	meta.RequestID, _ = r.Context().Value(requestIDKey{}).(string)
+	meta.IP = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		meta.IP = host
 	}
-	return fmt.Errorf("method not allowed %s", r.Method)
+	return meta
 }
 
+// storeFor is the store changes made on behalf of r should go through, so they are
+// recorded in the audit log against whoever made them.
+func (s *APIServer) storeFor(r *http.Request) Storage {
+	return s.store.As(auditMeta(r))
+}
+
+// CRUD
+
 func (s *APIServer) handleGetAccounts(w http.ResponseWriter, r *http.Request) error {
-	accounts, err := s.store.GetAllAccounts()
+	status := r.URL.Query().Get("status")
+	if status != "" {
+		if err := account.ValidateStatus(status); err != nil {
+			return err
+		}
+	}
+	accounts, err := s.store.GetAllAccounts(status)
 	if err != nil {
 		fmt.Println("Failed to get all accounts")
 		return err
@@ -83,26 +276,16 @@ func (s *APIServer) handleGetAccounts(w http.ResponseWriter, r *http.Request) er
 }
 
 func (s *APIServer) handleGetAccountbyID(w http.ResponseWriter, r *http.Request) error {
-	switch r.Method {
-	case "GET":
-		id, err := misc.GetID(r)
-		if err != nil {
-			fmt.Println("Invalid ID given!!!")
-			return err
-		}
-		account, err := s.store.GetAccountByID(id)
-		if err != nil {
-			return fmt.Errorf("failed to retrieve account by id : %v", err)
-		}
-		return WriteJSON(w, http.StatusOK, account)
-
-	case "DELETE":
-		return s.handleDeleteAccount(w, r)
-
-	case "PATCH":
-		return s.handleUpdateAccount(w, r)
+	id, err := misc.GetID(r)
+	if err != nil {
+		fmt.Println("Invalid ID given!!!")
+		return err
+	}
+	account, err := s.store.GetAccountByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve account by id : %v", err)
 	}
-	return fmt.Errorf("method not allowed : %v", r.Method)
+	return WriteJSON(w, http.StatusOK, account)
 }
 
 func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) error {
@@ -111,13 +294,21 @@ func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 	defer r.Body.Close()
+	if err := account.ValidateType(acctRequest.AccountType); err != nil {
+		return err
+	}
+	if acctRequest.Profile != nil {
+		if err := kyc.ValidateProfile(acctRequest.Profile, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
 	password, err := misc.HashPassword(acctRequest.Password)
 	if err != nil {
 		fmt.Println("could not hash password")
 		log.Fatal(err)
 	}
-	account := account.CreateAccount(acctRequest.FirstName, acctRequest.LastName, acctRequest.Username, password)
-	if err := s.store.CreateAccount(account); err != nil {
+	account := account.CreateAccount(acctRequest.FirstName, acctRequest.LastName, acctRequest.Username, password, acctRequest.AccountType)
+	if err := s.storeFor(r).CreateAccount(account, acctRequest.Profile); err != nil {
 		fmt.Println("error here", account)
 		return err
 	}
@@ -132,9 +323,6 @@ func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *APIServer) handleLogin(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != "POST" {
-		return fmt.Errorf("method not allowed %v", r.Method)
-	}
 	loginReq := account.LoginRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
 		return err
@@ -172,6 +360,9 @@ func (s *APIServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request)
 	if currentUser.Username != updateReq.CurrentUsername || !misc.CheckPasswordHash(updateReq.CurrentPassword, currentUser.Password) {
 		return fmt.Errorf("Access Denied")
 	}
+	if err := currentUser.Allows(account.OperationUpdate); err != nil {
+		return err
+	}
 
 	updateReq.Username = misc.DefaultValue(updateReq.Username, currentUser.Username)
 	updateReq.Password, err = misc.HashPassword(misc.DefaultValue(updateReq.Password, currentUser.Password))
@@ -181,28 +372,38 @@ func (s *APIServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request)
 
 	updateReq.FirstName = misc.DefaultValue(updateReq.FirstName, currentUser.FirstName)
 	updateReq.LastName = misc.DefaultValue(updateReq.LastName, currentUser.LastName)
-	if err := s.store.UpdateAccount(id, &updateReq); err != nil {
+	if err := s.storeFor(r).UpdateAccount(id, &updateReq); err != nil {
 		return err
 	}
 
 	return WriteJSON(w, http.StatusOK, updateReq)
 }
 
-func (s *APIServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request) error {
+// handleCloseAccount closes an account rather than deleting it. The body, which can be left
+// out when the balance is zero, says where any money left in the account should go.
+func (s *APIServer) handleCloseAccount(w http.ResponseWriter, r *http.Request) error {
+	closeReq := account.CloseRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&closeReq); err != nil && err != io.EOF {
+		return err
+	}
+	defer r.Body.Close()
 	id, err := misc.GetID(r)
 	if err != nil {
 		fmt.Println("invalid ID given!!!")
 		return err
 	}
-	if err := s.store.DeleteAccount(id); err != nil {
-		return fmt.Errorf("failed to delete account by id : %v", err)
+	if actorFrom(r).Role != account.RoleAdmin {
+		if err := requireHolder(r, id); err != nil {
+			return err
+		}
 	}
-	return WriteJSON(w, http.StatusOK, map[string]int{"deleted": id})
+	if err := s.storeFor(r).CloseAccount(id, &closeReq); err != nil {
+		return fmt.Errorf("failed to close account by id : %v", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]int{"closed": id})
 }
 
-//
 // Transfers
-//
 func (s *APIServer) handleTransfers(w http.ResponseWriter, r *http.Request) error {
 	allTransfers, err := s.store.GetAllTransfers()
 	if err != nil {
@@ -221,7 +422,11 @@ func (s *APIServer) handleMyBalance(w http.ResponseWriter, r *http.Request) erro
 	if err != nil {
 		return err
 	}
-	transactions, err := s.store.GetMyTransfers(id)
+	filter := transfer.HistoryFilter{
+		Search:   r.URL.Query().Get("search"),
+		Category: r.URL.Query().Get("category"),
+	}
+	transactions, err := s.store.GetMyTransfers(id, &filter)
 	if err != nil {
 		return err
 	}
@@ -232,9 +437,9 @@ func (s *APIServer) handleMyBalance(w http.ResponseWriter, r *http.Request) erro
 	return WriteJSON(w, http.StatusOK, myTransfers)
 }
 
-func (s *APIServer) handleDepositsAndWithdrawals(w http.ResponseWriter, r *http.Request) error {
-	transferRequest := transfer.TransferRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&transferRequest); err != nil {
+func (s *APIServer) handleDeposit(w http.ResponseWriter, r *http.Request) error {
+	depositRequest := transfer.DepositRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&depositRequest); err != nil {
 		return err
 	}
 	defer r.Body.Close()
@@ -242,17 +447,27 @@ func (s *APIServer) handleDepositsAndWithdrawals(w http.ResponseWriter, r *http.
 	if err != nil {
 		return fmt.Errorf("Permission Denied")
 	}
-	currentUser, err := s.store.GetAccountByID(id)
+	myBalance, err := s.storeFor(r).Deposit(id, &depositRequest)
 	if err != nil {
-		return fmt.Errorf("Account Does Not Exist")
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, myBalance)
+}
+
+func (s *APIServer) handleWithdrawal(w http.ResponseWriter, r *http.Request) error {
+	withdrawalRequest := transfer.WithdrawalRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&withdrawalRequest); err != nil {
+		return err
 	}
-	myBalance, err := s.store.DepositWithdrawIntoMyAccount(id, &transferRequest)
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	myBalance, err := s.storeFor(r).Withdraw(id, &withdrawalRequest)
 	if err != nil {
 		return err
 	}
-	time := time.Now().UTC()
-	addTransfer := transfer.CreateTransfer(id, id, transferRequest.Amount, currentUser.Balance, myBalance.Balance, misc.DepositOrWithdrawal(transferRequest.Amount), time)
-	s.store.CreateTransfer(addTransfer)
 	return WriteJSON(w, http.StatusOK, myBalance)
 }
 
@@ -266,46 +481,1248 @@ func (s *APIServer) handleTransfer(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return fmt.Errorf("Permission Denied")
 	}
+	transferResponse, err := s.storeFor(r).Transfer(id, actorFrom(r).ID, &transferRequest)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, transferResponse)
+}
 
-	currentUser, err := s.store.GetAccountByID(id)
+// handleUpdateTransferCategory lets the account holder change the category of one of their transfers,
+// the only detail of a transfer that can be edited after the fact.
+func (s *APIServer) handleUpdateTransferCategory(w http.ResponseWriter, r *http.Request) error {
+	updateReq := transfer.UpdateCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
 	if err != nil {
-		return fmt.Errorf("Account Does Not Exist")
+		return fmt.Errorf("Permission Denied")
 	}
-	recipientUser, err := s.store.GetAccountByID(transferRequest.ToAccount)
+	transferID, err := misc.GetIntVar(r, "transfer")
 	if err != nil {
-		return fmt.Errorf("Account Does Not Exist")
+		return fmt.Errorf("invalid transfer id")
+	}
+	if err := transfer.ValidateCategory(updateReq.Category); err != nil {
+		return err
+	}
+	if err := s.storeFor(r).UpdateTransferCategory(id, transferID, updateReq.Category); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, updateReq)
+}
+
+// handleQuoteFee tells a customer what a transfer or withdrawal will cost before they make it.
+func (s *APIServer) handleQuoteFee(w http.ResponseWriter, r *http.Request) error {
+	quoteReq := fee.QuoteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&quoteReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	quote, err := s.store.QuoteFee(id, quoteReq.Operation, quoteReq.Amount)
+	if err != nil {
+		return err
 	}
+	return WriteJSON(w, http.StatusOK, quote)
+}
 
-	transferResponse, err := s.store.Transfer(id, &transferRequest)
+// handleConfirmPayee lets a customer check who they are about to pay before sending anything.
+// The recipient is looked up by ?bank-number= or ?username= and only a masked name is returned.
+func (s *APIServer) handleConfirmPayee(w http.ResponseWriter, r *http.Request) error {
+	var bankNumber int64
+	if value := r.URL.Query().Get("bank-number"); value != "" {
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid bank number %q", value)
+		}
+		bankNumber = number
+	}
+	recipient, err := s.store.ResolveRecipient(bankNumber, r.URL.Query().Get("username"))
 	if err != nil {
 		return err
 	}
-	time := time.Now().UTC()
-	addTransferFrom := transfer.CreateTransfer(id, transferRequest.ToAccount, transferRequest.Amount, currentUser.Balance, transferResponse.Account.Balance, "withdrawal", time)
-	addTransferTo := transfer.CreateTransfer(id, transferRequest.ToAccount, transferRequest.Amount, recipientUser.Balance, recipientUser.Balance+int64(transferRequest.Amount), "deposit", time)
-	if err := s.store.CreateTransfer(addTransferFrom); err != nil {
-		return fmt.Errorf("Could Not Add Transaction To Table")
+	return WriteJSON(w, http.StatusOK, transfer.Payee{
+		BankNumber: recipient.BankNumber,
+		Name:       misc.MaskName(recipient.FirstName + " " + recipient.LastName),
+	})
+}
+
+//
+// Beneficiaries
+//
+
+func (s *APIServer) handleGetBeneficiaries(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
 	}
-	if err := s.store.CreateTransfer(addTransferTo); err != nil {
-		return fmt.Errorf("Could Not Add Transaction To Table")
+	beneficiaries, err := s.store.GetBeneficiaries(id)
+	if err != nil {
+		return err
 	}
-	return WriteJSON(w, http.StatusOK, transferResponse)
+	return WriteJSON(w, http.StatusOK, beneficiaries)
+}
+
+func (s *APIServer) handleCreateBeneficiary(w http.ResponseWriter, r *http.Request) error {
+	createReq := beneficiary.CreateBeneficiaryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	if createReq.Nickname == "" {
+		return fmt.Errorf("nickname is required")
+	}
+	recipient, err := s.store.ResolveRecipient(createReq.BankNumber, createReq.Username)
+	if err != nil {
+		return err
+	}
+	if recipient.ID == id {
+		return fmt.Errorf("cannot save your own account as a payee")
+	}
+	payee := beneficiary.CreateBeneficiary(id, createReq.Nickname, recipient.BankNumber, misc.MaskName(recipient.FirstName+" "+recipient.LastName))
+	if err := s.storeFor(r).CreateBeneficiary(payee); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, payee)
+}
+
+func (s *APIServer) handleRenameBeneficiary(w http.ResponseWriter, r *http.Request) error {
+	renameReq := beneficiary.RenameBeneficiaryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&renameReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	payeeID, err := misc.GetIntVar(r, "payee")
+	if err != nil {
+		return fmt.Errorf("invalid payee id")
+	}
+	if renameReq.Nickname == "" {
+		return fmt.Errorf("nickname is required")
+	}
+	if err := s.storeFor(r).RenameBeneficiary(id, payeeID, renameReq.Nickname); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, renameReq)
+}
+
+func (s *APIServer) handleDeleteBeneficiary(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	payeeID, err := misc.GetIntVar(r, "payee")
+	if err != nil {
+		return fmt.Errorf("invalid payee id")
+	}
+	if err := s.storeFor(r).DeleteBeneficiary(id, payeeID); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, map[string]int{"deleted": payeeID})
+}
+
+//
+// Standing Orders
+//
+
+func (s *APIServer) handleGetStandingOrders(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	orders, err := s.store.GetStandingOrders(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, orders)
+}
+
+func (s *APIServer) handleCreateStandingOrder(w http.ResponseWriter, r *http.Request) error {
+	createReq := standingorder.CreateStandingOrderRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	if err := standingorder.Validate(&createReq); err != nil {
+		return err
+	}
+	if err := transfer.ValidateDetails(createReq.Memo, createReq.Reference, createReq.Category); err != nil {
+		return err
+	}
+	// the recipient is checked now and pinned by bank number, unless it is a saved payee
+	var toBankNumber int64
+	if createReq.PayeeID != 0 {
+		if createReq.ToBankNumber != 0 || createReq.ToUsername != "" {
+			return fmt.Errorf("give either a payee id or a recipient, not both")
+		}
+		if _, err := s.store.GetBeneficiary(id, createReq.PayeeID); err != nil {
+			return err
+		}
+	} else {
+		recipient, err := s.store.ResolveRecipient(createReq.ToBankNumber, createReq.ToUsername)
+		if err != nil {
+			return err
+		}
+		if recipient.ID == id {
+			return fmt.Errorf("cannot transfer to your own account")
+		}
+		toBankNumber = recipient.BankNumber
+	}
+	order := standingorder.CreateStandingOrder(id, toBankNumber, &createReq)
+	if err := s.storeFor(r).CreateStandingOrder(order); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, order)
+}
+
+// handleUpdateStandingOrder pauses, resumes or cancels a standing order.
+func (s *APIServer) handleUpdateStandingOrder(w http.ResponseWriter, r *http.Request) error {
+	updateReq := standingorder.UpdateStatusRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	orderID, err := misc.GetIntVar(r, "order")
+	if err != nil {
+		return fmt.Errorf("invalid standing order id")
+	}
+	if err := s.storeFor(r).UpdateStandingOrderStatus(id, orderID, updateReq.Status); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, updateReq)
+}
+
+//
+// Notifications
+//
+
+func (s *APIServer) handleGetNotifications(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	notifications, err := s.store.GetNotifications(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, notifications)
+}
+
+//
+// Interest
+//
+
+// handleGetInterest lists the interest accrued day by day on the account.
+func (s *APIServer) handleGetInterest(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	accruals, err := s.store.GetInterestAccruals(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, accruals)
 }
 
 //
-func (s *APIServer) handleTransferAccount(w http.ResponseWriter, r *http.Request) error {
+// Limits
+//
 
-	switch r.Method {
-	case "GET":
-		// get current user balance
-		return s.handleMyBalance(w, r)
-	case "PATCH":
-		// withdrawal/deposit into user account
-		return s.handleDepositsAndWithdrawals(w, r)
-	case "POST":
-		// transfer from user to recipient
-		return s.handleTransfer(w, r)
+func (s *APIServer) handleGetLimits(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	allowance, err := s.store.GetAllowance(id)
+	if err != nil {
+		return err
 	}
+	return WriteJSON(w, http.StatusOK, allowance)
+}
 
-	return fmt.Errorf("method not allowed : %v", r.Method)
+// handleLowerLimits lets account holders tighten their own limits. Raising them takes an admin.
+func (s *APIServer) handleLowerLimits(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	return s.setLimits(w, r, true)
+}
+
+func (s *APIServer) handleSetLimits(w http.ResponseWriter, r *http.Request) error {
+	return s.setLimits(w, r, false)
+}
+
+func (s *APIServer) setLimits(w http.ResponseWriter, r *http.Request, onlyLower bool) error {
+	limits := limit.Limits{}
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := s.storeFor(r).SetLimits(id, limits, onlyLower); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, limits)
+}
+
+//
+// Joint Accounts
+//
+
+// requireHolder stops co-owners from doing what only the account holder can, such as
+// changing who else owns the account, what they need to approve, and the limits, payees
+// and standing orders money leaves it under.
+func requireHolder(r *http.Request, id int) error {
+	if actorFrom(r).ID != id {
+		return fmt.Errorf("only the account holder can do this")
+	}
+	return nil
+}
+
+func (s *APIServer) handleGetOwners(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	owners, err := s.store.GetOwners(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, owners)
+}
+
+func (s *APIServer) handleAddOwner(w http.ResponseWriter, r *http.Request) error {
+	ownerReq := joint.AddOwnerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&ownerReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	owner, err := s.storeFor(r).AddOwner(id, ownerReq.Username)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, owner)
+}
+
+func (s *APIServer) handleGetSigningRule(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	rule, err := s.store.GetSigningRule(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, rule)
+}
+
+func (s *APIServer) handleSetSigningRule(w http.ResponseWriter, r *http.Request) error {
+	rule := joint.SigningRule{}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	rule.AccountID = id
+	if err := s.storeFor(r).SetSigningRule(&rule); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, rule)
+}
+
+func (s *APIServer) handleGetPendingTransfers(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	pending, err := s.store.GetPendingTransfers(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, pending)
+}
+
+func (s *APIServer) handleApprovePendingTransfer(w http.ResponseWriter, r *http.Request) error {
+	return s.decidePendingTransfer(w, r, joint.DecisionApprove)
+}
+
+func (s *APIServer) handleRejectPendingTransfer(w http.ResponseWriter, r *http.Request) error {
+	return s.decidePendingTransfer(w, r, joint.DecisionReject)
+}
+
+func (s *APIServer) decidePendingTransfer(w http.ResponseWriter, r *http.Request, decision string) error {
+	decisionReq := joint.DecisionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&decisionReq); err != nil && err != io.EOF {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	pendingID, err := misc.GetIntVar(r, "pending")
+	if err != nil {
+		return fmt.Errorf("invalid pending transfer id")
+	}
+	pending, err := s.storeFor(r).DecidePendingTransfer(id, pendingID, actorFrom(r).ID, decision, decisionReq.Comment)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, pending)
+}
+
+//
+// Review
+//
+
+func (s *APIServer) handleGetAccountReviewItems(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	items, err := s.store.GetAccountReviewItems(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, items)
+}
+
+// handleGetReviewItems lists the transfers waiting for review, or those with ?status= if given.
+func (s *APIServer) handleGetReviewItems(w http.ResponseWriter, r *http.Request) error {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = review.StatusPending
+	}
+	if status == "all" {
+		status = ""
+	}
+	items, err := s.store.GetReviewItems(status)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, items)
+}
+
+func (s *APIServer) handleGetFraudDecisions(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	decisions, err := s.store.GetFraudDecisions(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, decisions)
+}
+
+func (s *APIServer) handleApproveReviewItem(w http.ResponseWriter, r *http.Request) error {
+	return s.decideReviewItem(w, r, true)
+}
+
+func (s *APIServer) handleRejectReviewItem(w http.ResponseWriter, r *http.Request) error {
+	return s.decideReviewItem(w, r, false)
+}
+
+func (s *APIServer) decideReviewItem(w http.ResponseWriter, r *http.Request, approve bool) error {
+	decisionReq := review.DecisionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&decisionReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := review.ValidateDecision(&decisionReq); err != nil {
+		return err
+	}
+	itemID, err := misc.GetIntVar(r, "review")
+	if err != nil {
+		return fmt.Errorf("invalid review id")
+	}
+	item, err := s.storeFor(r).DecideReviewItem(itemID, actorFrom(r).ID, approve, decisionReq.Comment)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, item)
+}
+
+//
+// Sanctions
+//
+
+func (s *APIServer) handleGetSanctionsHits(w http.ResponseWriter, r *http.Request) error {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = sanctions.HitOpen
+	}
+	if status == "all" {
+		status = ""
+	} else if err := sanctions.ValidateHitStatus(status); err != nil {
+		return err
+	}
+	hits, err := s.store.GetSanctionsHits(status)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, hits)
+}
+
+func (s *APIServer) handleClearSanctionsHit(w http.ResponseWriter, r *http.Request) error {
+	return s.decideSanctionsHit(w, r, true)
+}
+
+func (s *APIServer) handleConfirmSanctionsHit(w http.ResponseWriter, r *http.Request) error {
+	return s.decideSanctionsHit(w, r, false)
+}
+
+func (s *APIServer) decideSanctionsHit(w http.ResponseWriter, r *http.Request, clear bool) error {
+	decisionReq := review.DecisionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&decisionReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := review.ValidateDecision(&decisionReq); err != nil {
+		return err
+	}
+	hitID, err := misc.GetIntVar(r, "hit")
+	if err != nil {
+		return fmt.Errorf("invalid hit id")
+	}
+	hit, err := s.storeFor(r).DecideSanctionsHit(hitID, actorFrom(r).ID, clear, decisionReq.Comment)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, hit)
+}
+
+func (s *APIServer) handleGetSanctionsList(w http.ResponseWriter, r *http.Request) error {
+	return WriteJSON(w, http.StatusOK, s.store.SanctionsListInfo())
+}
+
+// handleReloadSanctionsList reads the sanctions list from its file again without a restart.
+func (s *APIServer) handleReloadSanctionsList(w http.ResponseWriter, r *http.Request) error {
+	info, err := s.storeFor(r).ReloadSanctionsList()
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, info)
+}
+
+//
+// KYC
+//
+
+func (s *APIServer) handleGetKYC(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	record, err := s.store.GetKYC(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, record)
+}
+
+// handleSaveKYCProfile fills in or corrects the account holder's identity details.
+func (s *APIServer) handleSaveKYCProfile(w http.ResponseWriter, r *http.Request) error {
+	profileReq := kyc.ProfileRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&profileReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	profile, err := s.storeFor(r).SaveKYCProfile(id, &profileReq)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, profile)
+}
+
+// handleUploadKYCDocument takes a document as a multipart form with its type in the "type"
+// field and the file in the "file" field.
+func (s *APIServer) handleUploadKYCDocument(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, kyc.MaxDocumentBytes()+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return fmt.Errorf("a document must be uploaded in the file field : %v", err)
+	}
+	defer file.Close()
+	document, err := s.storeFor(r).AddKYCDocument(id, r.FormValue("type"), header.Filename, file)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, document)
+}
+
+func (s *APIServer) handleDownloadKYCDocument(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	documentID, err := misc.GetIntVar(r, "document")
+	if err != nil {
+		return fmt.Errorf("invalid document id")
+	}
+	document, file, err := s.store.OpenKYCDocument(id, documentID)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", document.Filename))
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (s *APIServer) handleSubmitKYC(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	if err := requireHolder(r, id); err != nil {
+		return err
+	}
+	profile, err := s.storeFor(r).SubmitKYC(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, profile)
+}
+
+func (s *APIServer) handleGetKYCProfiles(w http.ResponseWriter, r *http.Request) error {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = kyc.StatusPending
+	}
+	if status == "all" {
+		status = ""
+	}
+	profiles, err := s.store.GetKYCProfiles(status)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, profiles)
+}
+
+func (s *APIServer) handleVerifyKYC(w http.ResponseWriter, r *http.Request) error {
+	return s.decideKYC(w, r, true)
+}
+
+func (s *APIServer) handleRejectKYC(w http.ResponseWriter, r *http.Request) error {
+	return s.decideKYC(w, r, false)
+}
+
+func (s *APIServer) decideKYC(w http.ResponseWriter, r *http.Request, verify bool) error {
+	decisionReq := review.DecisionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&decisionReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := review.ValidateDecision(&decisionReq); err != nil {
+		return err
+	}
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	profile, err := s.storeFor(r).DecideKYC(id, actorFrom(r).ID, verify, decisionReq.Comment)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, profile)
+}
+
+//
+// AML
+//
+
+func (s *APIServer) handleGetAMLAlerts(w http.ResponseWriter, r *http.Request) error {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = aml.StatusOpen
+	}
+	if status == "all" {
+		status = ""
+	}
+	alerts, err := s.store.GetAMLAlerts(status)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, alerts)
+}
+
+func (s *APIServer) handleGetAMLAlert(w http.ResponseWriter, r *http.Request) error {
+	alertID, err := misc.GetIntVar(r, "alert")
+	if err != nil {
+		return fmt.Errorf("invalid alert id")
+	}
+	alert, err := s.store.GetAMLAlert(alertID)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, alert)
+}
+
+// handleUpdateAMLAlert starts or stops an investigation into an alert, or closes it.
+func (s *APIServer) handleUpdateAMLAlert(w http.ResponseWriter, r *http.Request) error {
+	updateReq := aml.UpdateAlertRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	alertID, err := misc.GetIntVar(r, "alert")
+	if err != nil {
+		return fmt.Errorf("invalid alert id")
+	}
+	alert, err := s.storeFor(r).UpdateAMLAlert(alertID, actorFrom(r).ID, &updateReq)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, alert)
+}
+
+// handleExportSAR downloads a suspicious activity report for an alert as a text file.
+func (s *APIServer) handleExportSAR(w http.ResponseWriter, r *http.Request) error {
+	alertID, err := misc.GetIntVar(r, "alert")
+	if err != nil {
+		return fmt.Errorf("invalid alert id")
+	}
+	report, err := s.store.GetAMLReport(alertID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="sar-%d.txt"`, alertID))
+	return aml.WriteSAR(w, report)
+}
+
+//
+// Holds
+//
+
+func (s *APIServer) handleGetHolds(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	holds, err := s.store.GetHolds(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, holds)
+}
+
+// handleCreateHold authorises a card payment against an account without settling it.
+func (s *APIServer) handleCreateHold(w http.ResponseWriter, r *http.Request) error {
+	holdReq := hold.CreateHoldRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&holdReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := hold.Validate(&holdReq); err != nil {
+		return err
+	}
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	h := hold.CreateHold(id, &holdReq)
+	if err := s.storeFor(r).CreateHold(h); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *APIServer) handleCaptureHold(w http.ResponseWriter, r *http.Request) error {
+	captureReq := hold.CaptureRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&captureReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	holdID, err := misc.GetIntVar(r, "hold")
+	if err != nil {
+		return fmt.Errorf("invalid hold id")
+	}
+	h, err := s.storeFor(r).CaptureHold(holdID, captureReq.Amount)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *APIServer) handleReleaseHold(w http.ResponseWriter, r *http.Request) error {
+	holdID, err := misc.GetIntVar(r, "hold")
+	if err != nil {
+		return fmt.Errorf("invalid hold id")
+	}
+	h, err := s.storeFor(r).ReleaseHold(holdID)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, h)
+}
+
+//
+// Admin
+//
+
+// handleSetAccountStatus freezes, unfreezes or reactivates an account.
+func (s *APIServer) handleSetAccountStatus(w http.ResponseWriter, r *http.Request) error {
+	statusReq := account.StatusRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&statusReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := account.ValidateStatus(statusReq.Status); err != nil {
+		return err
+	}
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	if err := s.storeFor(r).SetAccountStatus(id, statusReq.Status); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, statusReq)
+}
+
+// handleSetOverdraft arranges, changes or removes (with a limit of 0) an account's overdraft.
+func (s *APIServer) handleSetOverdraft(w http.ResponseWriter, r *http.Request) error {
+	overdraftReq := account.OverdraftRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&overdraftReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("invalid account id")
+	}
+	if err := s.storeFor(r).SetOverdraftLimit(id, overdraftReq.OverdraftLimit); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, overdraftReq)
+}
+
+// handleReverseTransfer undoes a completed transfer, either side of which can be given.
+func (s *APIServer) handleReverseTransfer(w http.ResponseWriter, r *http.Request) error {
+	reversalReq := reversal.ReversalRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&reversalReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := reversal.Validate(&reversalReq); err != nil {
+		return err
+	}
+	transferID, err := misc.GetIntVar(r, "transfer")
+	if err != nil {
+		return fmt.Errorf("invalid transfer id")
+	}
+	rev := reversal.CreateReversal(transferID, actorFrom(r).ID, &reversalReq)
+	if err := s.storeFor(r).ReverseTransfer(rev); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, rev)
+}
+
+func (s *APIServer) handleGetAuditLog(w http.ResponseWriter, r *http.Request) error {
+	filter, err := audit.ParseFilter(r.URL.Query())
+	if err != nil {
+		return err
+	}
+	entries, err := s.store.GetAuditLog(filter)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, entries)
+}
+
+// handleGetAccountAt rebuilds the account from its events as it stood at the time given by
+// the at query parameter, or as it stands now.
+func (s *APIServer) handleGetAccountAt(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	at := time.Now().UTC()
+	if value := r.URL.Query().Get("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("at must be an RFC 3339 time")
+		}
+		at = parsed.UTC()
+	}
+	acc, err := s.store.GetAccountAt(id, at)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, acc)
+}
+
+// handleGetEvents pages through the event store, oldest first, from after the event id given
+// by the after query parameter.
+func (s *APIServer) handleGetEvents(w http.ResponseWriter, r *http.Request) error {
+	after, limit := 0, event.DefaultPageSize
+	if value := r.URL.Query().Get("after"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("invalid event id %q", value)
+		}
+		after = parsed
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > event.MaxPageSize {
+			return fmt.Errorf("limit must be between 1 and %d", event.MaxPageSize)
+		}
+		limit = parsed
+	}
+	events, err := s.store.GetEvents(after, limit)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, events)
+}
+
+//
+// Webhook
+//
+
+func (s *APIServer) handleGetWebhookSubscriptions(w http.ResponseWriter, r *http.Request) error {
+	subs, err := s.store.GetWebhookSubscriptions(actorFrom(r).ID)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, subs)
+}
+
+// handleCreateWebhookSubscription subscribes the client to events. The response is the only
+// time the secret payloads are signed with is shown.
+func (s *APIServer) handleCreateWebhookSubscription(w http.ResponseWriter, r *http.Request) error {
+	subReq := webhook.SubscriptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&subReq); err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if err := webhook.ValidateSubscription(&subReq); err != nil {
+		return err
+	}
+	sub, err := webhook.CreateSubscription(actorFrom(r).ID, &subReq)
+	if err != nil {
+		return err
+	}
+	if err := s.storeFor(r).CreateWebhookSubscription(sub); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, sub)
+}
+
+func (s *APIServer) handleGetWebhookSubscription(w http.ResponseWriter, r *http.Request) error {
+	subID, err := misc.GetIntVar(r, "subscription")
+	if err != nil {
+		return fmt.Errorf("invalid subscription id")
+	}
+	sub, err := s.store.GetWebhookSubscription(actorFrom(r).ID, subID)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, sub)
+}
+
+func (s *APIServer) handleDisableWebhookSubscription(w http.ResponseWriter, r *http.Request) error {
+	subID, err := misc.GetIntVar(r, "subscription")
+	if err != nil {
+		return fmt.Errorf("invalid subscription id")
+	}
+	if err := s.storeFor(r).DisableWebhookSubscription(actorFrom(r).ID, subID); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, map[string]int{"disabled": subID})
+}
+
+func (s *APIServer) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	subID, err := misc.GetIntVar(r, "subscription")
+	if err != nil {
+		return fmt.Errorf("invalid subscription id")
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" {
+		if err := webhook.ValidateStatus(status); err != nil {
+			return err
+		}
+	}
+	deliveries, err := s.store.GetWebhookDeliveries(actorFrom(r).ID, subID, status)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, deliveries)
+}
+
+func (s *APIServer) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request) error {
+	deliveryID, err := misc.GetIntVar(r, "delivery")
+	if err != nil {
+		return fmt.Errorf("invalid delivery id")
+	}
+	delivery, err := s.storeFor(r).RedeliverWebhook(actorFrom(r).ID, deliveryID)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, delivery)
+}
+
+//
+// Stream
+//
+
+// handleStream pushes balance changes and new transfers on the account as server-sent
+// events as soon as they are committed. A client reconnecting with the
+// Last-Event-ID header, or the after query parameter, is first sent everything it missed.
+func (s *APIServer) handleStream(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming is not supported")
+	}
+	after := 0
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("after")
+	}
+	if value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("invalid event id %q", value)
+		}
+		after = parsed
+	}
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	accountIDs := []int{id}
+	// subscribing before catching up means nothing committed in between is missed, and
+	// anything sent while catching up is skipped when it arrives from the bus
+	sub := s.store.SubscribeEvents(accountIDs)
+	defer sub.Close()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := stream.WriteRetry(w); err != nil {
+		return nil
+	}
+	for after > 0 {
+		events, err := s.store.GetStreamEvents(accountIDs, after, event.MaxPageSize)
+		if err != nil {
+			log.Printf("could not catch stream up from event %d : %v", after, err)
+			return nil
+		}
+		for _, e := range events {
+			if err := stream.Write(w, e); err != nil {
+				return nil
+			}
+			after = e.ID
+		}
+		if len(events) < event.MaxPageSize {
+			break
+		}
+	}
+	flusher.Flush()
+	heartbeat := time.NewTicker(stream.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case e, ok := <-sub.Events():
+			// a closed channel means the client fell behind, and can reconnect to catch up
+			if !ok {
+				return nil
+			}
+			if e.ID <= after {
+				continue
+			}
+			if err := stream.Write(w, e); err != nil {
+				return nil
+			}
+			after = e.ID
+		case <-heartbeat.C:
+			if err := stream.WriteHeartbeat(w); err != nil {
+				return nil
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+//
+// Statement
+//
+
+// handleGetStatement puts together a statement for the days given by the from and to query
+// parameters, as JSON, or as a CSV or PDF file with the format parameter.
+func (s *APIServer) handleGetStatement(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	query := r.URL.Query()
+	format := misc.DefaultValue(query.Get("format"), statement.FormatJSON)
+	if err := statement.ValidateFormat(format); err != nil {
+		return err
+	}
+	from, to, err := statement.ParsePeriod(query.Get("from"), query.Get("to"), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	st, err := s.store.GetStatement(id, from, to)
+	if err != nil {
+		return err
+	}
+	if format == statement.FormatJSON {
+		return WriteJSON(w, http.StatusOK, st)
+	}
+	w.Header().Set("Content-Type", statement.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statement.Filename(st.BankNumber, from, to, format)))
+	if format == statement.FormatCSV {
+		return statement.WriteCSV(w, st)
+	}
+	return statement.WritePDF(w, st)
+}
+
+func (s *APIServer) handleGetStoredStatements(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	statements, err := s.store.GetStoredStatements(id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, statements)
+}
+
+// handleDownloadStoredStatement sends a statement made by the monthly job as a PDF, or as
+// CSV with format=csv.
+func (s *APIServer) handleDownloadStoredStatement(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	statementID, err := misc.GetIntVar(r, "statement")
+	if err != nil {
+		return fmt.Errorf("invalid statement id")
+	}
+	format := misc.DefaultValue(r.URL.Query().Get("format"), statement.FormatPDF)
+	st, err := s.store.GetStoredStatement(id, statementID)
+	if err != nil {
+		return err
+	}
+	file, err := st.File(format)
+	if err != nil {
+		return err
+	}
+	acc, err := s.store.GetAccountByID(id)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", statement.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statement.Filename(acc.BankNumber, st.From, st.To, format)))
+	_, err = w.Write(file)
+	return err
+}
+
+// handleExportTransfers sends the account's transfers over the days given by the from and to
+// query parameters as an OFX, QIF or camt.053 file, chosen with the format parameter.
+func (s *APIServer) handleExportTransfers(w http.ResponseWriter, r *http.Request) error {
+	id, err := misc.GetID(r)
+	if err != nil {
+		return fmt.Errorf("Permission Denied")
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if err := export.ValidateFormat(format); err != nil {
+		return err
+	}
+	from, to, err := statement.ParsePeriod(query.Get("from"), query.Get("to"), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	ledger, err := s.store.GetExport(id, from, to)
+	if err != nil {
+		return err
+	}
+	var file bytes.Buffer
+	if err := export.Write(&file, format, ledger); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(ledger.Account.BankNumber, from, to, format)))
+	_, err = w.Write(file.Bytes())
+	return err
 }
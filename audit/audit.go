@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of thing an action can be taken on.
+const (
+	TargetAccount       = "account"
+	TargetTransfer      = "transfer"
+	TargetBeneficiary   = "beneficiary"
+	TargetStandingOrder = "standing-order"
+	TargetHold          = "hold"
+	TargetPending       = "pending-transfer"
+	TargetReview        = "review-item"
+	TargetSanctionsHit  = "sanctions-hit"
+	TargetSanctionsList = "sanctions-list"
+	TargetAMLAlert      = "aml-alert"
+	TargetKYC           = "kyc"
+	TargetWebhook       = "webhook-subscription"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Redacted stands in for the value of a secret field in a diff.
+const Redacted = "[redacted]"
+
+// secretFields are never written to the log, only whether they changed.
+var secretFields = []string{"password", "token", "national-id", "secret"}
+
+// Meta is who made a change and through which request. An empty Meta is the system itself,
+// such as a command run on the server.
+type Meta struct {
+	ActorID   int
+	RequestID string
+	IP        string
+}
+
+// Change is the value of one field before and after an action.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Entry is one action in the audit log. Hash covers every other field along with the hash of
+// the entry before it, so changing, removing or reordering entries breaks the chain.
+type Entry struct {
+	ID         int             `json:"id"`
+	ActorID    int             `json:"actor-id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target-type"`
+	TargetID   int             `json:"target-id"`
+	Diff       json.RawMessage `json:"diff"`
+	RequestID  string          `json:"request-id"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created-at"`
+	PrevHash   string          `json:"prev-hash"`
+	Hash       string          `json:"hash"`
+}
+
+// Filter narrows down a query of the log. Zero fields are not filtered on.
+type Filter struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   int
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+// ParseFilter reads a filter from the actor, action, target-type, target-id, from, to and
+// limit query parameters. From and to are RFC 3339 times. Without a limit the newest
+// 100 entries are returned.
+func ParseFilter(query url.Values) (*Filter, error) {
+	filter := &Filter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target-type"),
+		Limit:      defaultLimit,
+	}
+	ints := map[string]*int{"actor": &filter.ActorID, "target-id": &filter.TargetID, "limit": &filter.Limit}
+	for name, field := range ints {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return nil, fmt.Errorf("%s must be a positive number", name)
+		}
+		*field = parsed
+	}
+	if filter.Limit > maxLimit {
+		return nil, fmt.Errorf("limit cannot be more than %d", maxLimit)
+	}
+	times := map[string]*time.Time{"from": &filter.From, "to": &filter.To}
+	for name, field := range times {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 time", name)
+		}
+		*field = parsed.UTC()
+	}
+	return filter, nil
+}
+
+func CreateEntry(meta Meta, action, targetType string, targetID int, diff json.RawMessage, prevHash string, now time.Time) *Entry {
+	entry := &Entry{
+		ActorID:    meta.ActorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Diff:       diff,
+		RequestID:  meta.RequestID,
+		IP:         meta.IP,
+		CreatedAt:  now,
+		PrevHash:   prevHash,
+	}
+	entry.Hash = entry.ComputeHash()
+	return entry
+}
+
+// ComputeHash works out what the entry's hash should be from its contents.
+func (e *Entry) ComputeHash() string {
+	fields := []string{
+		e.PrevHash,
+		fmt.Sprint(e.ActorID),
+		e.Action,
+		e.TargetType,
+		fmt.Sprint(e.TargetID),
+		string(e.Diff),
+		e.RequestID,
+		e.IP,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks entries, oldest first, form an unbroken chain.
+func Verify(entries []*Entry) error {
+	prev := ""
+	for _, e := range entries {
+		if e.PrevHash != prev {
+			return fmt.Errorf("entry %d does not follow the entry before it", e.ID)
+		}
+		if e.ComputeHash() != e.Hash {
+			return fmt.Errorf("entry %d has been altered", e.ID)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Diff lists the fields that differ between before and after, as they are written to JSON.
+// Either can be nil, for something created or removed. Secret fields are redacted.
+func Diff(before, after interface{}) (json.RawMessage, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]Change{}
+	for name, value := range from {
+		other, ok := to[name]
+		switch {
+		case !ok:
+			changes[name] = Change{From: value}
+		case string(other) != string(value):
+			changes[name] = Change{From: value, To: other}
+		}
+	}
+	for name, value := range to {
+		if _, ok := from[name]; !ok {
+			changes[name] = Change{To: value}
+		}
+	}
+	for name, change := range changes {
+		if isSecret(name) {
+			changes[name] = redact(change)
+		}
+	}
+	return json.Marshal(changes)
+}
+
+// fields splits v, written as a JSON object, into its fields. Anything that is not an
+// object is kept whole under "value".
+func fields(v interface{}) (map[string]json.RawMessage, error) {
+	object := map[string]json.RawMessage{}
+	if v == nil {
+		return object, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(raw) == "null" {
+		return object, nil
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return map[string]json.RawMessage{"value": raw}, nil
+	}
+	return object, nil
+}
+
+func isSecret(name string) bool {
+	for _, secret := range secretFields {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(change Change) Change {
+	redacted := Change{}
+	if change.From != nil {
+		redacted.From = Redacted
+	}
+	if change.To != nil {
+		redacted.To = Redacted
+	}
+	return redacted
+}
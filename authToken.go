@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"net/http"
@@ -12,6 +13,8 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// withJWTAuth only lets the request through when the JWT belongs to the account in the path
+// or to one of its co-owners. Whoever it belongs to is made available through actorFrom.
 func withJWTAuth(handlerFunc http.HandlerFunc, s Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("calling JWT middleware...")
@@ -36,14 +39,66 @@ func withJWTAuth(handlerFunc http.HandlerFunc, s Storage) http.HandlerFunc {
 			return
 		}
 		claims := token.Claims.(jwt.MapClaims)
-		if account.BankNumber != int64(math.Round(claims["account-number"].(float64))) {
+		bankNumber, ok := claims["account-number"].(float64)
+		if !ok {
+			WriteJSON(w, http.StatusUnauthorized, apiError{Error: "Invalid JWT"})
+			return
+		}
+		actor := account
+		if account.BankNumber != int64(math.Round(bankNumber)) {
+			actor, err = s.GetAccountByBankNumber(int64(math.Round(bankNumber)))
+			if err != nil {
+				WriteJSON(w, http.StatusUnauthorized, apiError{Error: "Permission Denied"})
+				return
+			}
+			if owner, err := s.IsOwner(account.ID, actor.ID); err != nil || !owner {
+				WriteJSON(w, http.StatusUnauthorized, apiError{Error: "Permission Denied"})
+				return
+			}
+		}
+		handlerFunc(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	}
+}
+
+// actorKey is the request context key the authenticated account is stored under.
+type actorKey struct{}
+
+// withRoleAuth only lets the request through when the JWT belongs to an account holding one
+// of roles. The account is made available to the handler through actorFrom.
+func withRoleAuth(handlerFunc http.HandlerFunc, s Storage, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := validateJWT(r.Header.Get("x-jwt-token"))
+		if err != nil || !token.Valid {
+			WriteJSON(w, http.StatusUnauthorized, apiError{Error: "Invalid JWT"})
+			return
+		}
+		claims := token.Claims.(jwt.MapClaims)
+		bankNumber, ok := claims["account-number"].(float64)
+		if !ok {
+			WriteJSON(w, http.StatusUnauthorized, apiError{Error: "Invalid JWT"})
+			return
+		}
+		actor, err := s.GetAccountByBankNumber(int64(math.Round(bankNumber)))
+		if err != nil {
 			WriteJSON(w, http.StatusUnauthorized, apiError{Error: "Permission Denied"})
 			return
 		}
-		handlerFunc(w, r)
+		for _, role := range roles {
+			if actor.Role == role {
+				handlerFunc(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+				return
+			}
+		}
+		WriteJSON(w, http.StatusForbidden, apiError{Error: "Permission Denied"})
 	}
 }
 
+// actorFrom is the account withJWTAuth or withRoleAuth authenticated the request as.
+func actorFrom(r *http.Request) *account.Account {
+	actor, _ := r.Context().Value(actorKey{}).(*account.Account)
+	return actor
+}
+
 func validateJWT(tokenStr string) (*jwt.Token, error) {
 	secret := os.Getenv("jwtSecret")
 	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
@@ -0,0 +1,60 @@
+package beneficiary
+
+import (
+	"os"
+	"time"
+)
+
+// defaultCoolingOff is how long a newly saved payee waits before it can be paid
+// when payeeCoolingOff is not set.
+const defaultCoolingOff = 24 * time.Hour
+
+type CreateBeneficiaryRequest struct {
+	Nickname   string `json:"nickname"`
+	BankNumber int64  `json:"bank-number"`
+	Username   string `json:"username"`
+}
+
+type RenameBeneficiaryRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+type Beneficiary struct {
+	ID         int       `json:"id"`
+	AccountID  int       `json:"-"`
+	Nickname   string    `json:"nickname"`
+	BankNumber int64     `json:"bank-number"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created-at"`
+	ActiveFrom time.Time `json:"active-from"`
+	Active     bool      `json:"active"`
+}
+
+func CreateBeneficiary(accountID int, nickname string, bankNumber int64, name string) *Beneficiary {
+	now := time.Now().UTC()
+	beneficiary := &Beneficiary{
+		AccountID:  accountID,
+		Nickname:   nickname,
+		BankNumber: bankNumber,
+		Name:       name,
+		CreatedAt:  now,
+		ActiveFrom: now.Add(CoolingOff()),
+	}
+	beneficiary.Active = IsActive(beneficiary.ActiveFrom)
+	return beneficiary
+}
+
+// IsActive reports whether a payee that becomes active at activeFrom can be paid yet.
+func IsActive(activeFrom time.Time) bool {
+	return !time.Now().UTC().Before(activeFrom)
+}
+
+// CoolingOff reads the cooling-off period for new payees from the payeeCoolingOff
+// environment variable, e.g. "12h" or "30m".
+func CoolingOff() time.Duration {
+	coolingOff, err := time.ParseDuration(os.Getenv("payeeCoolingOff"))
+	if err != nil || coolingOff < 0 {
+		return defaultCoolingOff
+	}
+	return coolingOff
+}
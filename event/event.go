@@ -0,0 +1,383 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+// Types of event. Every event belongs to one account's stream. Each side of a movement of
+// money is its own event on the stream of the account whose balance it changed, so a
+// transfer between two accounts is a TransferSent on one and a TransferReceived on the other.
+const (
+	TypeAccountOpened         = "AccountOpened"
+	TypeNameChanged           = "NameChanged"
+	TypeUsernameChanged       = "UsernameChanged"
+	TypePasswordChanged       = "PasswordChanged"
+	TypeRoleChanged           = "RoleChanged"
+	TypeOverdraftLimitChanged = "OverdraftLimitChanged"
+	TypeStatusChanged         = "StatusChanged"
+	TypeDeposited             = "Deposited"
+	TypeWithdrawn             = "Withdrawn"
+	TypeTransferSent          = "TransferSent"
+	TypeTransferReceived      = "TransferReceived"
+	TypeFeeCharged            = "FeeCharged"
+	TypeFeeReceived           = "FeeReceived"
+	TypeInterestPaid          = "InterestPaid"
+	TypeInterestCharged       = "InterestCharged"
+	TypeReversalDebited       = "ReversalDebited"
+	TypeReversalCredited      = "ReversalCredited"
+	TypeTransferCategorised   = "TransferCategorised"
+	TypeTransferImported      = "TransferImported"
+)
+
+// How many events are read from the store at a time.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// legTypes is the event each side of a transfer is recorded as, by its action. Actions on
+// both sides of a movement between two accounts are keyed by their action alone, while those
+// where the account pays itself, such as cash deposits and interest, are keyed "self:".
+var legTypes = map[string]string{
+	"self:deposit":            TypeDeposited,
+	"self:withdrawal":         TypeWithdrawn,
+	"self:interest":           TypeInterestPaid,
+	"self:overdraft-interest": TypeInterestCharged,
+	"withdrawal":              TypeTransferSent,
+	"deposit":                 TypeTransferReceived,
+	"fee":                     TypeFeeCharged,
+	"fee-income":              TypeFeeReceived,
+	"reversal-debit":          TypeReversalDebited,
+	"reversal-credit":         TypeReversalCredited,
+}
+
+// Event is one change to an account or its ledger. ID orders every event in the store and
+// Version orders the events of one account.
+type Event struct {
+	ID         int             `json:"id"`
+	AccountID  int             `json:"account-id"`
+	Version    int             `json:"version"`
+	Type       string          `json:"type"`
+	Data       json.RawMessage `json:"data"`
+	OccurredAt time.Time       `json:"occurred-at"`
+}
+
+type NameChanged struct {
+	FirstName string `json:"first-name"`
+	LastName  string `json:"last-name"`
+}
+
+type UsernameChanged struct {
+	Username string `json:"username"`
+}
+
+// PasswordChanged records that the password was changed. Neither the password nor its hash
+// is kept in events, which are served to admins and replayed into read models.
+type PasswordChanged struct{}
+
+type RoleChanged struct {
+	Role string `json:"role"`
+}
+
+type OverdraftLimitChanged struct {
+	OverdraftLimit int64 `json:"overdraft-limit"`
+}
+
+type StatusChanged struct {
+	Status    string    `json:"status"`
+	ChangedAt time.Time `json:"changed-at"`
+}
+
+type TransferCategorised struct {
+	TransferID int    `json:"transfer-id"`
+	Category   string `json:"category"`
+}
+
+// Leg is one side of a transfer as it was written to the ledger. Unlike transfer.Transfer
+// it keeps the ids of both accounts.
+type Leg struct {
+	ID              int       `json:"id"`
+	From            int       `json:"from"`
+	To              int       `json:"to"`
+	FromBankNumber  int64     `json:"from-bank-number"`
+	ToBankNumber    int64     `json:"to-bank-number"`
+	Amount          int       `json:"amount"`
+	Action          string    `json:"action"`
+	PreviousBalance int64     `json:"previous-balance"`
+	CurrentBalance  int64     `json:"current-balance"`
+	CompletedAt     time.Time `json:"completed-at"`
+	Channel         string    `json:"channel"`
+	Reference       string    `json:"reference"`
+	Memo            string    `json:"memo"`
+	Category        string    `json:"category"`
+	ReversalOf      int       `json:"reversal-of"`
+	ReversedBy      int       `json:"reversed-by"`
+	CounterpartID   int       `json:"counterpart-id"`
+}
+
+func LegOf(t *transfer.Transfer) *Leg {
+	return &Leg{
+		ID:              t.ID,
+		From:            t.From,
+		To:              t.To,
+		FromBankNumber:  t.FromBankNumber,
+		ToBankNumber:    t.ToBankNumber,
+		Amount:          t.Amount,
+		Action:          t.Action,
+		PreviousBalance: t.PreviousBalance,
+		CurrentBalance:  t.CurrentBalance,
+		CompletedAt:     t.CompletedAt,
+		Channel:         t.Channel,
+		Reference:       t.Reference,
+		Memo:            t.Memo,
+		Category:        t.Category,
+		ReversalOf:      t.ReversalOf,
+		ReversedBy:      t.ReversedBy,
+		CounterpartID:   t.CounterpartID,
+	}
+}
+
+func (l *Leg) Transfer() *transfer.Transfer {
+	t := &transfer.Transfer{
+		ID:              l.ID,
+		From:            l.From,
+		To:              l.To,
+		FromBankNumber:  l.FromBankNumber,
+		ToBankNumber:    l.ToBankNumber,
+		Amount:          l.Amount,
+		Action:          l.Action,
+		PreviousBalance: l.PreviousBalance,
+		CurrentBalance:  l.CurrentBalance,
+		CompletedAt:     l.CompletedAt,
+		Channel:         l.Channel,
+		Reference:       l.Reference,
+		Memo:            l.Memo,
+		Category:        l.Category,
+		ReversalOf:      l.ReversalOf,
+		ReversedBy:      l.ReversedBy,
+		CounterpartID:   l.CounterpartID,
+	}
+	t.Status = transfer.StatusOf(t)
+	return t
+}
+
+func New(accountID int, eventType string, data interface{}, now time.Time) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{AccountID: accountID, Type: eventType, Data: raw, OccurredAt: now}, nil
+}
+
+// Opened is the event an account is opened with, carrying the whole account but its password.
+func Opened(acc *account.Account, now time.Time) (*Event, error) {
+	opened := *acc
+	opened.Password = ""
+	return New(acc.ID, TypeAccountOpened, &opened, now)
+}
+
+// ForTransfer is the event one side of a transfer is recorded as, on the stream of the
+// account whose balance it changed.
+func ForTransfer(t *transfer.Transfer) (*Event, error) {
+	key := t.Action
+	if t.From == t.To {
+		key = "self:" + t.Action
+	}
+	eventType, ok := legTypes[key]
+	if !ok {
+		return nil, fmt.Errorf("no event for a %s transfer", t.Action)
+	}
+	return New(Owner(t), eventType, LegOf(t), t.CompletedAt)
+}
+
+// Imported records a transfer written before events were kept. It adds the transfer to the
+// ledger without changing any balance.
+func Imported(t *transfer.Transfer, now time.Time) (*Event, error) {
+	return New(Owner(t), TypeTransferImported, LegOf(t), now)
+}
+
+// Owner is the account whose balance a side of a transfer changed: the sender for money
+// going out and the recipient for money coming in.
+func Owner(t *transfer.Transfer) int {
+	switch t.Action {
+	case "deposit", "fee-income", "reversal-credit":
+		return t.To
+	}
+	return t.From
+}
+
+// Changes are the events that take an account from before to after, for every field other
+// than its balance and status, which have events of their own.
+func Changes(before, after *account.Account, now time.Time) ([]*Event, error) {
+	changes := []struct {
+		changed   bool
+		eventType string
+		data      interface{}
+	}{
+		{before.FirstName != after.FirstName || before.LastName != after.LastName, TypeNameChanged, NameChanged{after.FirstName, after.LastName}},
+		{before.Username != after.Username, TypeUsernameChanged, UsernameChanged{after.Username}},
+		{before.Password != after.Password, TypePasswordChanged, PasswordChanged{}},
+		{before.Role != after.Role, TypeRoleChanged, RoleChanged{after.Role}},
+		{before.OverdraftLimit != after.OverdraftLimit, TypeOverdraftLimitChanged, OverdraftLimitChanged{after.OverdraftLimit}},
+	}
+	events := []*Event{}
+	for _, change := range changes {
+		if !change.changed {
+			continue
+		}
+		e, err := New(after.ID, change.eventType, change.data, now)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// IsLeg reports whether the event is one side of a transfer.
+func (e *Event) IsLeg() bool {
+	if e.Type == TypeTransferImported {
+		return true
+	}
+	for _, eventType := range legTypes {
+		if e.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Projection is the account and transfer tables as a run of events leaves them. Events are
+// applied oldest first. Passwords are never in events, so projected accounts have none.
+type Projection struct {
+	Accounts  map[int]*account.Account
+	Transfers map[int]*transfer.Transfer
+}
+
+func NewProjection() *Projection {
+	return &Projection{
+		Accounts:  map[int]*account.Account{},
+		Transfers: map[int]*transfer.Transfer{},
+	}
+}
+
+// Apply folds e into the projection.
+func (p *Projection) Apply(e *Event) error {
+	if e.Type == TypeAccountOpened {
+		acc := new(account.Account)
+		if err := json.Unmarshal(e.Data, acc); err != nil {
+			return err
+		}
+		p.Accounts[acc.ID] = acc
+		return nil
+	}
+	if e.IsLeg() {
+		return p.applyLeg(e)
+	}
+	if e.Type == TypeTransferCategorised {
+		data := TransferCategorised{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		if t, ok := p.Transfers[data.TransferID]; ok {
+			t.Category = data.Category
+		}
+		return nil
+	}
+	acc, ok := p.Accounts[e.AccountID]
+	if !ok {
+		return fmt.Errorf("event %d is for account %d, which has not been opened", e.ID, e.AccountID)
+	}
+	return applyToAccount(acc, e)
+}
+
+func (p *Projection) applyLeg(e *Event) error {
+	leg := new(Leg)
+	if err := json.Unmarshal(e.Data, leg); err != nil {
+		return err
+	}
+	t := leg.Transfer()
+	p.Transfers[t.ID] = t
+	if e.Type == TypeTransferImported {
+		return nil
+	}
+	// a reversal is linked back to the side of the transfer it undid
+	if reversed, ok := p.Transfers[t.ReversalOf]; ok && t.ReversalOf != 0 {
+		reversed.ReversedBy = t.ID
+		reversed.Status = transfer.StatusOf(reversed)
+	}
+	// the second side of a transfer is the first to know both ids, and links the first to it
+	if counterpart, ok := p.Transfers[t.CounterpartID]; ok && t.CounterpartID != 0 {
+		counterpart.CounterpartID = t.ID
+	}
+	if acc, ok := p.Accounts[e.AccountID]; ok {
+		acc.Balance = leg.CurrentBalance
+	}
+	return nil
+}
+
+func applyToAccount(acc *account.Account, e *Event) error {
+	switch e.Type {
+	case TypeNameChanged:
+		data := NameChanged{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		acc.FirstName, acc.LastName = data.FirstName, data.LastName
+	case TypeUsernameChanged:
+		data := UsernameChanged{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		acc.Username = data.Username
+	case TypePasswordChanged:
+		// there is no password to apply, projections keep the one in the account table
+	case TypeRoleChanged:
+		data := RoleChanged{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		acc.Role = data.Role
+	case TypeOverdraftLimitChanged:
+		data := OverdraftLimitChanged{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		acc.OverdraftLimit = data.OverdraftLimit
+	case TypeStatusChanged:
+		data := StatusChanged{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		acc.Status, acc.StatusChanged = data.Status, data.ChangedAt
+	default:
+		return fmt.Errorf("unknown event type %q", e.Type)
+	}
+	return nil
+}
+
+// AccountList is the projected accounts in id order.
+func (p *Projection) AccountList() []*account.Account {
+	accounts := []*account.Account{}
+	for _, acc := range p.Accounts {
+		accounts = append(accounts, acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts
+}
+
+// TransferList is the projected transfers in id order.
+func (p *Projection) TransferList() []*transfer.Transfer {
+	transfers := []*transfer.Transfer{}
+	for _, t := range p.Transfers {
+		transfers = append(transfers, t)
+	}
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].ID < transfers[j].ID })
+	return transfers
+}
@@ -0,0 +1,503 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/statement"
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+// Formats an account's transfers can be exported in: OFX 2.2 and QIF for accounting
+// software, and ISO 20022 camt.053 for treasury systems.
+const (
+	FormatOFX     = "ofx"
+	FormatQIF     = "qif"
+	FormatCamt053 = "camt053"
+)
+
+const (
+	defaultCurrency = "USD"
+	defaultBankID   = "BANKAPI"
+	// minorUnits is how many decimal places amounts are stored to, as they are all kept in
+	// the currency's smallest unit
+	minorUnits = 2
+	// camtNamespace is the version of camt.053 statements are written in
+	camtNamespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"
+	ofxTimeFormat = "20060102150405.000[0:GMT]"
+	qifDateFormat = "01/02/2006"
+)
+
+// Field lengths allowed by each format.
+const (
+	ofxMaxID   = 32
+	ofxMaxName = 32
+	ofxMaxMemo = 255
+	camtMax35  = 35
+	camtMax140 = 140
+	camtMax500 = 500
+)
+
+// Ledger is what every export is made from: an account's transfers over the days From to
+// To inclusive, as GetMyTransfers returns them, and its balance either side of them.
+type Ledger struct {
+	Account        *account.Account
+	From           time.Time
+	To             time.Time
+	OpeningBalance int64
+	ClosingBalance int64
+	Transfers      []*transfer.Transfer
+	GeneratedAt    time.Time
+}
+
+// NewLedger puts the transfers in the order they were booked. The closing balance is the
+// balance after the last of them.
+func NewLedger(acc *account.Account, from, to time.Time, opening int64, transfers []*transfer.Transfer, now time.Time) *Ledger {
+	sorted := append([]*transfer.Transfer{}, transfers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CompletedAt.Equal(sorted[j].CompletedAt) {
+			return sorted[i].CompletedAt.Before(sorted[j].CompletedAt)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	closing := opening
+	if len(sorted) > 0 {
+		closing = sorted[len(sorted)-1].CurrentBalance
+	}
+	return &Ledger{
+		Account:        acc,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: closing,
+		Transfers:      sorted,
+		GeneratedAt:    now,
+	}
+}
+
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatOFX, FormatQIF, FormatCamt053:
+		return nil
+	}
+	return fmt.Errorf("invalid format %q : must be %s, %s or %s", format, FormatOFX, FormatQIF, FormatCamt053)
+}
+
+// Write exports the ledger in format.
+func Write(w io.Writer, format string, l *Ledger) error {
+	var doc []byte
+	var err error
+	switch format {
+	case FormatOFX:
+		doc, err = OFX(l)
+	case FormatQIF:
+		doc, err = QIF(l)
+	case FormatCamt053:
+		doc, err = Camt053(l)
+	default:
+		err = ValidateFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(doc)
+	return err
+}
+
+// ContentType is the media type of an export in format.
+func ContentType(format string) string {
+	switch format {
+	case FormatOFX:
+		return "application/x-ofx"
+	case FormatCamt053:
+		return "application/xml"
+	}
+	return "application/qif"
+}
+
+// Filename is what an export is saved as, e.g. transfers-12345678-2024-01-01-2024-01-31.ofx.
+func Filename(bankNumber int64, from, to time.Time, format string) string {
+	extension := format
+	if format == FormatCamt053 {
+		extension = "xml"
+	}
+	return fmt.Sprintf("transfers-%d-%s-%s.%s", bankNumber, from.Format(statement.DayFormat), to.Format(statement.DayFormat), extension)
+}
+
+// Change is how much a transfer moved its account's balance: positive for money in and
+// negative for money out.
+func Change(t *transfer.Transfer) int64 {
+	return t.CurrentBalance - t.PreviousBalance
+}
+
+//
+// OFX
+//
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FITID  string `xml:"FITID"`
+	RefNum string `xml:"REFNUM,omitempty"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Response struct {
+			Status   ofxStatus `xml:"STATUS"`
+			Server   string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		Transaction struct {
+			ID        string    `xml:"TRNUID"`
+			Status    ofxStatus `xml:"STATUS"`
+			Statement struct {
+				Currency string `xml:"CURDEF"`
+				Account  struct {
+					BankID string `xml:"BANKID"`
+					ID     string `xml:"ACCTID"`
+					Type   string `xml:"ACCTTYPE"`
+				} `xml:"BANKACCTFROM"`
+				List struct {
+					Start        string            `xml:"DTSTART"`
+					End          string            `xml:"DTEND"`
+					Transactions []*ofxTransaction `xml:"STMTTRN"`
+				} `xml:"BANKTRANLIST"`
+				Ledger struct {
+					Amount string `xml:"BALAMT"`
+					AsOf   string `xml:"DTASOF"`
+				} `xml:"LEDGERBAL"`
+			} `xml:"STMTRS"`
+		} `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+// OFX exports the ledger as an OFX 2.2 bank statement download.
+func OFX(l *Ledger) ([]byte, error) {
+	doc := &ofxDocument{}
+	doc.SignOn.Response.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	doc.SignOn.Response.Server = l.GeneratedAt.UTC().Format(ofxTimeFormat)
+	doc.SignOn.Response.Language = "ENG"
+	rs := &doc.Bank.Transaction
+	rs.ID = "0"
+	rs.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	rs.Statement.Currency = Currency()
+	rs.Statement.Account.BankID = BankID()
+	rs.Statement.Account.ID = strconv.FormatInt(l.Account.BankNumber, 10)
+	rs.Statement.Account.Type = "CHECKING"
+	if l.Account.Type == account.TypeSavings {
+		rs.Statement.Account.Type = "SAVINGS"
+	}
+	rs.Statement.List.Start = l.From.UTC().Format(ofxTimeFormat)
+	rs.Statement.List.End = statement.End(l.To).UTC().Format(ofxTimeFormat)
+	rs.Statement.List.Transactions = []*ofxTransaction{}
+	for _, t := range l.Transfers {
+		memo := strings.TrimSpace(strings.Join([]string{t.Reference, t.Memo}, " "))
+		rs.Statement.List.Transactions = append(rs.Statement.List.Transactions, &ofxTransaction{
+			Type:   ofxType(t),
+			Posted: t.CompletedAt.UTC().Format(ofxTimeFormat),
+			Amount: Decimal(Change(t)),
+			FITID:  strconv.Itoa(t.ID),
+			RefNum: truncate(t.Reference, ofxMaxID),
+			Name:   truncate(statement.Describe(t), ofxMaxName),
+			Memo:   truncate(oneLine(memo), ofxMaxMemo),
+		})
+	}
+	rs.Statement.Ledger.Amount = Decimal(l.ClosingBalance)
+	rs.Statement.Ledger.AsOf = statement.End(l.To).UTC().Format(ofxTimeFormat)
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	out.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	out.Write(body)
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
+
+// ofxType is the OFX transaction type closest to what the transfer was.
+func ofxType(t *transfer.Transfer) string {
+	switch {
+	case t.From == t.To && t.Action == "deposit":
+		return "DEP"
+	case t.From == t.To && t.Action == "withdrawal" && t.Channel == transfer.ChannelCash:
+		return "CASH"
+	case t.Action == "interest", t.Action == "overdraft-interest":
+		return "INT"
+	case t.Action == "fee":
+		return "FEE"
+	case t.From != t.To && (t.Action == "withdrawal" || t.Action == "deposit"):
+		return "XFER"
+	case Change(t) < 0:
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+//
+// QIF
+//
+
+type qifRecord struct {
+	date    time.Time
+	amount  int64
+	number  string
+	payee   string
+	memo    string
+	cleared string
+}
+
+// QIF exports the ledger in Quicken Interchange Format as a bank account register.
+func QIF(l *Ledger) ([]byte, error) {
+	records := []*qifRecord{}
+	for _, t := range l.Transfers {
+		memo := strings.TrimSpace(strings.Join([]string{t.Reference, t.Memo}, " "))
+		records = append(records, &qifRecord{
+			date:    t.CompletedAt.UTC(),
+			amount:  Change(t),
+			number:  strconv.Itoa(t.ID),
+			payee:   oneLine(statement.Describe(t)),
+			memo:    oneLine(memo),
+			cleared: "X",
+		})
+	}
+	var out bytes.Buffer
+	out.WriteString("!Type:Bank\n")
+	for _, r := range records {
+		fmt.Fprintf(&out, "D%s\n", r.date.Format(qifDateFormat))
+		fmt.Fprintf(&out, "T%s\n", Decimal(r.amount))
+		fmt.Fprintf(&out, "C%s\n", r.cleared)
+		fmt.Fprintf(&out, "N%s\n", r.number)
+		fmt.Fprintf(&out, "P%s\n", r.payee)
+		if r.memo != "" {
+			fmt.Fprintf(&out, "M%s\n", r.memo)
+		}
+		out.WriteString("^\n")
+	}
+	return out.Bytes(), nil
+}
+
+//
+// camt.053
+//
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+type camtTotals struct {
+	Count string `xml:"NbOfNtries"`
+	Sum   string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	Reference      string     `xml:"NtryRef"`
+	Amount         camtAmount `xml:"Amt"`
+	Indicator      string     `xml:"CdtDbtInd"`
+	Reversal       bool       `xml:"RvslInd,omitempty"`
+	Status         string     `xml:"Sts>Cd"`
+	BookingDate    string     `xml:"BookgDt>DtTm"`
+	ValueDate      string     `xml:"ValDt>DtTm"`
+	ServicerRef    string     `xml:"AcctSvcrRef"`
+	BankCode       string     `xml:"BkTxCd>Prtry>Cd"`
+	BankCodeIssuer string     `xml:"BkTxCd>Prtry>Issr"`
+	EndToEndID     string     `xml:"NtryDtls>TxDtls>Refs>EndToEndId,omitempty"`
+	Unstructured   string     `xml:"NtryDtls>TxDtls>RmtInf>Ustrd,omitempty"`
+	AdditionalInfo string     `xml:"AddtlNtryInf,omitempty"`
+}
+
+type camtStatement struct {
+	ID        string `xml:"Id"`
+	CreatedAt string `xml:"CreDtTm"`
+	From      string `xml:"FrToDt>FrDtTm"`
+	To        string `xml:"FrToDt>ToDtTm"`
+	Account   struct {
+		ID       string `xml:"Id>Othr>Id"`
+		Currency string `xml:"Ccy"`
+		Owner    string `xml:"Ownr>Nm,omitempty"`
+	} `xml:"Acct"`
+	Balances []*camtBalance `xml:"Bal"`
+	Summary  struct {
+		Total struct {
+			Count     string `xml:"NbOfNtries"`
+			Sum       string `xml:"Sum"`
+			Net       string `xml:"TtlNetNtry>Amt"`
+			Indicator string `xml:"TtlNetNtry>CdtDbtInd"`
+		} `xml:"TtlNtries"`
+		Credits camtTotals `xml:"TtlCdtNtries"`
+		Debits  camtTotals `xml:"TtlDbtNtries"`
+	} `xml:"TxsSummry"`
+	Entries []*camtEntry `xml:"Ntry"`
+}
+
+type camtDocument struct {
+	XMLName   xml.Name `xml:"Document"`
+	Namespace string   `xml:"xmlns,attr"`
+	Header    struct {
+		MessageID string `xml:"MsgId"`
+		CreatedAt string `xml:"CreDtTm"`
+	} `xml:"BkToCstmrStmt>GrpHdr"`
+	Statement *camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+// Camt053 exports the ledger as an ISO 20022 camt.053 bank to customer statement, with an
+// opening and closing booked balance and an entry for every transfer.
+func Camt053(l *Ledger) ([]byte, error) {
+	currency := Currency()
+	created := l.GeneratedAt.UTC().Format(time.RFC3339)
+	doc := &camtDocument{Namespace: camtNamespace}
+	doc.Header.MessageID = fmt.Sprintf("STMT-%d-%s", l.Account.BankNumber, l.GeneratedAt.UTC().Format("20060102150405"))
+	doc.Header.CreatedAt = created
+	st := &camtStatement{
+		ID:        fmt.Sprintf("%d-%s-%s", l.Account.BankNumber, l.From.Format("20060102"), l.To.Format("20060102")),
+		CreatedAt: created,
+		From:      l.From.UTC().Format(time.RFC3339),
+		To:        statement.End(l.To).Add(-time.Second).UTC().Format(time.RFC3339),
+		Entries:   []*camtEntry{},
+	}
+	st.Account.ID = strconv.FormatInt(l.Account.BankNumber, 10)
+	st.Account.Currency = currency
+	st.Account.Owner = truncate(strings.TrimSpace(l.Account.FirstName+" "+l.Account.LastName), camtMax140)
+	st.Balances = []*camtBalance{
+		camtBalanceOf("OPBD", l.OpeningBalance, l.From, currency),
+		camtBalanceOf("CLBD", l.ClosingBalance, l.To, currency),
+	}
+	var credits, debits, creditSum, debitSum int64
+	for _, t := range l.Transfers {
+		change := Change(t)
+		entry := &camtEntry{
+			Reference:      strconv.Itoa(t.ID),
+			Amount:         camtAmount{Currency: currency, Value: Decimal(abs(change))},
+			Indicator:      indicator(change),
+			Reversal:       t.Action == "reversal-debit" || t.Action == "reversal-credit",
+			Status:         "BOOK",
+			BookingDate:    t.CompletedAt.UTC().Format(time.RFC3339),
+			ValueDate:      t.CompletedAt.UTC().Format(time.RFC3339),
+			ServicerRef:    strconv.Itoa(t.ID),
+			BankCode:       t.Action,
+			BankCodeIssuer: BankID(),
+			EndToEndID:     truncate(t.Reference, camtMax35),
+			Unstructured:   truncate(oneLine(t.Memo), camtMax140),
+			AdditionalInfo: truncate(statement.Describe(t), camtMax500),
+		}
+		if change < 0 {
+			debits++
+			debitSum -= change
+		} else {
+			credits++
+			creditSum += change
+		}
+		st.Entries = append(st.Entries, entry)
+	}
+	st.Summary.Total.Count = strconv.Itoa(len(st.Entries))
+	st.Summary.Total.Sum = Decimal(creditSum + debitSum)
+	st.Summary.Total.Net = Decimal(abs(creditSum - debitSum))
+	st.Summary.Total.Indicator = indicator(creditSum - debitSum)
+	st.Summary.Credits = camtTotals{Count: strconv.FormatInt(credits, 10), Sum: Decimal(creditSum)}
+	st.Summary.Debits = camtTotals{Count: strconv.FormatInt(debits, 10), Sum: Decimal(debitSum)}
+	doc.Statement = st
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+func camtBalanceOf(balanceType string, balance int64, day time.Time, currency string) *camtBalance {
+	return &camtBalance{
+		Type:      balanceType,
+		Amount:    camtAmount{Currency: currency, Value: Decimal(abs(balance))},
+		Indicator: indicator(balance),
+		Date:      day.Format(statement.DayFormat),
+	}
+}
+
+//
+// Formatting
+//
+
+// Decimal writes an amount held in minor units, e.g. -1234 as -12.34.
+func Decimal(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	scale := int64(1)
+	for i := 0; i < minorUnits; i++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/scale, minorUnits, minor%scale)
+}
+
+func indicator(amount int64) string {
+	if amount < 0 {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+func abs(amount int64) int64 {
+	if amount < 0 {
+		return -amount
+	}
+	return amount
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+// Currency is the ISO 4217 code of the currency accounts are held in, read from the currency
+// environment variable.
+func Currency() string {
+	if currency := os.Getenv("currency"); currency != "" {
+		return currency
+	}
+	return defaultCurrency
+}
+
+// BankID identifies the bank in exports, read from the exportBankID environment variable.
+// OFX allows up to nine characters.
+func BankID() string {
+	if id := os.Getenv("exportBankID"); id != "" {
+		return id
+	}
+	return defaultBankID
+}
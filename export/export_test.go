@@ -0,0 +1,466 @@
+package export
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+var (
+	from = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to   = time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// movement is one transfer on account 3, with other the account on its other side.
+type movement struct {
+	action    string
+	other     int
+	change    int64
+	channel   string
+	reference string
+	memo      string
+}
+
+// ledger books the movements one hour apart on account 3, starting from opening.
+func ledger(opening int64, movements ...movement) *Ledger {
+	acc := &account.Account{ID: 3, FirstName: "Bob", LastName: "Brown", BankNumber: 27222287}
+	transfers := []*transfer.Transfer{}
+	balance := opening
+	for i, m := range movements {
+		t := &transfer.Transfer{
+			ID:              i + 1,
+			From:            acc.ID,
+			To:              m.other,
+			FromBankNumber:  acc.BankNumber,
+			ToBankNumber:    int64(27222200 + m.other),
+			Amount:          int(abs(m.change)),
+			Action:          m.action,
+			PreviousBalance: balance,
+			CurrentBalance:  balance + m.change,
+			CompletedAt:     from.Add(time.Duration(i+1) * time.Hour),
+			Channel:         m.channel,
+			Reference:       m.reference,
+			Memo:            m.memo,
+		}
+		if m.change > 0 && m.other != acc.ID {
+			t.From, t.To = m.other, acc.ID
+			t.FromBankNumber, t.ToBankNumber = t.ToBankNumber, acc.BankNumber
+		}
+		transfers = append(transfers, t)
+		balance += m.change
+	}
+	return NewLedger(acc, from, to, opening, transfers, to.Add(24*time.Hour))
+}
+
+var ledgers = []struct {
+	name   string
+	ledger *Ledger
+}{
+	{"no transfers", ledger(1000)},
+	{"every kind of transfer", ledger(1000,
+		movement{"deposit", 3, 5000, transfer.ChannelCash, "PAY-1", ""},
+		movement{"withdrawal", 3, -1200, transfer.ChannelCard, "ATM 12", ""},
+		movement{"withdrawal", 4, -2500, "", "rent march", "flat 2\nthird floor"},
+		// booked before references were limited to 35 characters
+		movement{"deposit", 4, 700, "", strings.Repeat("ref ", 12), strings.Repeat("memo ", 40)},
+		movement{"fee", 3, -150, "", "", ""},
+		movement{"interest", 3, 12, "", "", ""},
+		movement{"reversal-credit", 4, 2500, "", "rent march", ""},
+	)},
+	{"overdrawn", ledger(-500,
+		movement{"withdrawal", 4, -1000, "", "loan", ""},
+		movement{"overdraft-interest", 3, -25, "", "", ""},
+	)},
+}
+
+var amountPattern = regexp.MustCompile(`^-?\d+\.\d{2}$`)
+
+// minor reads an amount written by Decimal back into minor units.
+func minor(t *testing.T, amount string) int64 {
+	t.Helper()
+	if !amountPattern.MatchString(amount) {
+		t.Fatalf("amount %q is not a decimal with two places", amount)
+	}
+	value, err := strconv.ParseInt(strings.Replace(amount, ".", "", 1), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// node is any XML element, so a document can be walked whatever its schema.
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []*node    `xml:",any"`
+}
+
+func parse(t *testing.T, doc []byte) *node {
+	t.Helper()
+	root := &node{}
+	if err := xml.Unmarshal(doc, root); err != nil {
+		t.Fatalf("export is not well formed XML : %v", err)
+	}
+	return root
+}
+
+// get is the first element at the slash separated path below n, or nil.
+func (n *node) get(path string) *node {
+	current := n
+	for _, name := range strings.Split(path, "/") {
+		var next *node
+		for _, child := range current.Children {
+			if child.XMLName.Local == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+func (n *node) text(path string) string {
+	if found := n.get(path); found != nil {
+		return strings.TrimSpace(found.Text)
+	}
+	return ""
+}
+
+func (n *node) all(name string) []*node {
+	found := []*node{}
+	for _, child := range n.Children {
+		if child.XMLName.Local == name {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// ofxTypes are the values of TRNTYPE in the OFX 2.2 specification.
+var ofxTypes = map[string]bool{
+	"CREDIT": true, "DEBIT": true, "INT": true, "DIV": true, "FEE": true, "SRVCHG": true,
+	"DEP": true, "ATM": true, "POS": true, "XFER": true, "CHECK": true, "PAYMENT": true,
+	"CASH": true, "DIRECTDEP": true, "DIRECTDEBIT": true, "REPEATPMT": true, "HOLD": true, "OTHER": true,
+}
+
+func TestOFX(t *testing.T) {
+	for _, test := range ledgers {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := OFX(test.ledger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.SplitN(string(out), "\n", 3)
+			if len(lines) < 3 || !strings.HasPrefix(lines[0], `<?xml version="1.0"`) {
+				t.Fatalf("export does not start with an XML declaration")
+			}
+			for _, attr := range []string{`OFXHEADER="200"`, `VERSION="220"`, `SECURITY="NONE"`, `OLDFILEUID="NONE"`, `NEWFILEUID="NONE"`} {
+				if !strings.HasPrefix(lines[1], "<?OFX ") || !strings.Contains(lines[1], attr) {
+					t.Errorf("OFX header %q is missing %s", lines[1], attr)
+				}
+			}
+
+			doc := parse(t, out)
+			if doc.XMLName.Local != "OFX" {
+				t.Fatalf("root element is %s, want OFX", doc.XMLName.Local)
+			}
+			required := []string{
+				"SIGNONMSGSRSV1/SONRS/STATUS/CODE",
+				"SIGNONMSGSRSV1/SONRS/STATUS/SEVERITY",
+				"SIGNONMSGSRSV1/SONRS/DTSERVER",
+				"SIGNONMSGSRSV1/SONRS/LANGUAGE",
+				"BANKMSGSRSV1/STMTTRNRS/TRNUID",
+				"BANKMSGSRSV1/STMTTRNRS/STATUS/CODE",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/CURDEF",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/BANKACCTFROM/BANKID",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/BANKACCTFROM/ACCTID",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/BANKACCTFROM/ACCTTYPE",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/BANKTRANLIST/DTSTART",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/BANKTRANLIST/DTEND",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/LEDGERBAL/BALAMT",
+				"BANKMSGSRSV1/STMTTRNRS/STMTRS/LEDGERBAL/DTASOF",
+			}
+			for _, path := range required {
+				if doc.text(path) == "" {
+					t.Errorf("%s is missing", path)
+				}
+			}
+			rs := doc.get("BANKMSGSRSV1/STMTTRNRS/STMTRS")
+			if rs == nil {
+				t.Fatalf("STMTRS is missing")
+			}
+			if id := rs.text("BANKACCTFROM/BANKID"); len(id) > 9 {
+				t.Errorf("BANKID %q is longer than 9 characters", id)
+			}
+			if id := rs.text("BANKACCTFROM/ACCTID"); len(id) > 22 {
+				t.Errorf("ACCTID %q is longer than 22 characters", id)
+			}
+			start, _ := time.Parse(ofxTimeFormat, rs.text("BANKTRANLIST/DTSTART"))
+			end, _ := time.Parse(ofxTimeFormat, rs.text("BANKTRANLIST/DTEND"))
+			if !start.Before(end) {
+				t.Errorf("DTSTART %v is not before DTEND %v", start, end)
+			}
+
+			transactions := rs.get("BANKTRANLIST").all("STMTTRN")
+			if len(transactions) != len(test.ledger.Transfers) {
+				t.Fatalf("got %d transactions, want %d", len(transactions), len(test.ledger.Transfers))
+			}
+			fitids := map[string]bool{}
+			net := int64(0)
+			for _, trn := range transactions {
+				fitid := trn.text("FITID")
+				if fitid == "" || len(fitid) > 255 || fitids[fitid] {
+					t.Errorf("FITID %q is empty, too long or used twice", fitid)
+				}
+				fitids[fitid] = true
+				if !ofxTypes[trn.text("TRNTYPE")] {
+					t.Errorf("transaction %s has TRNTYPE %q, which OFX does not define", fitid, trn.text("TRNTYPE"))
+				}
+				if posted, err := time.Parse(ofxTimeFormat, trn.text("DTPOSTED")); err != nil || posted.Before(start) || !posted.Before(end) {
+					t.Errorf("transaction %s was posted at %q, outside the statement", fitid, trn.text("DTPOSTED"))
+				}
+				if length(trn.text("REFNUM")) > ofxMaxID || length(trn.text("NAME")) > ofxMaxName || length(trn.text("MEMO")) > ofxMaxMemo {
+					t.Errorf("transaction %s has a field longer than OFX allows", fitid)
+				}
+				net += minor(t, trn.text("TRNAMT"))
+			}
+			if closing := minor(t, rs.text("LEDGERBAL/BALAMT")); test.ledger.OpeningBalance+net != closing {
+				t.Errorf("transactions add up to %s but the balance moved by %s", Decimal(net), Decimal(closing-test.ledger.OpeningBalance))
+			}
+		})
+	}
+}
+
+func TestQIF(t *testing.T) {
+	for _, test := range ledgers {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := QIF(test.ledger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := string(out)
+			if !strings.HasPrefix(doc, "!Type:Bank\n") {
+				t.Fatalf("export does not start with !Type:Bank")
+			}
+			doc = strings.TrimPrefix(doc, "!Type:Bank\n")
+			if doc != "" && !strings.HasSuffix(doc, "^\n") {
+				t.Fatalf("the last record is not terminated by ^")
+			}
+			records := strings.Split(doc, "^\n")
+			records = records[:len(records)-1]
+			if len(records) != len(test.ledger.Transfers) {
+				t.Fatalf("got %d records, want %d", len(records), len(test.ledger.Transfers))
+			}
+			net := int64(0)
+			for i, record := range records {
+				fields := map[byte]int{}
+				for _, line := range strings.Split(strings.TrimSuffix(record, "\n"), "\n") {
+					if line == "" || !strings.ContainsRune("DTCNPM", rune(line[0])) {
+						t.Errorf("record %d has a line %q that is not a field", i+1, line)
+						continue
+					}
+					fields[line[0]]++
+					switch line[0] {
+					case 'D':
+						if _, err := time.Parse(qifDateFormat, line[1:]); err != nil {
+							t.Errorf("record %d has date %q : %v", i+1, line[1:], err)
+						}
+					case 'T':
+						net += minor(t, line[1:])
+					case 'C':
+						if !strings.Contains("*cXR", line[1:]) {
+							t.Errorf("record %d has cleared status %q", i+1, line[1:])
+						}
+					}
+				}
+				if fields['D'] != 1 || fields['T'] != 1 {
+					t.Errorf("record %d has %d dates and %d amounts, want one of each", i+1, fields['D'], fields['T'])
+				}
+			}
+			if moved := test.ledger.ClosingBalance - test.ledger.OpeningBalance; net != moved {
+				t.Errorf("records add up to %s but the balance moved by %s", Decimal(net), Decimal(moved))
+			}
+		})
+	}
+}
+
+// camtSchema is the order camt.053.001.08 puts each element's children in, by the element's
+// path below Document. Only the elements an export can contain are listed, each with every
+// child the schema allows, used or not.
+var camtSchema = map[string][]string{
+	"":                                                  {"BkToCstmrStmt"},
+	"BkToCstmrStmt":                                     {"GrpHdr", "Stmt", "SplmtryData"},
+	"BkToCstmrStmt/GrpHdr":                              {"MsgId", "CreDtTm", "MsgRcpt", "MsgPgntn", "OrgnlBizQry", "AddtlInf"},
+	"BkToCstmrStmt/Stmt":                                {"Id", "StmtPgntn", "ElctrncSeqNb", "RptgSeq", "LglSeqNb", "CreDtTm", "FrToDt", "CpyDplctInd", "RptgSrc", "Acct", "RltdAcct", "Intrst", "Bal", "TxsSummry", "Ntry", "AddtlStmtInf"},
+	"BkToCstmrStmt/Stmt/FrToDt":                         {"FrDtTm", "ToDtTm"},
+	"BkToCstmrStmt/Stmt/Acct":                           {"Id", "Tp", "Ccy", "Nm", "Prxy", "Ownr", "Svcr"},
+	"BkToCstmrStmt/Stmt/Acct/Id":                        {"IBAN", "Othr"},
+	"BkToCstmrStmt/Stmt/Acct/Id/Othr":                   {"Id", "SchmeNm", "Issr"},
+	"BkToCstmrStmt/Stmt/Acct/Ownr":                      {"Nm", "PstlAdr", "Id", "CtryOfRes", "CtctDtls"},
+	"BkToCstmrStmt/Stmt/Bal":                            {"Tp", "CdtLine", "Amt", "CdtDbtInd", "Dt", "Avlbty"},
+	"BkToCstmrStmt/Stmt/Bal/Tp":                         {"CdOrPrtry", "SubTp"},
+	"BkToCstmrStmt/Stmt/Bal/Tp/CdOrPrtry":               {"Cd", "Prtry"},
+	"BkToCstmrStmt/Stmt/Bal/Dt":                         {"Dt", "DtTm"},
+	"BkToCstmrStmt/Stmt/TxsSummry":                      {"TtlNtries", "TtlCdtNtries", "TtlDbtNtries", "TtlNtriesPerBkTxCd"},
+	"BkToCstmrStmt/Stmt/TxsSummry/TtlNtries":            {"NbOfNtries", "Sum", "TtlNetNtry"},
+	"BkToCstmrStmt/Stmt/TxsSummry/TtlNtries/TtlNetNtry": {"Amt", "CdtDbtInd"},
+	"BkToCstmrStmt/Stmt/TxsSummry/TtlCdtNtries":         {"NbOfNtries", "Sum"},
+	"BkToCstmrStmt/Stmt/TxsSummry/TtlDbtNtries":         {"NbOfNtries", "Sum"},
+	"BkToCstmrStmt/Stmt/Ntry": {"NtryRef", "Amt", "CdtDbtInd", "RvslInd", "Sts", "BookgDt", "ValDt", "AcctSvcrRef", "Avlbty", "BkTxCd",
+		"ComssnWvrInd", "AddtlInfInd", "AmtDtls", "Chrgs", "TechInptChanl", "Intrst", "CardTx", "NtryDtls", "AddtlNtryInf"},
+	"BkToCstmrStmt/Stmt/Ntry/Sts":          {"Cd", "Prtry"},
+	"BkToCstmrStmt/Stmt/Ntry/BookgDt":      {"Dt", "DtTm"},
+	"BkToCstmrStmt/Stmt/Ntry/ValDt":        {"Dt", "DtTm"},
+	"BkToCstmrStmt/Stmt/Ntry/BkTxCd":       {"Domn", "Prtry"},
+	"BkToCstmrStmt/Stmt/Ntry/BkTxCd/Prtry": {"Cd", "Issr"},
+	"BkToCstmrStmt/Stmt/Ntry/NtryDtls":     {"Btch", "TxDtls"},
+	"BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls": {"Refs", "Amt", "CdtDbtInd", "AmtDtls", "Avlbty", "BkTxCd", "Chrgs", "Intrst", "RltdPties", "RltdAgts",
+		"LclInstrm", "Purp", "RltdRmtInf", "RmtInf", "RltdDts", "RltdPric", "RltdQties", "FinInstrmId", "Tax", "RtrInf", "CorpActn", "SfkpgAcct",
+		"CshDpst", "CardTx", "AddtlTxInf", "SplmtryData"},
+	"BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/Refs": {"MsgId", "AcctSvcrRef", "PmtInfId", "InstrId", "EndToEndId", "UETR", "TxId", "MndtId", "ChqNb",
+		"ClrSysRef", "AcctOwnrTxId", "AcctSvcrTxId", "MktInfrstrctrTxId", "PrcgId", "Prtry"},
+	"BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RmtInf": {"Ustrd", "Strd"},
+}
+
+// checkOrder fails unless the children of n and every element below it are ones the schema
+// allows, in the order it lists them.
+func checkOrder(t *testing.T, n *node, path string) {
+	t.Helper()
+	if len(n.Children) == 0 {
+		return
+	}
+	order, ok := camtSchema[path]
+	if !ok {
+		t.Errorf("%s has children the test does not know the order of", path)
+		return
+	}
+	last := 0
+	for _, child := range n.Children {
+		name := child.XMLName.Local
+		position := -1
+		for i, allowed := range order {
+			if allowed == name {
+				position = i
+			}
+		}
+		switch {
+		case position < 0:
+			t.Errorf("%s cannot contain %s", path, name)
+		case position < last:
+			t.Errorf("%s has %s after %s", path, name, order[last])
+		default:
+			last = position
+		}
+		checkOrder(t, child, strings.TrimPrefix(path+"/"+name, "/"))
+	}
+}
+
+func TestCamt053(t *testing.T) {
+	for _, test := range ledgers {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := Camt053(test.ledger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := parse(t, out)
+			if doc.XMLName.Local != "Document" || doc.XMLName.Space != camtNamespace {
+				t.Fatalf("root element is %s in %q, want Document in %s", doc.XMLName.Local, doc.XMLName.Space, camtNamespace)
+			}
+			checkOrder(t, doc, "")
+
+			st := doc.get("BkToCstmrStmt/Stmt")
+			if st == nil {
+				t.Fatalf("Stmt is missing")
+			}
+			for _, id := range []string{doc.text("BkToCstmrStmt/GrpHdr/MsgId"), st.text("Id")} {
+				if id == "" || length(id) > camtMax35 {
+					t.Errorf("id %q must be 1 to %d characters", id, camtMax35)
+				}
+			}
+			signed := func(n *node, amount string) int64 {
+				value := minor(t, n.text(amount))
+				switch n.text("CdtDbtInd") {
+				case "CRDT":
+					return value
+				case "DBIT":
+					return -value
+				}
+				t.Errorf("%s has CdtDbtInd %q", n.XMLName.Local, n.text("CdtDbtInd"))
+				return 0
+			}
+			balances := map[string]int64{}
+			for _, bal := range st.all("Bal") {
+				balances[bal.text("Tp/CdOrPrtry/Cd")] = signed(bal, "Amt")
+			}
+			if balances["OPBD"] != test.ledger.OpeningBalance || balances["CLBD"] != test.ledger.ClosingBalance {
+				t.Errorf("balances = %v, want OPBD %d and CLBD %d", balances, test.ledger.OpeningBalance, test.ledger.ClosingBalance)
+			}
+
+			var net, credits, debits, creditSum, debitSum int64
+			entries := st.all("Ntry")
+			for _, ntry := range entries {
+				for _, path := range []string{"NtryRef", "AcctSvcrRef", "BkTxCd/Prtry/Cd", "NtryDtls/TxDtls/Refs/EndToEndId"} {
+					if length(ntry.text(path)) > camtMax35 {
+						t.Errorf("entry %s has a %s longer than %d characters", ntry.text("NtryRef"), path, camtMax35)
+					}
+				}
+				if length(ntry.text("NtryDtls/TxDtls/RmtInf/Ustrd")) > camtMax140 || length(ntry.text("AddtlNtryInf")) > camtMax500 {
+					t.Errorf("entry %s has remittance information longer than allowed", ntry.text("NtryRef"))
+				}
+				change := signed(ntry, "Amt")
+				net += change
+				if change < 0 {
+					debits++
+					debitSum -= change
+				} else {
+					credits++
+					creditSum += change
+				}
+			}
+			if balances["OPBD"]+net != balances["CLBD"] {
+				t.Errorf("OPBD %s and entries of %s do not make CLBD %s", Decimal(balances["OPBD"]), Decimal(net), Decimal(balances["CLBD"]))
+			}
+
+			summary := st.get("TxsSummry")
+			if summary == nil {
+				t.Fatalf("TxsSummry is missing")
+			}
+			totals := []struct {
+				path  string
+				count int64
+				sum   int64
+			}{
+				{"TtlNtries", int64(len(entries)), creditSum + debitSum},
+				{"TtlCdtNtries", credits, creditSum},
+				{"TtlDbtNtries", debits, debitSum},
+			}
+			for _, total := range totals {
+				if count := summary.text(total.path + "/NbOfNtries"); count != strconv.FormatInt(total.count, 10) {
+					t.Errorf("%s counts %s entries, want %d", total.path, count, total.count)
+				}
+				if sum := minor(t, summary.text(total.path+"/Sum")); sum != total.sum {
+					t.Errorf("%s sums to %s, want %s", total.path, Decimal(sum), Decimal(total.sum))
+				}
+			}
+			if total := signed(summary.get("TtlNtries/TtlNetNtry"), "Amt"); total != net {
+				t.Errorf("TtlNetNtry is %s, want %s", Decimal(total), Decimal(net))
+			}
+		})
+	}
+}
+
+// length counts characters rather than bytes, as every format's limits do.
+func length(s string) int {
+	return len([]rune(s))
+}
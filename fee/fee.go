@@ -0,0 +1,109 @@
+package fee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Operations fees can be charged on.
+const (
+	OperationTransfer   = "transfer"
+	OperationWithdrawal = "withdrawal"
+)
+
+// Rule charges a flat amount plus a percentage of the amount, capped at Max, on an operation
+// of at least MinAmount. The first FreePerMonth operations of a calendar month are free.
+type Rule struct {
+	Name         string `json:"name"`
+	Operation    string `json:"operation"`
+	MinAmount    int    `json:"min-amount"`
+	FreePerMonth int    `json:"free-per-month"`
+	Flat         int    `json:"flat"`
+	PercentBps   int    `json:"percent-bps"`
+	Max          int    `json:"max"`
+}
+
+type Schedule struct {
+	Rules []Rule `json:"rules"`
+}
+
+type QuoteRequest struct {
+	Operation string `json:"operation"`
+	Amount    int    `json:"amount"`
+}
+
+// Quote is the fee that will be charged on an operation, made up of the rules that applied.
+type Quote struct {
+	Operation string   `json:"operation"`
+	Amount    int      `json:"amount"`
+	Fee       int      `json:"fee"`
+	Rules     []string `json:"rules"`
+}
+
+// LoadSchedule reads the fee rules from a JSON file. A missing file means nothing is charged.
+func LoadSchedule(path string) (*Schedule, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Schedule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	schedule := &Schedule{}
+	if err := json.NewDecoder(file).Decode(schedule); err != nil {
+		return nil, fmt.Errorf("invalid fee schedule %s : %v", path, err)
+	}
+	for _, rule := range schedule.Rules {
+		if err := ValidateOperation(rule.Operation); err != nil {
+			return nil, fmt.Errorf("fee rule %q : %v", rule.Name, err)
+		}
+		if rule.Flat < 0 || rule.PercentBps < 0 || rule.Max < 0 || rule.MinAmount < 0 || rule.FreePerMonth < 0 {
+			return nil, fmt.Errorf("fee rule %q cannot have negative values", rule.Name)
+		}
+	}
+	return schedule, nil
+}
+
+// ScheduleFile is where the fee rules are read from, set with the feeScheduleFile environment variable.
+func ScheduleFile() string {
+	if path := os.Getenv("feeScheduleFile"); path != "" {
+		return path
+	}
+	return "./config/fees.json"
+}
+
+func ValidateOperation(operation string) error {
+	switch operation {
+	case OperationTransfer, OperationWithdrawal:
+		return nil
+	}
+	return fmt.Errorf("invalid operation %q : must be %s or %s", operation, OperationTransfer, OperationWithdrawal)
+}
+
+// Quote works out the fee on an operation of amount, given how many operations of the same
+// kind the account has already made this month. It only looks at its arguments, so the same
+// inputs always give the same fee.
+func (s *Schedule) Quote(operation string, amount, madeThisMonth int) Quote {
+	quote := Quote{
+		Operation: operation,
+		Amount:    amount,
+		Rules:     []string{},
+	}
+	for _, rule := range s.Rules {
+		if rule.Operation != operation || amount < rule.MinAmount || madeThisMonth < rule.FreePerMonth {
+			continue
+		}
+		charge := rule.Flat + int(int64(amount)*int64(rule.PercentBps)/10000)
+		if rule.Max > 0 && charge > rule.Max {
+			charge = rule.Max
+		}
+		if charge == 0 {
+			continue
+		}
+		quote.Fee += charge
+		quote.Rules = append(quote.Rules, rule.Name)
+	}
+	return quote
+}
@@ -0,0 +1,56 @@
+package fee
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tiered charges transfers on a sliding scale and withdrawals after three free a month.
+var tiered = &Schedule{Rules: []Rule{
+	{Name: "small-transfer", Operation: OperationTransfer, MinAmount: 0, Flat: 10},
+	{Name: "large-transfer", Operation: OperationTransfer, MinAmount: 100000, PercentBps: 50, Max: 2000},
+	{Name: "atm", Operation: OperationWithdrawal, FreePerMonth: 3, Flat: 150},
+}}
+
+func TestQuoteTierBoundaries(t *testing.T) {
+	tests := []struct {
+		name          string
+		operation     string
+		amount        int
+		madeThisMonth int
+		fee           int
+		rules         []string
+	}{
+		{"below large tier", OperationTransfer, 99999, 0, 10, []string{"small-transfer"}},
+		{"at large tier", OperationTransfer, 100000, 0, 10 + 500, []string{"small-transfer", "large-transfer"}},
+		{"just under the cap", OperationTransfer, 399800, 0, 10 + 1999, []string{"small-transfer", "large-transfer"}},
+		{"at the cap", OperationTransfer, 400000, 0, 10 + 2000, []string{"small-transfer", "large-transfer"}},
+		{"over the cap", OperationTransfer, 10000000, 0, 10 + 2000, []string{"small-transfer", "large-transfer"}},
+		{"last free withdrawal", OperationWithdrawal, 5000, 2, 0, []string{}},
+		{"first charged withdrawal", OperationWithdrawal, 5000, 3, 150, []string{"atm"}},
+		{"rules for other operations ignored", OperationWithdrawal, 100000, 10, 150, []string{"atm"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quote := tiered.Quote(test.operation, test.amount, test.madeThisMonth)
+			if quote.Fee != test.fee {
+				t.Errorf("fee = %d, want %d", quote.Fee, test.fee)
+			}
+			if !reflect.DeepEqual(quote.Rules, test.rules) {
+				t.Errorf("rules = %v, want %v", quote.Rules, test.rules)
+			}
+		})
+	}
+}
+
+func TestQuoteSkipsRulesThatChargeNothing(t *testing.T) {
+	schedule := &Schedule{Rules: []Rule{{Name: "tiny", Operation: OperationTransfer, PercentBps: 1}}}
+	quote := schedule.Quote(OperationTransfer, 9999, 0)
+	if quote.Fee != 0 || len(quote.Rules) != 0 {
+		t.Errorf("quote = %+v, want no fee and no rules", quote)
+	}
+	quote = schedule.Quote(OperationTransfer, 10000, 0)
+	if quote.Fee != 1 || !reflect.DeepEqual(quote.Rules, []string{"tiny"}) {
+		t.Errorf("quote = %+v, want a fee of 1 from tiny", quote)
+	}
+}
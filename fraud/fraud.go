@@ -0,0 +1,407 @@
+package fraud
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decisions a rule, and the engine as a whole, can come to. Block is worse than review,
+// which is worse than allow.
+const (
+	DecisionAllow  = "allow"
+	DecisionReview = "review"
+	DecisionBlock  = "block"
+)
+
+// Types of rule that can be configured.
+const (
+	RuleVelocity     = "velocity"
+	RuleNewPayee     = "new-payee"
+	RuleBalanceShare = "balance-share"
+	RuleUnusualHour  = "unusual-hour"
+)
+
+// HistoryDays is how far back the payments a transfer is checked against go.
+const HistoryDays = 90
+
+// Payment is one earlier transfer out of the account.
+type Payment struct {
+	ToBankNumber int64
+	Amount       int
+	At           time.Time
+}
+
+// Input is what the rules know about a transfer that is about to be sent. History holds
+// the account's transfers out over the last HistoryDays days, oldest first. A withdrawal
+// has no recipient, so its ToBankNumber is 0.
+type Input struct {
+	AccountID    int
+	ToBankNumber int64
+	Amount       int
+	Available    int64
+	PayeeSavedAt time.Time
+	History      []Payment
+	Now          time.Time
+}
+
+// Result is one rule's verdict on a transfer.
+type Result struct {
+	Rule     string `json:"rule"`
+	Decision string `json:"decision"`
+	Score    int    `json:"score"`
+	Reason   string `json:"reason"`
+}
+
+// Rule checks a transfer for one kind of suspicious behaviour. It returns nil when it has
+// nothing to say about the transfer.
+type Rule interface {
+	Evaluate(in *Input) *Result
+}
+
+// RuleConfig is one rule in the rules file. Only the fields its type uses are read. A rule
+// with no decision only adds its score.
+type RuleConfig struct {
+	Name         string  `json:"name"`
+	Type         string  `json:"type"`
+	Decision     string  `json:"decision"`
+	Score        int     `json:"score"`
+	Count        int     `json:"count"`
+	Minutes      int     `json:"minutes"`
+	MinAmount    int     `json:"min-amount"`
+	PayeeHours   int     `json:"payee-hours"`
+	Share        float64 `json:"share"`
+	MinHistory   int     `json:"min-history"`
+	MaxHourShare float64 `json:"max-hour-share"`
+}
+
+// Config is the rules file. Besides each rule's own decision, a transfer is reviewed once the
+// scores of the rules it triggers add up to ReviewScore and blocked once they reach
+// BlockScore. A score of 0 turns that off.
+type Config struct {
+	ReviewScore int          `json:"review-score"`
+	BlockScore  int          `json:"block-score"`
+	Rules       []RuleConfig `json:"rules"`
+}
+
+// Assessment is the engine's decision on a transfer and the rule results behind it.
+type Assessment struct {
+	ID           int       `json:"id"`
+	AccountID    int       `json:"account-id"`
+	ToBankNumber int64     `json:"to-bank-number"`
+	Amount       int       `json:"amount"`
+	Decision     string    `json:"decision"`
+	Score        int       `json:"score"`
+	Reasons      []string  `json:"reasons"`
+	CreatedAt    time.Time `json:"created-at"`
+}
+
+// Summary joins the reasons for the decision into one line.
+func (a *Assessment) Summary() string {
+	return strings.Join(a.Reasons, "; ")
+}
+
+// BlockedError is returned when a transfer is blocked. It is sent to the client as is.
+type BlockedError struct {
+	Message    string      `json:"Error"`
+	Assessment *Assessment `json:"-"`
+}
+
+func (e *BlockedError) Error() string {
+	return e.Message
+}
+
+func (e *BlockedError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// builders makes a Rule from its configuration, keyed by rule type. Adding a new kind of
+// rule only takes adding it here.
+var builders = map[string]func(c RuleConfig) (Rule, error){
+	RuleVelocity:     newVelocity,
+	RuleNewPayee:     newNewPayee,
+	RuleBalanceShare: newBalanceShare,
+	RuleUnusualHour:  newUnusualHour,
+}
+
+// Engine runs every configured rule over a transfer. Its rules can be swapped while it is
+// in use, which is how the rules file is reloaded without a restart.
+type Engine struct {
+	mu       sync.RWMutex
+	config   Config
+	rules    []Rule
+	names    []string
+	modified time.Time
+}
+
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// Assess decides whether a transfer can go ahead.
+func (e *Engine) Assess(in *Input) *Assessment {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	assessment := &Assessment{
+		AccountID:    in.AccountID,
+		ToBankNumber: in.ToBankNumber,
+		Amount:       in.Amount,
+		Decision:     DecisionAllow,
+		Reasons:      []string{},
+		CreatedAt:    in.Now,
+	}
+	for i, rule := range e.rules {
+		result := rule.Evaluate(in)
+		if result == nil {
+			continue
+		}
+		result.Rule = e.names[i]
+		assessment.Score += result.Score
+		assessment.Reasons = append(assessment.Reasons, result.Rule+" : "+result.Reason)
+		assessment.Decision = worse(assessment.Decision, result.Decision)
+	}
+	if e.config.BlockScore > 0 && assessment.Score >= e.config.BlockScore {
+		assessment.Decision = DecisionBlock
+	} else if e.config.ReviewScore > 0 && assessment.Score >= e.config.ReviewScore {
+		assessment.Decision = worse(assessment.Decision, DecisionReview)
+	}
+	return assessment
+}
+
+func worse(a, b string) string {
+	rank := map[string]int{DecisionAllow: 0, DecisionReview: 1, DecisionBlock: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Load reads the rules from a JSON file and puts them in place of the current ones. A
+// missing file means there are no rules. On error the current rules are kept.
+func (e *Engine) Load(path string) error {
+	config := Config{}
+	var modified time.Time
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		modified = info.ModTime()
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := json.NewDecoder(file).Decode(&config); err != nil {
+			return fmt.Errorf("invalid fraud rules %s : %v", path, err)
+		}
+	}
+	rules, names := []Rule{}, []string{}
+	for _, c := range config.Rules {
+		build, ok := builders[c.Type]
+		if !ok {
+			return fmt.Errorf("fraud rule %q : unknown type %q", c.Name, c.Type)
+		}
+		if c.Decision == "" {
+			c.Decision = DecisionAllow
+		}
+		if err := ValidateDecision(c.Decision); err != nil {
+			return fmt.Errorf("fraud rule %q : %v", c.Name, err)
+		}
+		rule, err := build(c)
+		if err != nil {
+			return fmt.Errorf("fraud rule %q : %v", c.Name, err)
+		}
+		rules, names = append(rules, rule), append(names, ruleName(c))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config, e.rules, e.names, e.modified = config, rules, names, modified
+	return nil
+}
+
+// Watch reloads the rules whenever the file changes, checking every interval. It never returns.
+func (e *Engine) Watch(path string, interval time.Duration) {
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		e.mu.RLock()
+		modified := e.modified
+		e.mu.RUnlock()
+		if err == nil && info.ModTime().Equal(modified) || os.IsNotExist(err) && modified.IsZero() {
+			continue
+		}
+		if err := e.Load(path); err != nil {
+			log.Printf("keeping the current fraud rules : %v", err)
+			continue
+		}
+		log.Println("fraud rules reloaded from", path)
+	}
+}
+
+func ValidateDecision(decision string) error {
+	switch decision {
+	case DecisionAllow, DecisionReview, DecisionBlock:
+		return nil
+	}
+	return fmt.Errorf("invalid decision %q : must be %s, %s or %s", decision, DecisionAllow, DecisionReview, DecisionBlock)
+}
+
+// RulesFile is where the fraud rules are read from, set with the fraudRulesFile environment variable.
+func RulesFile() string {
+	if path := os.Getenv("fraudRulesFile"); path != "" {
+		return path
+	}
+	return "./config/fraud_rules.json"
+}
+
+// ruleName is how a rule is referred to in reasons, its type when it has no name.
+func ruleName(c RuleConfig) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+//
+// Rules
+//
+
+// velocity flags an account making Count or more transfers within Minutes, this one included.
+type velocity struct {
+	config RuleConfig
+}
+
+func newVelocity(c RuleConfig) (Rule, error) {
+	if c.Count < 1 || c.Minutes < 1 {
+		return nil, fmt.Errorf("count and minutes must be at least 1")
+	}
+	if c.Minutes > HistoryDays*24*60 {
+		return nil, fmt.Errorf("minutes cannot go back further than %d days", HistoryDays)
+	}
+	return &velocity{c}, nil
+}
+
+func (r *velocity) Evaluate(in *Input) *Result {
+	since := in.Now.Add(-time.Duration(r.config.Minutes) * time.Minute)
+	count := 1
+	for _, payment := range in.History {
+		if payment.At.After(since) {
+			count++
+		}
+	}
+	if count < r.config.Count {
+		return nil
+	}
+	return &Result{
+		Decision: r.config.Decision,
+		Score:    r.config.Score,
+		Reason:   fmt.Sprintf("%d transfers in %d minutes", count, r.config.Minutes),
+	}
+}
+
+// newPayee flags a transfer of at least MinAmount to someone the account has not paid in the
+// last HistoryDays days, or only saved as a payee in the last PayeeHours hours.
+type newPayee struct {
+	config RuleConfig
+}
+
+func newNewPayee(c RuleConfig) (Rule, error) {
+	if c.MinAmount < 0 || c.PayeeHours < 0 {
+		return nil, fmt.Errorf("min-amount and payee-hours cannot be negative")
+	}
+	return &newPayee{c}, nil
+}
+
+func (r *newPayee) Evaluate(in *Input) *Result {
+	if in.ToBankNumber == 0 || in.Amount < r.config.MinAmount {
+		return nil
+	}
+	reason := ""
+	if !in.PayeeSavedAt.IsZero() && in.Now.Sub(in.PayeeSavedAt) < time.Duration(r.config.PayeeHours)*time.Hour {
+		reason = fmt.Sprintf("%v to a payee saved %v ago", in.Amount, in.Now.Sub(in.PayeeSavedAt).Round(time.Minute))
+	}
+	paidBefore := false
+	for _, payment := range in.History {
+		if payment.ToBankNumber == in.ToBankNumber {
+			paidBefore = true
+			break
+		}
+	}
+	if !paidBefore {
+		reason = fmt.Sprintf("%v to a recipient not paid in the last %d days", in.Amount, HistoryDays)
+	}
+	if reason == "" {
+		return nil
+	}
+	return &Result{Decision: r.config.Decision, Score: r.config.Score, Reason: reason}
+}
+
+// balanceShare flags a transfer of at least MinAmount taking Share or more of what is available.
+type balanceShare struct {
+	config RuleConfig
+}
+
+func newBalanceShare(c RuleConfig) (Rule, error) {
+	if c.Share <= 0 || c.Share > 1 {
+		return nil, fmt.Errorf("share must be above 0 and at most 1")
+	}
+	return &balanceShare{c}, nil
+}
+
+func (r *balanceShare) Evaluate(in *Input) *Result {
+	if in.Amount < r.config.MinAmount || in.Available <= 0 {
+		return nil
+	}
+	share := float64(in.Amount) / float64(in.Available)
+	if share < r.config.Share {
+		return nil
+	}
+	return &Result{
+		Decision: r.config.Decision,
+		Score:    r.config.Score,
+		Reason:   fmt.Sprintf("%v is %.0f%% of the available balance", in.Amount, share*100),
+	}
+}
+
+// unusualHour flags a transfer made at an hour of the day (UTC) the account rarely sends
+// money at, once it has at least MinHistory transfers to go by. An hour is unusual when
+// no more than MaxHourShare of the account's transfers were made in it.
+type unusualHour struct {
+	config RuleConfig
+}
+
+func newUnusualHour(c RuleConfig) (Rule, error) {
+	if c.MinHistory < 1 {
+		return nil, fmt.Errorf("min-history must be at least 1")
+	}
+	if c.MaxHourShare < 0 || c.MaxHourShare >= 1 {
+		return nil, fmt.Errorf("max-hour-share must be at least 0 and below 1")
+	}
+	return &unusualHour{c}, nil
+}
+
+func (r *unusualHour) Evaluate(in *Input) *Result {
+	if len(in.History) < r.config.MinHistory {
+		return nil
+	}
+	hour, count := in.Now.Hour(), 0
+	for _, payment := range in.History {
+		if payment.At.Hour() == hour {
+			count++
+		}
+	}
+	if float64(count)/float64(len(in.History)) > r.config.MaxHourShare {
+		return nil
+	}
+	return &Result{
+		Decision: r.config.Decision,
+		Score:    r.config.Score,
+		Reason:   fmt.Sprintf("%d of the last %d transfers were made between %02d:00 and %02d:59", count, len(in.History), hour, hour),
+	}
+}
@@ -0,0 +1,182 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+)
+
+// now is the fixed clock every rule is evaluated against.
+var now = time.Date(2024, time.March, 14, 3, 30, 0, 0, time.UTC)
+
+func ago(d time.Duration) time.Time {
+	return now.Add(-d)
+}
+
+func mustBuild(t *testing.T, c RuleConfig) Rule {
+	t.Helper()
+	c.Decision, c.Score = DecisionReview, 10
+	rule, err := builders[c.Type](c)
+	if err != nil {
+		t.Fatalf("building %s rule : %v", c.Type, err)
+	}
+	return rule
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RuleConfig
+		in      Input
+		flagged bool
+	}{
+		{
+			name:    "velocity under the count",
+			config:  RuleConfig{Type: RuleVelocity, Count: 3, Minutes: 10},
+			in:      Input{History: []Payment{{At: ago(5 * time.Minute)}}},
+			flagged: false,
+		},
+		{
+			name:    "velocity at the count, this transfer included",
+			config:  RuleConfig{Type: RuleVelocity, Count: 3, Minutes: 10},
+			in:      Input{History: []Payment{{At: ago(9 * time.Minute)}, {At: ago(time.Minute)}}},
+			flagged: true,
+		},
+		{
+			name:    "velocity ignores transfers at the edge of the window",
+			config:  RuleConfig{Type: RuleVelocity, Count: 3, Minutes: 10},
+			in:      Input{History: []Payment{{At: ago(10 * time.Minute)}, {At: ago(time.Minute)}}},
+			flagged: false,
+		},
+		{
+			name:    "new payee below the minimum",
+			config:  RuleConfig{Type: RuleNewPayee, MinAmount: 1000, PayeeHours: 24},
+			in:      Input{ToBankNumber: 7, Amount: 999},
+			flagged: false,
+		},
+		{
+			name:    "new payee never paid",
+			config:  RuleConfig{Type: RuleNewPayee, MinAmount: 1000, PayeeHours: 24},
+			in:      Input{ToBankNumber: 7, Amount: 1000, History: []Payment{{ToBankNumber: 8, At: ago(time.Hour)}}},
+			flagged: true,
+		},
+		{
+			name:    "new payee paid before and saved long ago",
+			config:  RuleConfig{Type: RuleNewPayee, MinAmount: 1000, PayeeHours: 24},
+			in:      Input{ToBankNumber: 7, Amount: 1000, PayeeSavedAt: ago(25 * time.Hour), History: []Payment{{ToBankNumber: 7, At: ago(time.Hour)}}},
+			flagged: false,
+		},
+		{
+			name:    "new payee paid before but only just saved",
+			config:  RuleConfig{Type: RuleNewPayee, MinAmount: 1000, PayeeHours: 24},
+			in:      Input{ToBankNumber: 7, Amount: 1000, PayeeSavedAt: ago(2 * time.Hour), History: []Payment{{ToBankNumber: 7, At: ago(time.Hour)}}},
+			flagged: true,
+		},
+		{
+			name:    "new payee ignores withdrawals",
+			config:  RuleConfig{Type: RuleNewPayee, MinAmount: 1000, PayeeHours: 24},
+			in:      Input{Amount: 1000},
+			flagged: false,
+		},
+		{
+			name:    "balance share under the share",
+			config:  RuleConfig{Type: RuleBalanceShare, MinAmount: 100, Share: 0.5},
+			in:      Input{Amount: 499, Available: 1000},
+			flagged: false,
+		},
+		{
+			name:    "balance share at the share",
+			config:  RuleConfig{Type: RuleBalanceShare, MinAmount: 100, Share: 0.5},
+			in:      Input{Amount: 500, Available: 1000},
+			flagged: true,
+		},
+		{
+			name:    "balance share below the minimum",
+			config:  RuleConfig{Type: RuleBalanceShare, MinAmount: 100, Share: 0.5},
+			in:      Input{Amount: 99, Available: 100},
+			flagged: false,
+		},
+		{
+			name:    "balance share with nothing available",
+			config:  RuleConfig{Type: RuleBalanceShare, MinAmount: 100, Share: 0.5},
+			in:      Input{Amount: 500, Available: 0},
+			flagged: false,
+		},
+		{
+			name:    "unusual hour without enough history",
+			config:  RuleConfig{Type: RuleUnusualHour, MinHistory: 4, MaxHourShare: 0.25},
+			in:      Input{History: []Payment{{At: ago(24 * time.Hour)}}},
+			flagged: false,
+		},
+		{
+			name:   "unusual hour never used",
+			config: RuleConfig{Type: RuleUnusualHour, MinHistory: 4, MaxHourShare: 0.25},
+			in: Input{History: []Payment{
+				{At: ago(18 * time.Hour)}, {At: ago(42 * time.Hour)}, {At: ago(66 * time.Hour)}, {At: ago(90 * time.Hour)},
+			}},
+			flagged: true,
+		},
+		{
+			name:   "unusual hour used exactly the maximum share",
+			config: RuleConfig{Type: RuleUnusualHour, MinHistory: 4, MaxHourShare: 0.25},
+			in: Input{History: []Payment{
+				{At: ago(24 * time.Hour)}, {At: ago(42 * time.Hour)}, {At: ago(66 * time.Hour)}, {At: ago(90 * time.Hour)},
+			}},
+			flagged: true,
+		},
+		{
+			name:   "usual hour",
+			config: RuleConfig{Type: RuleUnusualHour, MinHistory: 4, MaxHourShare: 0.25},
+			in: Input{History: []Payment{
+				{At: ago(24 * time.Hour)}, {At: ago(48 * time.Hour)}, {At: ago(66 * time.Hour)}, {At: ago(90 * time.Hour)},
+			}},
+			flagged: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.in.Now = now
+			result := mustBuild(t, test.config).Evaluate(&test.in)
+			if flagged := result != nil; flagged != test.flagged {
+				t.Fatalf("flagged = %v, want %v (%+v)", flagged, test.flagged, result)
+			}
+			if result != nil && (result.Decision != DecisionReview || result.Score != 10 || result.Reason == "") {
+				t.Errorf("result = %+v, want the rule's decision, score and a reason", result)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidRules(t *testing.T) {
+	tests := []RuleConfig{
+		{Type: RuleVelocity, Count: 0, Minutes: 10},
+		{Type: RuleVelocity, Count: 3, Minutes: HistoryDays*24*60 + 1},
+		{Type: RuleNewPayee, MinAmount: -1},
+		{Type: RuleBalanceShare, Share: 1.5},
+		{Type: RuleUnusualHour, MinHistory: 0, MaxHourShare: 0.1},
+		{Type: RuleUnusualHour, MinHistory: 1, MaxHourShare: 1},
+	}
+	for _, c := range tests {
+		if _, err := builders[c.Type](c); err == nil {
+			t.Errorf("%+v built, want an error", c)
+		}
+	}
+}
+
+func TestSampleRules(t *testing.T) {
+	engine := NewEngine()
+	if err := engine.Load("../config/fraud_rules.json"); err != nil {
+		t.Fatal(err)
+	}
+	quiet := &Input{ToBankNumber: 7, Amount: 100, Available: 100000, History: []Payment{{ToBankNumber: 7, At: ago(time.Hour)}}, Now: now}
+	if assessment := engine.Assess(quiet); assessment.Decision != DecisionAllow {
+		t.Errorf("decision = %s, want %s (%s)", assessment.Decision, DecisionAllow, assessment.Summary())
+	}
+	// a burst of transfers to a new payee adds up to a review without either rule asking for one
+	burst := &Input{ToBankNumber: 9, Amount: 100000, Available: 1000000, Now: now}
+	for i := 1; i <= 4; i++ {
+		burst.History = append(burst.History, Payment{ToBankNumber: 7, At: ago(time.Duration(i) * time.Minute)})
+	}
+	if assessment := engine.Assess(burst); assessment.Decision != DecisionReview || assessment.Score != 70 {
+		t.Errorf("assessment = %s with score %d, want %s with 70 (%s)", assessment.Decision, assessment.Score, DecisionReview, assessment.Summary())
+	}
+}
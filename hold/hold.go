@@ -0,0 +1,83 @@
+package hold
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+const (
+	StatusActive   = "active"
+	StatusCaptured = "captured"
+	StatusReleased = "released"
+	StatusExpired  = "expired"
+)
+
+const defaultExpiryDays = 7
+
+type CreateHoldRequest struct {
+	Amount    int    `json:"amount"`
+	Merchant  string `json:"merchant"`
+	Reference string `json:"reference"`
+}
+
+// CaptureRequest settles a hold. An amount of 0 captures the whole hold, anything less
+// captures part of it and releases the rest.
+type CaptureRequest struct {
+	Amount int `json:"amount"`
+}
+
+// Hold reserves money on an account for a card payment that has been authorised but not
+// settled yet. It lowers the available balance but leaves the ledger balance alone.
+type Hold struct {
+	ID         int       `json:"id"`
+	AccountID  int       `json:"-"`
+	Amount     int       `json:"amount"`
+	Captured   int       `json:"captured"`
+	Merchant   string    `json:"merchant"`
+	Reference  string    `json:"reference"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created-at"`
+	ExpiresAt  time.Time `json:"expires-at"`
+	SettledAt  time.Time `json:"settled-at"`
+	TransferID int       `json:"transfer-id"`
+}
+
+func CreateHold(accountID int, request *CreateHoldRequest) *Hold {
+	now := time.Now().UTC()
+	return &Hold{
+		AccountID: accountID,
+		Amount:    request.Amount,
+		Merchant:  request.Merchant,
+		Reference: request.Reference,
+		Status:    StatusActive,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, ExpiryDays()),
+	}
+}
+
+func Validate(request *CreateHoldRequest) error {
+	if request.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if request.Merchant == "" || request.Reference == "" {
+		return fmt.Errorf("merchant and reference are required")
+	}
+	if len([]rune(request.Reference)) > transfer.MaxReferenceLength {
+		return fmt.Errorf("reference cannot be longer than %d characters", transfer.MaxReferenceLength)
+	}
+	return nil
+}
+
+// ExpiryDays is how long a hold lasts before it is released on its own,
+// read from the holdExpiryDays environment variable.
+func ExpiryDays() int {
+	days, err := strconv.Atoi(os.Getenv("holdExpiryDays"))
+	if err != nil || days < 1 {
+		return defaultExpiryDays
+	}
+	return days
+}
@@ -0,0 +1,101 @@
+package interest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DayFormat is how days are keyed in the interest tables.
+const DayFormat = "2006-01-02"
+
+// MicroUnits is how many micro units make up one unit of balance. Accruals are kept in
+// micro units so that a month of small daily amounts adds up without rounding each day.
+const MicroUnits = 1000000
+
+// OverdraftRateType is the account type under which the rate charged on overdrawn
+// balances is set. It applies to every account that goes below zero.
+const OverdraftRateType = "overdraft"
+
+// Rate is the APR, in basis points, paid on an account type from EffectiveFrom onwards.
+type Rate struct {
+	AccountType   string `json:"account-type"`
+	APRBps        int    `json:"apr-bps"`
+	EffectiveFrom string `json:"effective-from"`
+}
+
+type Accrual struct {
+	ID        int    `json:"id"`
+	AccountID int    `json:"-"`
+	Day       string `json:"day"`
+	Balance   int64  `json:"balance"`
+	APRBps    int    `json:"apr-bps"`
+	Micro     int64  `json:"micro"`
+}
+
+// LoadRates reads the rate table from a JSON file, e.g.
+// [{"account-type": "savings", "apr-bps": 250, "effective-from": "2026-01-01"}].
+// A missing file means no interest is paid on any account type.
+func LoadRates(path string) ([]Rate, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Rate{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rates := []Rate{}
+	if err := json.NewDecoder(file).Decode(&rates); err != nil {
+		return nil, fmt.Errorf("invalid interest rates file %s : %v", path, err)
+	}
+	for _, rate := range rates {
+		if _, err := time.Parse(DayFormat, rate.EffectiveFrom); err != nil {
+			return nil, fmt.Errorf("invalid effective date %q for %s", rate.EffectiveFrom, rate.AccountType)
+		}
+		if rate.APRBps < 0 {
+			return nil, fmt.Errorf("negative rate for %s", rate.AccountType)
+		}
+	}
+	return rates, nil
+}
+
+// RatesFile is where the rate table is read from, set with the interestRatesFile environment variable.
+func RatesFile() string {
+	if path := os.Getenv("interestRatesFile"); path != "" {
+		return path
+	}
+	return "./config/interest_rates.json"
+}
+
+// DailyAccrual is one day of interest on balance at aprBps, in micro units, rounded half up.
+// Only positive balances earn interest.
+func DailyAccrual(balance int64, aprBps int) int64 {
+	if balance <= 0 || aprBps <= 0 {
+		return 0
+	}
+	// balance * apr / 10000 / 365 * MicroUnits, kept in integers
+	numerator := balance * int64(aprBps) * (MicroUnits / 10000)
+	return (2*numerator + 365) / (2 * 365)
+}
+
+// DailyOverdraftCharge is one day of interest charged on an overdrawn balance at aprBps, in
+// micro units, rounded half up. The result is negative as it is taken from the account.
+func DailyOverdraftCharge(balance int64, aprBps int) int64 {
+	if balance >= 0 || aprBps <= 0 {
+		return 0
+	}
+	return -DailyAccrual(-balance, aprBps)
+}
+
+// Split turns micro units into the whole units that can be posted and the remainder
+// that is carried over into the next month. Charges are negative and split the same way.
+func Split(micro int64) (int64, int64) {
+	return micro / MicroUnits, micro % MicroUnits
+}
+
+// LastDayOfMonth reports whether day is the final day of its month, when interest is posted.
+func LastDayOfMonth(day time.Time) bool {
+	return day.AddDate(0, 0, 1).Day() == 1
+}
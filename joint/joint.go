@@ -0,0 +1,150 @@
+package joint
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+// Signing rules for accounts with more than one owner. Under RuleAnyOne every owner can send
+// money on their own. Under RuleNOfM transfers above Above need Required owners to approve
+// them, counting the owner who asked for the transfer.
+const (
+	RuleAnyOne = "any-one"
+	RuleNOfM   = "n-of-m"
+)
+
+// A pending transfer goes to StatusInReview rather than StatusExecuted when, once approved,
+// it is large enough to also need a reviewer's approval.
+const (
+	StatusPending  = "pending"
+	StatusExecuted = "executed"
+	StatusInReview = "in-review"
+	StatusRejected = "rejected"
+	StatusFailed   = "failed"
+)
+
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+type AddOwnerRequest struct {
+	Username string `json:"username"`
+}
+
+// Owner is a customer who can use an account besides its own holder. They sign in as
+// themselves and act on the account with their own token.
+type Owner struct {
+	AccountID  int       `json:"-"`
+	OwnerID    int       `json:"-"`
+	Username   string    `json:"username"`
+	BankNumber int64     `json:"bank-number"`
+	AddedAt    time.Time `json:"added-at"`
+}
+
+type SigningRule struct {
+	AccountID int    `json:"-"`
+	Rule      string `json:"rule"`
+	Required  int    `json:"required"`
+	Above     int    `json:"above"`
+}
+
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// PendingTransfer is a transfer waiting on the approvals its account's signing rule asks for.
+// It is carried out as soon as enough owners have approved it, and dropped as soon as one rejects it.
+type PendingTransfer struct {
+	ID           int         `json:"id"`
+	AccountID    int         `json:"-"`
+	RequestedBy  int         `json:"requested-by"`
+	ToBankNumber int64       `json:"to-bank-number"`
+	Amount       int         `json:"amount"`
+	Memo         string      `json:"memo"`
+	Reference    string      `json:"reference"`
+	Category     string      `json:"category"`
+	Required     int         `json:"required"`
+	Status       string      `json:"status"`
+	CreatedAt    time.Time   `json:"created-at"`
+	DecidedAt    time.Time   `json:"decided-at"`
+	Approvals    []*Approval `json:"approvals"`
+}
+
+// Approval records one owner's decision on a pending transfer.
+type Approval struct {
+	ID        int       `json:"id"`
+	PendingID int       `json:"-"`
+	OwnerID   int       `json:"owner-id"`
+	Decision  string    `json:"decision"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created-at"`
+}
+
+// DefaultRule is the rule for accounts that have not set one: any owner can send money.
+func DefaultRule(accountID int) *SigningRule {
+	return &SigningRule{AccountID: accountID, Rule: RuleAnyOne}
+}
+
+// ApprovalsNeeded is how many owners have to approve a transfer of amount.
+func (r *SigningRule) ApprovalsNeeded(amount int) int {
+	if r.Rule == RuleNOfM && amount > r.Above {
+		return r.Required
+	}
+	return 1
+}
+
+// ValidateRule checks rule can be met by an account with owners owners in total.
+func ValidateRule(rule *SigningRule, owners int) error {
+	switch rule.Rule {
+	case RuleAnyOne:
+		return nil
+	case RuleNOfM:
+		if rule.Required < 2 || rule.Required > owners {
+			return fmt.Errorf("required approvals must be between 2 and the %d owners of the account", owners)
+		}
+		if rule.Above < 0 {
+			return fmt.Errorf("above cannot be negative")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid signing rule %q : must be %s or %s", rule.Rule, RuleAnyOne, RuleNOfM)
+}
+
+func CreatePendingTransfer(accountID, requestedBy int, toBankNumber int64, request *transfer.TransferRequest, required int) *PendingTransfer {
+	return &PendingTransfer{
+		AccountID:    accountID,
+		RequestedBy:  requestedBy,
+		ToBankNumber: toBankNumber,
+		Amount:       request.Amount,
+		Memo:         request.Memo,
+		Reference:    request.Reference,
+		Category:     request.Category,
+		Required:     required,
+		Status:       StatusPending,
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+// TransferRequest is the transfer to make once the pending transfer is approved.
+func (p *PendingTransfer) TransferRequest() *transfer.TransferRequest {
+	return &transfer.TransferRequest{
+		ToBankNumber: p.ToBankNumber,
+		Amount:       p.Amount,
+		Memo:         p.Memo,
+		Reference:    p.Reference,
+		Category:     p.Category,
+	}
+}
+
+func CreateApproval(pendingID, ownerID int, decision, comment string) *Approval {
+	return &Approval{
+		PendingID: pendingID,
+		OwnerID:   ownerID,
+		Decision:  decision,
+		Comment:   comment,
+		CreatedAt: time.Now().UTC(),
+	}
+}
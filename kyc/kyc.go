@@ -0,0 +1,294 @@
+package kyc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/limit"
+)
+
+// Statuses a customer's identity verification moves through. Every account starts out
+// unverified. Submitting a complete profile makes it pending until a reviewer verifies or
+// rejects it. A rejected profile can be corrected and submitted again.
+const (
+	StatusUnverified = "unverified"
+	StatusPending    = "pending"
+	StatusVerified   = "verified"
+	StatusRejected   = "rejected"
+)
+
+// Types of document a customer can upload.
+const (
+	DocumentPassport       = "passport"
+	DocumentDrivingLicence = "driving-licence"
+	DocumentNationalIDCard = "national-id-card"
+	DocumentProofOfAddress = "proof-of-address"
+)
+
+// DateFormat is how dates of birth are written.
+const DateFormat = "2006-01-02"
+
+// MinimumAge is how old a customer has to be to be verified.
+const MinimumAge = 18
+
+const (
+	defaultMaxDocumentBytes   = 10 << 20
+	defaultUnverifiedPerTrans = 25000
+	defaultUnverifiedDaily    = 50000
+	defaultUnverifiedCount    = 5
+)
+
+var (
+	nationalIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{4,32}$`)
+	blobKeyPattern    = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// contentTypes are the kinds of file accepted as documents.
+var contentTypes = []string{"application/pdf", "image/jpeg", "image/png"}
+
+// ProfileRequest sets the identity details a customer is verified against.
+type ProfileRequest struct {
+	DateOfBirth  string `json:"date-of-birth"`
+	AddressLine1 string `json:"address-line-1"`
+	AddressLine2 string `json:"address-line-2"`
+	City         string `json:"city"`
+	Postcode     string `json:"postcode"`
+	Country      string `json:"country"`
+	NationalID   string `json:"national-id"`
+}
+
+// Profile is an account holder's identity details and where their verification stands.
+type Profile struct {
+	AccountID    int       `json:"account-id"`
+	DateOfBirth  string    `json:"date-of-birth"`
+	AddressLine1 string    `json:"address-line-1"`
+	AddressLine2 string    `json:"address-line-2"`
+	City         string    `json:"city"`
+	Postcode     string    `json:"postcode"`
+	Country      string    `json:"country"`
+	NationalID   string    `json:"national-id"`
+	Status       string    `json:"status"`
+	ReviewerID   int       `json:"reviewer-id"`
+	Comment      string    `json:"comment"`
+	UpdatedAt    time.Time `json:"updated-at"`
+	SubmittedAt  time.Time `json:"submitted-at"`
+	DecidedAt    time.Time `json:"decided-at"`
+}
+
+// Document is an uploaded identity document. The file itself is kept in the blob store,
+// keyed by its SHA-256.
+type Document struct {
+	ID          int       `json:"id"`
+	AccountID   int       `json:"-"`
+	Type        string    `json:"type"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content-type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	UploadedAt  time.Time `json:"uploaded-at"`
+}
+
+// Record is everything held about an account holder's identity.
+type Record struct {
+	Profile   *Profile    `json:"profile"`
+	Documents []*Document `json:"documents"`
+}
+
+// EmptyProfile is the profile of an account holder who has not given any details yet.
+func EmptyProfile(accountID int) *Profile {
+	return &Profile{AccountID: accountID, Status: StatusUnverified}
+}
+
+// Apply copies the details in request onto p.
+func (p *Profile) Apply(request *ProfileRequest, now time.Time) {
+	p.DateOfBirth = request.DateOfBirth
+	p.AddressLine1 = request.AddressLine1
+	p.AddressLine2 = request.AddressLine2
+	p.City = request.City
+	p.Postcode = request.Postcode
+	p.Country = strings.ToUpper(request.Country)
+	p.NationalID = strings.ToUpper(request.NationalID)
+	p.UpdatedAt = now
+}
+
+// Editable reports whether the holder can still change the profile. Once it has been
+// submitted it is locked until it is rejected.
+func (p *Profile) Editable() error {
+	switch p.Status {
+	case StatusUnverified, StatusRejected:
+		return nil
+	}
+	return fmt.Errorf("profile is %s and cannot be changed", p.Status)
+}
+
+func ValidateProfile(request *ProfileRequest, now time.Time) error {
+	born, err := time.Parse(DateFormat, request.DateOfBirth)
+	if err != nil {
+		return fmt.Errorf("date of birth must be given as YYYY-MM-DD")
+	}
+	if born.AddDate(MinimumAge, 0, 0).After(now) {
+		return fmt.Errorf("account holders must be at least %d", MinimumAge)
+	}
+	if request.AddressLine1 == "" || request.City == "" || request.Postcode == "" {
+		return fmt.Errorf("address line 1, city and postcode are required")
+	}
+	if len(request.Country) != 2 {
+		return fmt.Errorf("country must be a two letter country code")
+	}
+	if !nationalIDPattern.MatchString(request.NationalID) {
+		return fmt.Errorf("national id must be 4 to 32 letters and digits")
+	}
+	return nil
+}
+
+func ValidateDocumentType(docType string) error {
+	switch docType {
+	case DocumentPassport, DocumentDrivingLicence, DocumentNationalIDCard, DocumentProofOfAddress:
+		return nil
+	}
+	return fmt.Errorf("invalid document type %q : must be one of %s, %s, %s or %s", docType,
+		DocumentPassport, DocumentDrivingLicence, DocumentNationalIDCard, DocumentProofOfAddress)
+}
+
+func ValidateContentType(contentType string) error {
+	for _, allowed := range contentTypes {
+		if contentType == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("documents must be one of %s, not %s", strings.Join(contentTypes, ", "), contentType)
+}
+
+// ValidateSubmission checks a profile is complete enough to be reviewed. It needs its details
+// filled in and at least one document.
+func ValidateSubmission(p *Profile, documents []*Document) error {
+	if err := p.Editable(); err != nil {
+		return err
+	}
+	if p.DateOfBirth == "" {
+		return fmt.Errorf("fill in your profile before submitting it")
+	}
+	if len(documents) == 0 {
+		return fmt.Errorf("upload at least one document before submitting your profile")
+	}
+	return nil
+}
+
+// UnverifiedLimits are the limits on transfers out of accounts whose holder is not verified,
+// read from the kycUnverifiedPerTransaction, kycUnverifiedDaily and kycUnverifiedDailyCount
+// environment variables.
+func UnverifiedLimits() limit.Limits {
+	return limit.Limits{
+		PerTransaction: int64(envInt("kycUnverifiedPerTransaction", defaultUnverifiedPerTrans)),
+		Daily:          int64(envInt("kycUnverifiedDaily", defaultUnverifiedDaily)),
+		DailyCount:     envInt("kycUnverifiedDailyCount", defaultUnverifiedCount),
+	}
+}
+
+// MaxDocumentBytes is the largest document that can be uploaded, read from the
+// kycMaxDocumentBytes environment variable.
+func MaxDocumentBytes() int64 {
+	return int64(envInt("kycMaxDocumentBytes", defaultMaxDocumentBytes))
+}
+
+// BlobDir is where documents are stored, read from the kycBlobDir environment variable.
+func BlobDir() string {
+	if dir := os.Getenv("kycBlobDir"); dir != "" {
+		return dir
+	}
+	return "./db/kyc"
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 1 {
+		return fallback
+	}
+	return value
+}
+
+// Blob is a file put in the blob store.
+type Blob struct {
+	Key  string
+	Size int64
+}
+
+// Sniff works out what kind of file r holds from its first bytes. The returned reader still
+// reads r from the start.
+func Sniff(r io.Reader) (string, io.Reader) {
+	reader := bufio.NewReader(r)
+	head, _ := reader.Peek(512)
+	return http.DetectContentType(head), reader
+}
+
+// BlobStore keeps documents as files on local disk. Each file is named after the SHA-256 of
+// its contents, so the same file uploaded twice is only stored once and a key can always be
+// checked against what it points to.
+type BlobStore struct {
+	dir string
+}
+
+func NewBlobStore(dir string) *BlobStore {
+	return &BlobStore{dir: dir}
+}
+
+// Put copies r into the store. It fails without storing anything if r holds more than
+// maxBytes.
+func (b *BlobStore) Put(r io.Reader, maxBytes int64) (*Blob, error) {
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return nil, err
+	}
+	temp, err := ioutil.TempFile(b.dir, "upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, hash), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > maxBytes {
+		return nil, fmt.Errorf("document is over the limit of %d bytes", maxBytes)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("document is empty")
+	}
+	if err := temp.Close(); err != nil {
+		return nil, err
+	}
+	blob := &Blob{Key: hex.EncodeToString(hash.Sum(nil)), Size: size}
+	path := b.path(blob.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Open opens the file stored under key.
+func (b *BlobStore) Open(key string) (*os.File, error) {
+	if !blobKeyPattern.MatchString(key) {
+		return nil, fmt.Errorf("invalid blob key %q", key)
+	}
+	return os.Open(b.path(key))
+}
+
+// path spreads files over subdirectories named after the first two characters of their key.
+func (b *BlobStore) path(key string) string {
+	return filepath.Join(b.dir, key[:2], key)
+}
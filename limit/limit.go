@@ -0,0 +1,148 @@
+package limit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Limits caps the money leaving an account. A zero field means no limit.
+type Limits struct {
+	PerTransaction int64 `json:"per-transaction"`
+	Daily          int64 `json:"daily"`
+	DailyCount     int   `json:"daily-count"`
+}
+
+// Usage is what has left the account over the last 24 hours.
+type Usage struct {
+	Amount int64 `json:"amount"`
+	Count  int   `json:"count"`
+}
+
+// Allowance is an account's limits alongside how much of them is still available.
+type Allowance struct {
+	Limits         Limits `json:"limits"`
+	Usage          Usage  `json:"usage"`
+	Remaining      int64  `json:"remaining"`
+	RemainingCount int    `json:"remaining-count"`
+}
+
+// ExceededError is returned when a payment would go over a limit. It is sent to the client as
+// is, so it can tell which limit was hit and what is still allowed. Remaining and
+// RemainingCount are -1 when that limit is not set.
+type ExceededError struct {
+	Message        string `json:"Error"`
+	Limit          string `json:"limit"`
+	Max            int64  `json:"max"`
+	Remaining      int64  `json:"remaining"`
+	RemainingCount int    `json:"remaining-count"`
+}
+
+func (e *ExceededError) Error() string {
+	return e.Message
+}
+
+func (e *ExceededError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// RoleLimits are the limits for each role, used for accounts without limits of their own.
+type RoleLimits map[string]Limits
+
+// LoadRoleLimits reads the limits per role from a JSON file, e.g.
+// {"customer": {"per-transaction": 100000, "daily": 250000, "daily-count": 20}}.
+// A missing file means no role has any limits.
+func LoadRoleLimits(path string) (RoleLimits, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return RoleLimits{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	roleLimits := RoleLimits{}
+	if err := json.NewDecoder(file).Decode(&roleLimits); err != nil {
+		return nil, fmt.Errorf("invalid limits file %s : %v", path, err)
+	}
+	for role, limits := range roleLimits {
+		if err := Validate(limits); err != nil {
+			return nil, fmt.Errorf("limits for %s : %v", role, err)
+		}
+	}
+	return roleLimits, nil
+}
+
+// LimitsFile is where the limits per role are read from, set with the limitsFile environment variable.
+func LimitsFile() string {
+	if path := os.Getenv("limitsFile"); path != "" {
+		return path
+	}
+	return "./config/limits.json"
+}
+
+func Validate(limits Limits) error {
+	if limits.PerTransaction < 0 || limits.Daily < 0 || limits.DailyCount < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	return nil
+}
+
+// Remaining works out what is still allowed under limits after usage.
+func Remaining(limits Limits, usage Usage) Allowance {
+	allowance := Allowance{Limits: limits, Usage: usage, Remaining: -1, RemainingCount: -1}
+	if limits.Daily > 0 {
+		allowance.Remaining = max64(limits.Daily-usage.Amount, 0)
+	}
+	if limits.DailyCount > 0 {
+		allowance.RemainingCount = int(max64(int64(limits.DailyCount-usage.Count), 0))
+	}
+	return allowance
+}
+
+// Check reports the first limit a payment of amount would break, or nil if it is allowed.
+func Check(limits Limits, usage Usage, amount int64) error {
+	allowance := Remaining(limits, usage)
+	exceeded := func(name string, max int64, message string) error {
+		return &ExceededError{
+			Message:        message,
+			Limit:          name,
+			Max:            max,
+			Remaining:      allowance.Remaining,
+			RemainingCount: allowance.RemainingCount,
+		}
+	}
+	if limits.PerTransaction > 0 && amount > limits.PerTransaction {
+		return exceeded("per-transaction", limits.PerTransaction, fmt.Sprintf("amount is over the limit of %v per transaction", limits.PerTransaction))
+	}
+	if limits.DailyCount > 0 && usage.Count >= limits.DailyCount {
+		return exceeded("daily-count", int64(limits.DailyCount), fmt.Sprintf("only %v payments can be made in 24 hours", limits.DailyCount))
+	}
+	if limits.Daily > 0 && usage.Amount+amount > limits.Daily {
+		return exceeded("daily", limits.Daily, fmt.Sprintf("amount is over the 24 hour limit, %v is left", allowance.Remaining))
+	}
+	return nil
+}
+
+// IsLower reports whether every limit in requested is at least as strict as in current,
+// which is all an account holder may change on their own.
+func IsLower(current, requested Limits) bool {
+	return lower(current.PerTransaction, requested.PerTransaction) &&
+		lower(current.Daily, requested.Daily) &&
+		lower(int64(current.DailyCount), int64(requested.DailyCount))
+}
+
+func lower(current, requested int64) bool {
+	if requested == 0 {
+		return current == 0
+	}
+	return current == 0 || requested <= current
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -1,10 +1,26 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"strings"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/fee"
+	"github.com/Jasonasante/bankAPI.git/fraud"
+	"github.com/Jasonasante/bankAPI.git/interest"
+	"github.com/Jasonasante/bankAPI.git/limit"
+	"github.com/Jasonasante/bankAPI.git/sanctions"
+	"github.com/Jasonasante/bankAPI.git/stream"
 )
 
 func main() {
+	backfillFrom := flag.String("backfill-interest", "", "accrue interest for every day from this date (YYYY-MM-DD) to yesterday, then exit")
+	grantRole := flag.String("grant-role", "", "give an account a role, as username=role, then exit")
+	rebuild := flag.Bool("rebuild-projections", false, "rebuild the account and transfer tables from the event store, then exit")
+	verifyAudit := flag.Bool("verify-audit", false, "check the audit log has not been tampered with, then exit")
+	flag.Parse()
+
 	store, err := NewDB()
 	if err != nil {
 		log.Fatal(err)
@@ -12,6 +28,82 @@ func main() {
 	if err := store.Init(); err != nil {
 		log.Fatal(err)
 	}
+	rates, err := interest.LoadRates(interest.RatesFile())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := store.SaveInterestRates(rates); err != nil {
+		log.Fatal(err)
+	}
+	fees, err := fee.LoadSchedule(fee.ScheduleFile())
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.fees = fees
+	roleLimits, err := limit.LoadRoleLimits(limit.LimitsFile())
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.roleLimits = roleLimits
+	if err := store.fraud.Load(fraud.RulesFile()); err != nil {
+		log.Fatal(err)
+	}
+	if err := store.sanctions.Load(sanctions.ListFile()); err != nil {
+		log.Fatal(err)
+	}
+	if *rebuild {
+		accounts, transfers, err := store.RebuildProjections()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("rebuilt %d accounts and %d transfers from events", accounts, transfers)
+		return
+	}
+	if *verifyAudit {
+		checked, err := store.VerifyAuditLog()
+		if err != nil {
+			log.Fatalf("audit log of %d entries is broken : %v", checked, err)
+		}
+		log.Printf("audit log is intact : %d entries checked", checked)
+		return
+	}
+	if *grantRole != "" {
+		parts := strings.SplitN(*grantRole, "=", 2)
+		if len(parts) != 2 {
+			log.Fatal("grant-role must be given as username=role")
+		}
+		if err := store.SetRole(parts[0], parts[1]); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s is now %s", parts[0], parts[1])
+		return
+	}
+	if *backfillFrom != "" {
+		from, err := time.Parse(interest.DayFormat, *backfillFrom)
+		if err != nil {
+			log.Fatal(err)
+		}
+		now := time.Now().UTC()
+		yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+		if err := accrueInterest(store, from, yesterday); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("interest accrued from", *backfillFrom)
+		return
+	}
+	scheduler := NewScheduler(
+		standingOrderJob(store),
+		interestJob(store),
+		holdExpiryJob(store),
+		dormancyJob(store),
+		reviewExpiryJob(store),
+		amlMonitoringJob(store),
+		webhookJob(store),
+		statementJob(store),
+	)
+	scheduler.Start()
+	go store.fraud.Watch(fraud.RulesFile(), 10*time.Second)
+	go store.RelayEvents(stream.PollInterval())
 	server := NewAPIServer(":3500", store)
 	server.Run()
 }
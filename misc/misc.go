@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	uuid "github.com/satori/go.uuid"
@@ -36,8 +37,13 @@ func CheckPasswordHash(password, hash string) bool {
 }
 
 func GetID(r *http.Request) (int, error) {
+	return GetIntVar(r, "id")
+}
+
+// GetIntVar reads a numeric route variable other than the account id, e.g. {payee}.
+func GetIntVar(r *http.Request, name string) (int, error) {
 	vars := mux.Vars(r) // Extract route variables and returns it as a map[string]string
-	return strconv.Atoi(vars["id"])
+	return strconv.Atoi(vars[name])
 }
 
 func DefaultValue(value, defaultValue string) string {
@@ -47,9 +53,13 @@ func DefaultValue(value, defaultValue string) string {
 	return value
 }
 
-func DepositOrWithdrawal(value int) string {
-	if value < 0 {
-		return "withdrawal"
+// MaskName keeps the first letter of each part of a name and hides the rest,
+// e.g. "Jason Asante" becomes "J**** A*****".
+func MaskName(name string) string {
+	parts := strings.Fields(name)
+	for i, part := range parts {
+		runes := []rune(part)
+		parts[i] = string(runes[0]) + strings.Repeat("*", len(runes)-1)
 	}
-	return "deposit"
+	return strings.Join(parts, " ")
 }
@@ -0,0 +1,32 @@
+package notification
+
+import "time"
+
+// Kinds of notification an account holder can receive.
+const (
+	KindStandingOrderFailed = "standing-order-failed"
+	KindOverdraftEntered    = "overdraft-entered"
+	KindOverdraftLeft       = "overdraft-left"
+	KindTransferReversed    = "transfer-reversed"
+	KindAccountStatus       = "account-status"
+	KindApprovalRequested   = "approval-requested"
+	KindTransferReviewed    = "transfer-reviewed"
+	KindKYCStatus           = "kyc-status"
+)
+
+type Notification struct {
+	ID        int       `json:"id"`
+	AccountID int       `json:"-"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created-at"`
+}
+
+func CreateNotification(accountID int, kind, message string) *Notification {
+	return &Notification{
+		AccountID: accountID,
+		Kind:      kind,
+		Message:   message,
+		CreatedAt: time.Now().UTC(),
+	}
+}
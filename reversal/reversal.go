@@ -0,0 +1,60 @@
+package reversal
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const maxReasonLength = 140
+
+// Policies for reversing a transfer the recipient no longer has the money for. Under
+// PolicyReject the reversal fails, under PolicyAllowNegative the recipient is taken
+// into a negative balance.
+const (
+	PolicyReject        = "reject"
+	PolicyAllowNegative = "allow-negative"
+)
+
+type ReversalRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Reversal undoes a transfer between two accounts by moving the money back with a pair of
+// compensating ledger entries linked to the original ones. A transfer can only be reversed once.
+type Reversal struct {
+	ID         int       `json:"id"`
+	TransferID int       `json:"transfer-id"`
+	Reason     string    `json:"reason"`
+	ActorID    int       `json:"-"`
+	Amount     int       `json:"amount"`
+	Negative   bool      `json:"negative-balance"`
+	CreatedAt  time.Time `json:"created-at"`
+}
+
+func CreateReversal(transferID, actorID int, request *ReversalRequest) *Reversal {
+	return &Reversal{
+		TransferID: transferID,
+		Reason:     request.Reason,
+		ActorID:    actorID,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+func Validate(request *ReversalRequest) error {
+	if request.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if len([]rune(request.Reason)) > maxReasonLength {
+		return fmt.Errorf("reason cannot be longer than %d characters", maxReasonLength)
+	}
+	return nil
+}
+
+// Policy is the negative balance policy, read from the reversalPolicy environment variable.
+func Policy() string {
+	if os.Getenv("reversalPolicy") == PolicyAllowNegative {
+		return PolicyAllowNegative
+	}
+	return PolicyReject
+}
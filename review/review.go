@@ -0,0 +1,106 @@
+package review
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+)
+
+const (
+	defaultThreshold   = 1000000
+	defaultExpiryHours = 72
+)
+
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// Item is a transfer held back for a reviewer to check before it is sent. MakerID is the
+// account that asked for the transfer, which is not always the account it is sent from.
+type Item struct {
+	ID           int       `json:"id"`
+	AccountID    int       `json:"account-id"`
+	MakerID      int       `json:"maker-id"`
+	ToBankNumber int64     `json:"to-bank-number"`
+	Amount       int       `json:"amount"`
+	Memo         string    `json:"memo"`
+	Reference    string    `json:"reference"`
+	Category     string    `json:"category"`
+	Status       string    `json:"status"`
+	ReviewerID   int       `json:"reviewer-id"`
+	Comment      string    `json:"comment"`
+	CreatedAt    time.Time `json:"created-at"`
+	ExpiresAt    time.Time `json:"expires-at"`
+	DecidedAt    time.Time `json:"decided-at"`
+	Reason       string    `json:"reason"`
+}
+
+func CreateItem(accountID, makerID int, toBankNumber int64, request *transfer.TransferRequest, reason string) *Item {
+	now := time.Now().UTC()
+	return &Item{
+		AccountID:    accountID,
+		MakerID:      makerID,
+		ToBankNumber: toBankNumber,
+		Amount:       request.Amount,
+		Memo:         request.Memo,
+		Reference:    request.Reference,
+		Category:     request.Category,
+		Status:       StatusPending,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Duration(ExpiryHours()) * time.Hour),
+		Reason:       reason,
+	}
+}
+
+// TransferRequest is the transfer to make once the item is approved.
+func (i *Item) TransferRequest() *transfer.TransferRequest {
+	return &transfer.TransferRequest{
+		ToBankNumber: i.ToBankNumber,
+		Amount:       i.Amount,
+		Memo:         i.Memo,
+		Reference:    i.Reference,
+		Category:     i.Category,
+	}
+}
+
+func ValidateDecision(request *DecisionRequest) error {
+	if request.Comment == "" {
+		return fmt.Errorf("comment is required")
+	}
+	return nil
+}
+
+// Threshold is the amount from which transfers are held for review, read from the
+// reviewThreshold environment variable.
+func Threshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("reviewThreshold"))
+	if err != nil || threshold < 1 {
+		return defaultThreshold
+	}
+	return threshold
+}
+
+// ExpiryHours is how long an item waits for a reviewer before it expires, read from the
+// reviewExpiryHours environment variable.
+func ExpiryHours() int {
+	hours, err := strconv.Atoi(os.Getenv("reviewExpiryHours"))
+	if err != nil || hours < 1 {
+		return defaultExpiryHours
+	}
+	return hours
+}
+
+// NeedsReview reports whether a transfer of amount has to be reviewed before it is sent.
+func NeedsReview(amount int) bool {
+	return amount >= Threshold()
+}
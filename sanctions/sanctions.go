@@ -0,0 +1,392 @@
+package sanctions
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Jasonasante/bankAPI.git/misc"
+)
+
+// Statuses a hit moves through. An open hit is waiting on compliance, who either clear it as
+// a false match or confirm it.
+const (
+	HitOpen      = "open"
+	HitCleared   = "cleared"
+	HitConfirmed = "confirmed"
+)
+
+// Algorithms names can be compared with.
+const (
+	AlgorithmJaroWinkler = "jaro-winkler"
+	AlgorithmLevenshtein = "levenshtein"
+)
+
+const defaultThreshold = 0.9
+
+// Entry is one person or organisation on the list, with the other names they are known by.
+type Entry struct {
+	UID      string   `json:"uid"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Programs []string `json:"programs"`
+	Aliases  []string `json:"aliases"`
+}
+
+// Match is the entry a screened name came closest to, and how close it came from 0 to 1.
+type Match struct {
+	Entry *Entry  `json:"entry"`
+	Name  string  `json:"screened-name"`
+	Score float64 `json:"score"`
+}
+
+func (m *Match) Summary() string {
+	return fmt.Sprintf("%q matched %q (uid %s) with a score of %.2f", m.Name, m.Entry.Name, m.Entry.UID, m.Score)
+}
+
+// Hit is a name that matched the list, on an account being opened or on either side of a
+// transfer. A transfer's hit carries the review item the transfer is held under.
+type Hit struct {
+	ID           int       `json:"id"`
+	AccountID    int       `json:"account-id"`
+	ReviewID     int       `json:"review-id"`
+	ScreenedName string    `json:"screened-name"`
+	EntryUID     string    `json:"entry-uid"`
+	EntryName    string    `json:"entry-name"`
+	Score        float64   `json:"score"`
+	Status       string    `json:"status"`
+	ReviewerID   int       `json:"reviewer-id"`
+	Comment      string    `json:"comment"`
+	CreatedAt    time.Time `json:"created-at"`
+	DecidedAt    time.Time `json:"decided-at"`
+}
+
+// ListInfo describes the list currently loaded.
+type ListInfo struct {
+	Source   string    `json:"source"`
+	Entries  int       `json:"entries"`
+	LoadedAt time.Time `json:"loaded-at"`
+}
+
+func CreateHit(accountID int, match *Match) *Hit {
+	return &Hit{
+		AccountID:    accountID,
+		ScreenedName: match.Name,
+		EntryUID:     match.Entry.UID,
+		EntryName:    match.Entry.Name,
+		Score:        match.Score,
+		Status:       HitOpen,
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+func ValidateHitStatus(status string) error {
+	switch status {
+	case HitOpen, HitCleared, HitConfirmed:
+		return nil
+	}
+	return fmt.Errorf("invalid status %q : must be %s, %s or %s", status, HitOpen, HitCleared, HitConfirmed)
+}
+
+// List is the sanctions list names are screened against. It can be reloaded while in use.
+type List struct {
+	mu      sync.RWMutex
+	entries []*Entry
+	info    ListInfo
+}
+
+func NewList() *List {
+	return &List{entries: []*Entry{}}
+}
+
+// Load reads the list from a file and puts it in place of the current one. Files ending in
+// .xml are read as an OFAC SDN list and anything else as CSV. A missing file means an empty
+// list. On error the current list is kept.
+func (l *List) Load(path string) error {
+	entries := []*Entry{}
+	file, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		defer file.Close()
+		if strings.EqualFold(filepath.Ext(path), ".xml") {
+			entries, err = ReadSDN(file)
+		} else {
+			entries, err = ReadCSV(file)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid sanctions list %s : %v", path, err)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = entries
+	l.info = ListInfo{Source: path, Entries: len(entries), LoadedAt: time.Now().UTC()}
+	return nil
+}
+
+func (l *List) Info() ListInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.info
+}
+
+// Screen compares name with every name on the list and returns the closest match at or
+// above Threshold, or nil if there is none.
+func (l *List) Screen(name string) *Match {
+	screened := normalise(name)
+	if screened == "" {
+		return nil
+	}
+	similarity := Similarity()
+	threshold := Threshold()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var best *Match
+	for _, entry := range l.entries {
+		for _, listed := range append([]string{entry.Name}, entry.Aliases...) {
+			score := similarity(screened, normalise(listed))
+			if score >= threshold && (best == nil || score > best.Score) {
+				best = &Match{Entry: entry, Name: name, Score: score}
+			}
+		}
+	}
+	return best
+}
+
+// ReadCSV reads a list with a header row. The name column is required. The uid, type,
+// programs and aliases columns are optional, with programs and aliases separated by ";".
+func ReadCSV(r io.Reader) ([]*Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []*Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("missing name column")
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	entries := []*Entry{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := &Entry{
+			UID:      misc.DefaultValue(field(record, "uid"), strconv.Itoa(line)),
+			Name:     field(record, "name"),
+			Type:     field(record, "type"),
+			Programs: split(field(record, "programs")),
+			Aliases:  split(field(record, "aliases")),
+		}
+		if entry.Name == "" {
+			return nil, fmt.Errorf("line %d : name is required", line)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+type sdnList struct {
+	Entries []sdnEntry `xml:"sdnEntry"`
+}
+
+type sdnEntry struct {
+	UID       string   `xml:"uid"`
+	FirstName string   `xml:"firstName"`
+	LastName  string   `xml:"lastName"`
+	Type      string   `xml:"sdnType"`
+	Programs  []string `xml:"programList>program"`
+	Akas      []sdnAka `xml:"akaList>aka"`
+}
+
+type sdnAka struct {
+	FirstName string `xml:"firstName"`
+	LastName  string `xml:"lastName"`
+}
+
+// ReadSDN reads a list in the format of OFAC's Specially Designated Nationals XML file.
+func ReadSDN(r io.Reader) ([]*Entry, error) {
+	list := sdnList{}
+	if err := xml.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	entries := []*Entry{}
+	for _, sdn := range list.Entries {
+		entry := &Entry{
+			UID:      sdn.UID,
+			Name:     strings.TrimSpace(sdn.FirstName + " " + sdn.LastName),
+			Type:     sdn.Type,
+			Programs: sdn.Programs,
+			Aliases:  []string{},
+		}
+		for _, aka := range sdn.Akas {
+			entry.Aliases = append(entry.Aliases, strings.TrimSpace(aka.FirstName+" "+aka.LastName))
+		}
+		if entry.Name != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// ListFile is where the list is loaded from, read from the sanctionsListFile environment variable.
+func ListFile() string {
+	if path := os.Getenv("sanctionsListFile"); path != "" {
+		return path
+	}
+	return "./config/sanctions.csv"
+}
+
+// Threshold is the score from 0 to 1 at which a name counts as a match, read from the
+// sanctionsMatchThreshold environment variable.
+func Threshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("sanctionsMatchThreshold"), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return defaultThreshold
+	}
+	return threshold
+}
+
+// Similarity is the function names are compared with, chosen by the sanctionsMatchAlgorithm
+// environment variable. Jaro-Winkler is the default.
+func Similarity() func(a, b string) float64 {
+	if os.Getenv("sanctionsMatchAlgorithm") == AlgorithmLevenshtein {
+		return levenshtein
+	}
+	return jaroWinkler
+}
+
+// normalise lowercases a name, drops punctuation and sorts its words, so "HUSSEIN, Saddam"
+// and "Saddam Hussein" compare as the same name.
+func normalise(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+func split(value string) []string {
+	parts := []string{}
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	window := maxInt(len(s), len(t))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	sMatched, tMatched := make([]bool, len(s)), make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		for j := maxInt(0, i-window); j < len(t) && j <= i+window; j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+	prefix := 0
+	for prefix < 4 && prefix < len(s) && prefix < len(t) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// levenshtein is 1 less the edit distance between a and b as a share of the longer of them.
+func levenshtein(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	previous, current := make([]int, len(t)+1), make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(t)])/float64(maxInt(len(s), len(t)))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(values ...int) int {
+	smallest := values[0]
+	for _, v := range values[1:] {
+		if v < smallest {
+			smallest = v
+		}
+	}
+	return smallest
+}
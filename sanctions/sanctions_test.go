@@ -0,0 +1,120 @@
+package sanctions
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		score float64
+	}{
+		{"martha", "marhta", 0.9611},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.8133},
+		{"saddam hussein", "saddam hussein", 1},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+	for _, test := range tests {
+		if score := jaroWinkler(test.a, test.b); math.Abs(score-test.score) > 0.0001 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", test.a, test.b, score, test.score)
+		}
+		if score := jaroWinkler(test.b, test.a); math.Abs(score-test.score) > 0.0001 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", test.b, test.a, score, test.score)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		score float64
+	}{
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"flaw", "lawn", 0.5},
+		{"abbas", "abbas", 1},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+	for _, test := range tests {
+		if score := levenshtein(test.a, test.b); math.Abs(score-test.score) > 0.0001 {
+			t.Errorf("levenshtein(%q, %q) = %.4f, want %.4f", test.a, test.b, score, test.score)
+		}
+	}
+}
+
+func TestReadSDN(t *testing.T) {
+	file, err := os.Open("testdata/sdn.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	entries, err := ReadSDN(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{
+		{UID: "36", Name: "AEROCARIBBEAN AIRLINES", Type: "Entity", Programs: []string{"CUBA"}, Aliases: []string{"AERO-CARIBBEAN"}},
+		{UID: "2674", Name: "Abu ABBAS", Type: "Individual", Programs: []string{"SDGT", "IRAQ2"}, Aliases: []string{"Muhammad ZIYDAN", "Muhammad ABBAS"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %s, want %s", describe(entries), describe(want))
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "Name, UID, Programs, Aliases\n" +
+		"Abu Abbas, 2674, SDGT; IRAQ2, Muhammad Ziydan;Muhammad Abbas\n" +
+		"Aerocaribbean Airlines\n"
+	entries, err := ReadCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{
+		{UID: "2674", Name: "Abu Abbas", Programs: []string{"SDGT", "IRAQ2"}, Aliases: []string{"Muhammad Ziydan", "Muhammad Abbas"}},
+		{UID: "3", Name: "Aerocaribbean Airlines", Programs: []string{}, Aliases: []string{}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %s, want %s", describe(entries), describe(want))
+	}
+
+	for _, input := range []string{"uid,type\n1,Individual\n", "name,uid\n,1\n"} {
+		if _, err := ReadCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadCSV(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestScreen(t *testing.T) {
+	list := NewList()
+	if err := list.Load("testdata/sdn.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if info := list.Info(); info.Entries != 2 {
+		t.Fatalf("loaded %d entries, want 2", info.Entries)
+	}
+	// names are compared whatever their order and case, aliases included
+	match := list.Screen("ziydan, MUHAMMAD")
+	if match == nil || match.Entry.UID != "2674" || match.Score != 1 {
+		t.Fatalf("match = %+v, want entry 2674 with a score of 1", match)
+	}
+	if match := list.Screen("Abu Abas"); match == nil || match.Entry.UID != "2674" {
+		t.Errorf("match = %+v, want a close match on entry 2674", match)
+	}
+	if match := list.Screen("Jane Smith"); match != nil {
+		t.Errorf("match = %+v, want none", match)
+	}
+}
+
+func describe(entries []*Entry) string {
+	lines := []string{}
+	for _, entry := range entries {
+		lines = append(lines, strings.TrimSpace(strings.Join([]string{entry.UID, entry.Name, entry.Type, strings.Join(entry.Programs, ";"), strings.Join(entry.Aliases, ";")}, "|")))
+	}
+	return "[" + strings.Join(lines, ", ") + "]"
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/interest"
+	"github.com/Jasonasante/bankAPI.git/webhook"
+)
+
+// webhookBatchSize is how many deliveries are attempted each time the webhook job runs.
+const webhookBatchSize = 100
+
+// Job is a piece of background work the scheduler runs every Interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(now time.Time) error
+}
+
+type Scheduler struct {
+	jobs []Job
+}
+
+func NewScheduler(jobs ...Job) *Scheduler {
+	return &Scheduler{
+		jobs: jobs,
+	}
+}
+
+// Start runs every job straight away and then once per interval, each in its own goroutine.
+// Jobs have to be safe to run again after a restart, since nothing is remembered in memory.
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		go func(job Job) {
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+			for {
+				if err := job.Run(time.Now().UTC()); err != nil {
+					log.Printf("scheduled job %q failed : %v", job.Name, err)
+				}
+				<-ticker.C
+			}
+		}(job)
+	}
+}
+
+// standingOrderJob pays every standing order that has fallen due.
+func standingOrderJob(store Storage) Job {
+	return Job{
+		Name:     "standing orders",
+		Interval: time.Minute,
+		Run: func(now time.Time) error {
+			due, err := store.GetDueStandingOrders(now)
+			if err != nil {
+				return err
+			}
+			for _, id := range due {
+				if err := store.RunStandingOrder(id, now); err != nil {
+					log.Printf("could not run standing order %v : %v", id, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// interestJob accrues interest for every day that has ended since the last accrual, which
+// also catches up on the days missed while the server was down.
+func interestJob(store Storage) Job {
+	return Job{
+		Name:     "interest accrual",
+		Interval: time.Hour,
+		Run: func(now time.Time) error {
+			yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+			from := yesterday
+			last, ok, err := store.LastInterestDay()
+			if err != nil {
+				return err
+			}
+			if ok {
+				from = last.AddDate(0, 0, 1)
+			}
+			return accrueInterest(store, from, yesterday)
+		},
+	}
+}
+
+// holdExpiryJob releases card holds that were never captured.
+func holdExpiryJob(store Storage) Job {
+	return Job{
+		Name:     "hold expiry",
+		Interval: time.Hour,
+		Run:      store.ExpireHolds,
+	}
+}
+
+// reviewExpiryJob expires transfers that waited too long for a reviewer.
+func reviewExpiryJob(store Storage) Job {
+	return Job{
+		Name:     "review expiry",
+		Interval: time.Hour,
+		Run:      store.ExpireReviewItems,
+	}
+}
+
+// amlMonitoringJob raises alerts for cash deposits compliance need to look at.
+func amlMonitoringJob(store Storage) Job {
+	return Job{
+		Name:     "aml monitoring",
+		Interval: time.Hour,
+		Run:      store.MonitorTransfers,
+	}
+}
+
+// webhookJob moves new messages out of the outbox into deliveries and makes every delivery
+// that is due. A delivery that fails is retried later with backoff until it is dead.
+func webhookJob(store Storage) Job {
+	client := webhook.NewClient()
+	return Job{
+		Name:     "webhook delivery",
+		Interval: 5 * time.Second,
+		Run: func(now time.Time) error {
+			if err := store.DispatchOutbox(now); err != nil {
+				return err
+			}
+			attempts, err := store.GetDueWebhookAttempts(now, webhookBatchSize)
+			if err != nil {
+				return err
+			}
+			for _, attempt := range attempts {
+				statusCode, failure := webhook.Send(client, attempt, time.Now().UTC())
+				attempt.Delivery.Record(statusCode, failure, time.Now().UTC())
+				if err := store.RecordWebhookAttempt(attempt.Delivery); err != nil {
+					return err
+				}
+				if attempt.Delivery.Status == webhook.StatusDead {
+					log.Printf("webhook delivery %v is dead after %v attempts : %v", attempt.Delivery.ID, attempt.Delivery.Attempts, failure)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// dormancyJob makes accounts that have gone unused for too long dormant.
+func dormancyJob(store Storage) Job {
+	return Job{
+		Name:     "dormancy",
+		Interval: 24 * time.Hour,
+		Run:      store.MarkDormant,
+	}
+}
+
+// statementJob stores last month's statements once the month is over, ready to download.
+func statementJob(store Storage) Job {
+	return Job{
+		Name:     "monthly statements",
+		Interval: time.Hour,
+		Run:      store.GenerateMonthlyStatements,
+	}
+}
+
+// accrueInterest accrues every day from from to through, skipping days that are already done.
+func accrueInterest(store Storage, from, through time.Time) error {
+	for day := from; !day.After(through); day = day.AddDate(0, 0, 1) {
+		if err := store.AccrueInterest(day); err != nil {
+			return fmt.Errorf("accruing interest for %s : %v", day.Format(interest.DayFormat), err)
+		}
+	}
+	return nil
+}
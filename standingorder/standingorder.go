@@ -0,0 +1,186 @@
+package standingorder
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	FrequencyOnce    = "once"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultRetryDelay  = time.Hour
+)
+
+type CreateStandingOrderRequest struct {
+	ToBankNumber int64     `json:"to-bank-number"`
+	ToUsername   string    `json:"to-username"`
+	PayeeID      int       `json:"payee-id"`
+	Amount       int       `json:"amount"`
+	Memo         string    `json:"memo"`
+	Reference    string    `json:"reference"`
+	Category     string    `json:"category"`
+	Frequency    string    `json:"frequency"`
+	StartDate    time.Time `json:"start-date"`
+	DayOfMonth   int       `json:"day-of-month"`
+	EndDate      time.Time `json:"end-date"`
+	Occurrences  int       `json:"occurrences"`
+}
+
+type UpdateStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// StandingOrder pays a fixed amount to the same recipient on a schedule. NextRunAt is the
+// occurrence currently due and AttemptAt is when it is next tried, which moves on after
+// a failed attempt while NextRunAt stays put until the occurrence is paid or given up.
+type StandingOrder struct {
+	ID             int       `json:"id"`
+	AccountID      int       `json:"-"`
+	ToBankNumber   int64     `json:"to-bank-number"`
+	PayeeID        int       `json:"payee-id"`
+	Amount         int       `json:"amount"`
+	Memo           string    `json:"memo"`
+	Reference      string    `json:"reference"`
+	Category       string    `json:"category"`
+	Frequency      string    `json:"frequency"`
+	DayOfMonth     int       `json:"day-of-month"`
+	StartDate      time.Time `json:"start-date"`
+	EndDate        time.Time `json:"end-date"`
+	MaxOccurrences int       `json:"max-occurrences"`
+	Executed       int       `json:"executed"`
+	NextRunAt      time.Time `json:"next-run-at"`
+	AttemptAt      time.Time `json:"attempt-at"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"last-error"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created-at"`
+}
+
+func CreateStandingOrder(accountID int, toBankNumber int64, request *CreateStandingOrderRequest) *StandingOrder {
+	order := &StandingOrder{
+		AccountID:      accountID,
+		ToBankNumber:   toBankNumber,
+		PayeeID:        request.PayeeID,
+		Amount:         request.Amount,
+		Memo:           request.Memo,
+		Reference:      request.Reference,
+		Category:       request.Category,
+		Frequency:      request.Frequency,
+		DayOfMonth:     request.DayOfMonth,
+		StartDate:      request.StartDate.UTC(),
+		EndDate:        request.EndDate.UTC(),
+		MaxOccurrences: request.Occurrences,
+		Status:         StatusActive,
+		CreatedAt:      time.Now().UTC(),
+	}
+	order.NextRunAt = FirstRun(order)
+	order.AttemptAt = order.NextRunAt
+	return order
+}
+
+// Validate checks the schedule of a new standing order. The transfer details themselves
+// are checked by the same rules as any other transfer.
+func Validate(request *CreateStandingOrderRequest) error {
+	if request.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if request.StartDate.IsZero() {
+		return fmt.Errorf("start date is required")
+	}
+	switch request.Frequency {
+	case FrequencyOnce, FrequencyWeekly:
+	case FrequencyMonthly:
+		if request.DayOfMonth < 1 || request.DayOfMonth > 31 {
+			return fmt.Errorf("day of month must be between 1 and 31")
+		}
+	default:
+		return fmt.Errorf("invalid frequency %q : must be one of %s, %s or %s", request.Frequency, FrequencyOnce, FrequencyWeekly, FrequencyMonthly)
+	}
+	if request.Occurrences < 0 {
+		return fmt.Errorf("occurrences cannot be negative")
+	}
+	if !request.EndDate.IsZero() && request.EndDate.Before(request.StartDate) {
+		return fmt.Errorf("end date cannot be before the start date")
+	}
+	return nil
+}
+
+// FirstRun is the first time the order is due on or after its start date.
+func FirstRun(order *StandingOrder) time.Time {
+	if order.Frequency != FrequencyMonthly {
+		return order.StartDate
+	}
+	first := dayOfMonth(order.StartDate, order.DayOfMonth)
+	if first.Before(order.StartDate) {
+		first = dayOfMonth(order.StartDate.AddDate(0, 0, 1-order.StartDate.Day()).AddDate(0, 1, 0), order.DayOfMonth)
+	}
+	return first
+}
+
+// NextRun is the occurrence that follows the one due at previous.
+func NextRun(order *StandingOrder, previous time.Time) time.Time {
+	switch order.Frequency {
+	case FrequencyWeekly:
+		return previous.AddDate(0, 0, 7)
+	case FrequencyMonthly:
+		// step from the first of the month so a 31st doesn't overflow into the month after
+		firstOfMonth := previous.AddDate(0, 0, 1-previous.Day())
+		return dayOfMonth(firstOfMonth.AddDate(0, 1, 0), order.DayOfMonth)
+	}
+	return time.Time{}
+}
+
+// Finished reports whether an order whose next occurrence would be at next has nothing left to pay.
+func Finished(order *StandingOrder, next time.Time) bool {
+	if next.IsZero() {
+		return true
+	}
+	if order.MaxOccurrences > 0 && order.Executed >= order.MaxOccurrences {
+		return true
+	}
+	return !order.EndDate.IsZero() && next.After(order.EndDate)
+}
+
+// dayOfMonth moves t to day of its month, or the last day if the month is shorter.
+func dayOfMonth(t time.Time, day int) time.Time {
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(t.Year(), t.Month(), day, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
+
+// MaxAttempts is how many times an occurrence is tried before it is given up on,
+// read from the standingOrderAttempts environment variable.
+func MaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("standingOrderAttempts"))
+	if err != nil || attempts < 1 {
+		return defaultMaxAttempts
+	}
+	return attempts
+}
+
+// RetryDelay is how long to wait before trying a failed occurrence again,
+// read from the standingOrderRetryDelay environment variable, e.g. "30m".
+func RetryDelay() time.Duration {
+	delay, err := time.ParseDuration(os.Getenv("standingOrderRetryDelay"))
+	if err != nil || delay <= 0 {
+		return defaultRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,380 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+// Formats a statement can be downloaded in.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatPDF  = "pdf"
+)
+
+// DayFormat is how the days a statement covers are written, in requests and in the statement.
+const DayFormat = "2006-01-02"
+
+// The printed page: A4 in points, with text set in 8 point Courier so columns line up.
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 36
+	fontSize     = 8
+	lineHeight   = 11
+	linesPerPage = (pageHeight - 2*margin - 2*lineHeight) / lineHeight
+)
+
+// Line is one transaction on a statement. Balance is the account's balance straight after it.
+type Line struct {
+	TransferID  int       `json:"transfer-id"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Reference   string    `json:"reference"`
+	In          int64     `json:"in"`
+	Out         int64     `json:"out"`
+	Balance     int64     `json:"balance"`
+}
+
+// Statement is an account's transactions over a run of days, From to To inclusive.
+type Statement struct {
+	AccountID      int       `json:"account-id"`
+	Name           string    `json:"name"`
+	BankNumber     int64     `json:"bank-number"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening-balance"`
+	ClosingBalance int64     `json:"closing-balance"`
+	TotalIn        int64     `json:"total-in"`
+	TotalOut       int64     `json:"total-out"`
+	Lines          []*Line   `json:"lines"`
+	GeneratedAt    time.Time `json:"generated-at"`
+}
+
+// Stored is a statement generated ahead of time by the monthly job, kept with its files so it
+// reads the same however much later it is downloaded.
+type Stored struct {
+	ID             int       `json:"id"`
+	AccountID      int       `json:"account-id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening-balance"`
+	ClosingBalance int64     `json:"closing-balance"`
+	TotalIn        int64     `json:"total-in"`
+	TotalOut       int64     `json:"total-out"`
+	Transactions   int       `json:"transactions"`
+	GeneratedAt    time.Time `json:"generated-at"`
+	CSV            []byte    `json:"-"`
+	PDF            []byte    `json:"-"`
+}
+
+// Build puts together acc's statement from from to to, given its balance at the start of
+// from and its transfers over the period, oldest first.
+func Build(acc *account.Account, from, to time.Time, opening int64, transfers []*transfer.Transfer, now time.Time) *Statement {
+	st := &Statement{
+		AccountID:      acc.ID,
+		Name:           strings.TrimSpace(acc.FirstName + " " + acc.LastName),
+		BankNumber:     acc.BankNumber,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+		Lines:          []*Line{},
+		GeneratedAt:    now,
+	}
+	for _, t := range transfers {
+		line := &Line{
+			TransferID:  t.ID,
+			Date:        t.CompletedAt,
+			Description: Describe(t),
+			Reference:   t.Reference,
+			Balance:     t.CurrentBalance,
+		}
+		if change := t.CurrentBalance - t.PreviousBalance; change >= 0 {
+			line.In = change
+		} else {
+			line.Out = -change
+		}
+		st.TotalIn += line.In
+		st.TotalOut += line.Out
+		st.ClosingBalance = line.Balance
+		st.Lines = append(st.Lines, line)
+	}
+	return st
+}
+
+// Store keeps st with its CSV and PDF files.
+func Store(st *Statement) (*Stored, error) {
+	var csvFile, pdfFile bytes.Buffer
+	if err := WriteCSV(&csvFile, st); err != nil {
+		return nil, err
+	}
+	if err := WritePDF(&pdfFile, st); err != nil {
+		return nil, err
+	}
+	return &Stored{
+		AccountID:      st.AccountID,
+		From:           st.From,
+		To:             st.To,
+		OpeningBalance: st.OpeningBalance,
+		ClosingBalance: st.ClosingBalance,
+		TotalIn:        st.TotalIn,
+		TotalOut:       st.TotalOut,
+		Transactions:   len(st.Lines),
+		GeneratedAt:    st.GeneratedAt,
+		CSV:            csvFile.Bytes(),
+		PDF:            pdfFile.Bytes(),
+	}, nil
+}
+
+// File is the stored statement in format, which must be csv or pdf.
+func (s *Stored) File(format string) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return s.CSV, nil
+	case FormatPDF:
+		return s.PDF, nil
+	}
+	return nil, fmt.Errorf("invalid format %q : must be %s or %s", format, FormatCSV, FormatPDF)
+}
+
+// Describe says what a transfer was from the point of view of the account it belongs to.
+func Describe(t *transfer.Transfer) string {
+	description := t.Action
+	switch {
+	case t.From == t.To && t.Action == "deposit":
+		description = "Deposit"
+	case t.From == t.To && t.Action == "withdrawal":
+		description = "Withdrawal"
+	case t.Action == "interest":
+		description = "Interest"
+	case t.Action == "overdraft-interest":
+		description = "Overdraft interest"
+	case t.Action == "withdrawal":
+		description = fmt.Sprintf("Transfer to %d", t.ToBankNumber)
+	case t.Action == "deposit":
+		description = fmt.Sprintf("Transfer from %d", t.FromBankNumber)
+	case t.Action == "fee":
+		description = "Fee"
+	case t.Action == "fee-income":
+		description = "Fee income"
+	case t.Action == "reversal-debit", t.Action == "reversal-credit":
+		description = fmt.Sprintf("Reversal of %d", t.ReversalOf)
+	}
+	if t.Channel != "" {
+		description += " (" + t.Channel + ")"
+	}
+	return description
+}
+
+// ParsePeriod reads the days a statement covers from the from and to query parameters.
+// From is required and to defaults to today.
+func ParsePeriod(from, to string, now time.Time) (time.Time, time.Time, error) {
+	if from == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("from is required, as %s", DayFormat)
+	}
+	start, err := time.Parse(DayFormat, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be a day, as %s", DayFormat)
+	}
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if to != "" {
+		end, err = time.Parse(DayFormat, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be a day, as %s", DayFormat)
+		}
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to cannot be before from")
+	}
+	return start, end, nil
+}
+
+// LastMonth is the first and last day of the calendar month before now's.
+func LastMonth(now time.Time) (time.Time, time.Time) {
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return thisMonth.AddDate(0, -1, 0), thisMonth.AddDate(0, 0, -1)
+}
+
+// End is the moment a statement running to the day to stops, the start of the day after.
+func End(to time.Time) time.Time {
+	return to.AddDate(0, 0, 1)
+}
+
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatCSV, FormatPDF:
+		return nil
+	}
+	return fmt.Errorf("invalid format %q : must be %s, %s or %s", format, FormatJSON, FormatCSV, FormatPDF)
+}
+
+// ContentType is the media type of a statement file in format.
+func ContentType(format string) string {
+	if format == FormatPDF {
+		return "application/pdf"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename is what a statement file is saved as, e.g. statement-12345678-2024-01-01-2024-01-31.pdf.
+func Filename(bankNumber int64, from, to time.Time, format string) string {
+	return fmt.Sprintf("statement-%d-%s-%s.%s", bankNumber, from.Format(DayFormat), to.Format(DayFormat), format)
+}
+
+// WriteCSV writes the statement as CSV: the opening balance, a row for each transaction with
+// the running balance, the closing balance, and the totals in and out.
+func WriteCSV(w io.Writer, st *Statement) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"date", "transfer-id", "description", "reference", "in", "out", "balance"})
+	out.Write([]string{st.From.Format(DayFormat), "", "Opening balance", "", "", "", strconv.FormatInt(st.OpeningBalance, 10)})
+	for _, line := range st.Lines {
+		out.Write([]string{
+			line.Date.UTC().Format(time.RFC3339),
+			strconv.Itoa(line.TransferID),
+			line.Description,
+			line.Reference,
+			amount(line.In),
+			amount(line.Out),
+			strconv.FormatInt(line.Balance, 10),
+		})
+	}
+	out.Write([]string{st.To.Format(DayFormat), "", "Closing balance", "", "", "", strconv.FormatInt(st.ClosingBalance, 10)})
+	out.Write([]string{st.To.Format(DayFormat), "", "Totals", "", strconv.FormatInt(st.TotalIn, 10), strconv.FormatInt(st.TotalOut, 10), ""})
+	out.Flush()
+	return out.Error()
+}
+
+// WritePDF writes the statement as a printable PDF. The document is put together by hand
+// rather than with a library: it only needs the built-in Courier fonts, which every PDF
+// reader has, so nothing has to be embedded.
+func WritePDF(w io.Writer, st *Statement) error {
+	type text struct {
+		value string
+		bold  bool
+	}
+	header := []text{
+		{"ACCOUNT STATEMENT", true},
+		{fmt.Sprintf("Name:             %s", st.Name), false},
+		{fmt.Sprintf("Account number:   %d", st.BankNumber), false},
+		{fmt.Sprintf("Period:           %s to %s", st.From.Format(DayFormat), st.To.Format(DayFormat)), false},
+		{fmt.Sprintf("Generated:        %s", st.GeneratedAt.UTC().Format(time.RFC3339)), false},
+		{"", false},
+		{fmt.Sprintf("Opening balance:  %d", st.OpeningBalance), false},
+		{fmt.Sprintf("Money in:         %d", st.TotalIn), false},
+		{fmt.Sprintf("Money out:        %d", st.TotalOut), false},
+		{fmt.Sprintf("Closing balance:  %d", st.ClosingBalance), true},
+		{"", false},
+	}
+	columns := text{row("Date", "Transfer", "Description", "Reference", "In", "Out", "Balance"), true}
+	rows := []text{}
+	for _, line := range st.Lines {
+		rows = append(rows, text{row(
+			line.Date.UTC().Format(DayFormat),
+			strconv.Itoa(line.TransferID),
+			line.Description,
+			line.Reference,
+			amount(line.In),
+			amount(line.Out),
+			strconv.FormatInt(line.Balance, 10),
+		), false})
+	}
+	if len(rows) == 0 {
+		rows = append(rows, text{"No transactions in this period.", false})
+	}
+
+	// the column headings are repeated at the top of every page the table runs on to
+	pages := [][]text{append(append([]text{}, header...), columns)}
+	for _, r := range rows {
+		if len(pages[len(pages)-1]) == linesPerPage {
+			pages = append(pages, []text{columns})
+		}
+		pages[len(pages)-1] = append(pages[len(pages)-1], r)
+	}
+
+	var doc bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, doc.Len())
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := []string{}
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, lineHeight, margin, pageHeight-margin-fontSize)
+		for _, t := range page {
+			font := "/F1"
+			if t.bold {
+				font = "/F2"
+			}
+			fmt.Fprintf(&content, "%s %d Tf\n(%s) Tj\nT*\n", font, fontSize, escape(t.value))
+		}
+		fmt.Fprintf(&content, "ET\nBT\n/F1 %d Tf\n%d %d Td\n(%s) Tj\nET", fontSize, margin, margin, escape(fmt.Sprintf("Page %d of %d", i+1, len(pages))))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// row lays out one line of the printed transaction table in fixed width columns.
+func row(date, id, description, reference, in, out, balance string) string {
+	return fmt.Sprintf("%-10s %8s  %-30s %-16s %11s %11s %13s",
+		date, id, truncate(description, 30), truncate(reference, 16), in, out, balance)
+}
+
+// amount is how money in or out is written in a table, blank when there was none.
+func amount(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length-1] + "~"
+}
+
+// escape makes s safe to put in a PDF string. Anything outside printable ASCII is replaced,
+// since the fonts are not embedded.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/transfer"
+)
+
+var (
+	from = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to   = time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// sample is a statement of n deposits of 100 each, starting from an opening balance of 500.
+func sample(n int) *Statement {
+	acc := &account.Account{ID: 3, FirstName: "Bob", LastName: "(Brown)", BankNumber: 27222287}
+	transfers := []*transfer.Transfer{}
+	balance := int64(500)
+	for i := 0; i < n; i++ {
+		transfers = append(transfers, &transfer.Transfer{
+			ID:              i + 1,
+			From:            acc.ID,
+			To:              acc.ID,
+			Amount:          100,
+			Action:          "deposit",
+			Channel:         transfer.ChannelCash,
+			Reference:       fmt.Sprintf(`ref\%d (cash)`, i),
+			PreviousBalance: balance,
+			CurrentBalance:  balance + 100,
+			CompletedAt:     from.Add(time.Duration(i) * time.Minute),
+		})
+		balance += 100
+	}
+	return Build(acc, from, End(to), 500, transfers, to)
+}
+
+func TestBuildTotals(t *testing.T) {
+	st := sample(3)
+	if st.OpeningBalance != 500 || st.TotalIn != 300 || st.TotalOut != 0 || st.ClosingBalance != 800 {
+		t.Errorf("statement = opening %d, in %d, out %d, closing %d, want 500, 300, 0, 800",
+			st.OpeningBalance, st.TotalIn, st.TotalOut, st.ClosingBalance)
+	}
+	if len(st.Lines) != 3 || st.Lines[2].Balance != 800 {
+		t.Errorf("got %d lines, want 3 ending on a balance of 800", len(st.Lines))
+	}
+}
+
+var (
+	objectHeader = regexp.MustCompile(`^(\d+) 0 obj\n`)
+	startXref    = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	xrefHeader   = regexp.MustCompile(`^xref\n0 (\d+)\n`)
+	xrefEntry    = regexp.MustCompile(`^(\d{10}) (\d{5}) ([nf]) \n`)
+	streamLength = regexp.MustCompile(`<< /Length (\d+) >>\nstream\n`)
+)
+
+// TestWritePDFCrossReference checks every offset in the cross reference table points at
+// the start of the object it is for, and every stream is as long as it says, which is what
+// readers rely on to open the file without repairing it.
+func TestWritePDFCrossReference(t *testing.T) {
+	for _, n := range []int{0, 1, linesPerPage * 2} {
+		t.Run(fmt.Sprintf("%d lines", n), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WritePDF(&buf, sample(n)); err != nil {
+				t.Fatal(err)
+			}
+			doc := buf.Bytes()
+			if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) {
+				t.Fatalf("missing PDF header")
+			}
+			match := startXref.FindSubmatch(doc)
+			if match == nil {
+				t.Fatalf("missing startxref")
+			}
+			xref, _ := strconv.Atoi(string(match[1]))
+			if xref >= len(doc) {
+				t.Fatalf("startxref %d is past the end of the file", xref)
+			}
+			table := doc[xref:]
+			header := xrefHeader.FindSubmatch(table)
+			if header == nil {
+				t.Fatalf("startxref %d does not point at the xref table", xref)
+			}
+			size, _ := strconv.Atoi(string(header[1]))
+			table = table[len(header[0]):]
+			for object := 0; object < size; object++ {
+				entry := xrefEntry.FindSubmatch(table)
+				if entry == nil {
+					t.Fatalf("xref entry %d is malformed : %q", object, table[:20])
+				}
+				table = table[len(entry[0]):]
+				if object == 0 {
+					if string(entry[3]) != "f" {
+						t.Errorf("xref entry 0 should be free")
+					}
+					continue
+				}
+				offset, _ := strconv.Atoi(string(entry[1]))
+				found := objectHeader.FindSubmatch(doc[offset:])
+				if found == nil || string(found[1]) != strconv.Itoa(object) {
+					t.Errorf("xref entry %d points at offset %d, which starts %q", object, offset, doc[offset:offset+10])
+				}
+			}
+			if !bytes.HasPrefix(table, []byte(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>", size))) {
+				t.Errorf("trailer does not follow the xref table or has the wrong size")
+			}
+			for _, loc := range streamLength.FindAllSubmatchIndex(doc, -1) {
+				length, _ := strconv.Atoi(string(doc[loc[2]:loc[3]]))
+				end := loc[1] + length
+				if !bytes.HasPrefix(doc[end:], []byte("\nendstream")) {
+					t.Errorf("stream at %d is not %d bytes long", loc[0], length)
+				}
+			}
+			pages := bytes.Count(doc, []byte("/Type /Page /Parent"))
+			if !bytes.Contains(doc, []byte(fmt.Sprintf("/Count %d >>", pages))) {
+				t.Errorf("page tree does not count the %d pages", pages)
+			}
+			if n > linesPerPage && pages < 2 {
+				t.Errorf("%d lines fit on %d page", n, pages)
+			}
+		})
+	}
+}
@@ -2,36 +2,200 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Jasonasante/bankAPI.git/account"
+	"github.com/Jasonasante/bankAPI.git/aml"
+	"github.com/Jasonasante/bankAPI.git/audit"
+	"github.com/Jasonasante/bankAPI.git/beneficiary"
+	"github.com/Jasonasante/bankAPI.git/event"
+	"github.com/Jasonasante/bankAPI.git/export"
+	"github.com/Jasonasante/bankAPI.git/fee"
+	"github.com/Jasonasante/bankAPI.git/fraud"
+	"github.com/Jasonasante/bankAPI.git/hold"
+	"github.com/Jasonasante/bankAPI.git/interest"
+	"github.com/Jasonasante/bankAPI.git/joint"
+	"github.com/Jasonasante/bankAPI.git/kyc"
+	"github.com/Jasonasante/bankAPI.git/limit"
 	"github.com/Jasonasante/bankAPI.git/misc"
+	"github.com/Jasonasante/bankAPI.git/notification"
+	"github.com/Jasonasante/bankAPI.git/reversal"
+	"github.com/Jasonasante/bankAPI.git/review"
+	"github.com/Jasonasante/bankAPI.git/sanctions"
+	"github.com/Jasonasante/bankAPI.git/standingorder"
+	"github.com/Jasonasante/bankAPI.git/statement"
+	"github.com/Jasonasante/bankAPI.git/stream"
 	"github.com/Jasonasante/bankAPI.git/transfer"
+	"github.com/Jasonasante/bankAPI.git/webhook"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type Storage interface {
-	CreateAccount(*account.Account) error
-	DeleteAccount(int) error
+	As(meta audit.Meta) Storage
+	GetAuditLog(filter *audit.Filter) ([]*audit.Entry, error)
+	VerifyAuditLog() (int, error)
+	GetEvents(after, limit int) ([]*event.Event, error)
+	GetAccountAt(id int, at time.Time) (*account.Account, error)
+	RebuildProjections() (accounts int, transfers int, err error)
+	CreateWebhookSubscription(sub *webhook.Subscription) error
+	GetWebhookSubscriptions(clientID int) ([]*webhook.Subscription, error)
+	GetWebhookSubscription(clientID, id int) (*webhook.Subscription, error)
+	DisableWebhookSubscription(clientID, id int) error
+	GetWebhookDeliveries(clientID, subscriptionID int, status string) ([]*webhook.Delivery, error)
+	RedeliverWebhook(clientID, deliveryID int) (*webhook.Delivery, error)
+	DispatchOutbox(now time.Time) error
+	GetDueWebhookAttempts(now time.Time, limit int) ([]*webhook.Attempt, error)
+	RecordWebhookAttempt(delivery *webhook.Delivery) error
+	GetStreamEvents(accountIDs []int, after, limit int) ([]*event.Event, error)
+	SubscribeEvents(accountIDs []int) *stream.Subscription
+	GetStatement(id int, from, to time.Time) (*statement.Statement, error)
+	GenerateMonthlyStatements(now time.Time) error
+	GetStoredStatements(accountID int) ([]*statement.Stored, error)
+	GetStoredStatement(accountID, id int) (*statement.Stored, error)
+	GetExport(id int, from, to time.Time) (*export.Ledger, error)
+	CreateAccount(*account.Account, *kyc.ProfileRequest) error
+	CloseAccount(id int, request *account.CloseRequest) error
+	SetAccountStatus(id int, status string) error
+	MarkDormant(now time.Time) error
 	UpdateAccount(id int, update *account.UpdateAccountRequest) error
 	GetAccountByID(int) (*account.Account, error)
-	GetAllAccounts() ([]*account.Account, error)
+	GetAllAccounts(status string) ([]*account.Account, error)
 	VerifyLogin(account.LoginRequest) (*account.Account, error)
 	CreateTransfer(trans *transfer.Transfer) error
 	GetAccountBalance(id int) (*transfer.MyBalance, error)
-	DepositWithdrawIntoMyAccount(id int, deposit *transfer.TransferRequest) (*transfer.MyBalance, error)
-	Transfer(id int, request *transfer.TransferRequest) (*transfer.TransferResponse, error)
+	Deposit(id int, request *transfer.DepositRequest) (*transfer.MyBalance, error)
+	Withdraw(id int, request *transfer.WithdrawalRequest) (*transfer.MyBalance, error)
+	ResolveRecipient(bankNumber int64, username string) (*account.Account, error)
+	Transfer(id, actorID int, request *transfer.TransferRequest) (*transfer.TransferResponse, error)
+	AddOwner(accountID int, username string) (*joint.Owner, error)
+	GetOwners(accountID int) ([]*joint.Owner, error)
+	IsOwner(accountID, ownerID int) (bool, error)
+	SetSigningRule(rule *joint.SigningRule) error
+	GetSigningRule(accountID int) (*joint.SigningRule, error)
+	GetPendingTransfers(accountID int) ([]*joint.PendingTransfer, error)
+	DecidePendingTransfer(accountID, pendingID, ownerID int, decision, comment string) (*joint.PendingTransfer, error)
+	GetReviewItems(status string) ([]*review.Item, error)
+	GetAccountReviewItems(accountID int) ([]*review.Item, error)
+	DecideReviewItem(id, reviewerID int, approve bool, comment string) (*review.Item, error)
+	ExpireReviewItems(now time.Time) error
+	GetFraudDecisions(accountID int) ([]*fraud.Assessment, error)
+	ReloadSanctionsList() (sanctions.ListInfo, error)
+	SanctionsListInfo() sanctions.ListInfo
+	GetSanctionsHits(status string) ([]*sanctions.Hit, error)
+	DecideSanctionsHit(id, reviewerID int, clear bool, comment string) (*sanctions.Hit, error)
+	MonitorTransfers(now time.Time) error
+	GetAMLAlerts(status string) ([]*aml.Alert, error)
+	GetAMLAlert(id int) (*aml.Alert, error)
+	UpdateAMLAlert(id, actorID int, request *aml.UpdateAlertRequest) (*aml.Alert, error)
+	GetAMLReport(id int) (*aml.Report, error)
+	GetKYC(accountID int) (*kyc.Record, error)
+	SaveKYCProfile(accountID int, request *kyc.ProfileRequest) (*kyc.Profile, error)
+	AddKYCDocument(accountID int, docType, filename string, content io.Reader) (*kyc.Document, error)
+	OpenKYCDocument(accountID, documentID int) (*kyc.Document, io.ReadCloser, error)
+	SubmitKYC(accountID int) (*kyc.Profile, error)
+	GetKYCProfiles(status string) ([]*kyc.Profile, error)
+	DecideKYC(accountID, reviewerID int, verify bool, comment string) (*kyc.Profile, error)
+	CreateBeneficiary(b *beneficiary.Beneficiary) error
+	GetBeneficiaries(accountID int) ([]*beneficiary.Beneficiary, error)
+	RenameBeneficiary(accountID, id int, nickname string) error
+	DeleteBeneficiary(accountID, id int) error
+	GetBeneficiary(accountID, id int) (*beneficiary.Beneficiary, error)
+	CreateStandingOrder(order *standingorder.StandingOrder) error
+	GetStandingOrders(accountID int) ([]*standingorder.StandingOrder, error)
+	UpdateStandingOrderStatus(accountID, id int, status string) error
+	GetDueStandingOrders(now time.Time) ([]int, error)
+	RunStandingOrder(id int, now time.Time) error
+	GetNotifications(accountID int) ([]*notification.Notification, error)
+	SaveInterestRates(rates []interest.Rate) error
+	LastInterestDay() (time.Time, bool, error)
+	AccrueInterest(day time.Time) error
+	GetInterestAccruals(accountID int) ([]*interest.Accrual, error)
+	QuoteFee(id int, operation string, amount int) (*fee.Quote, error)
+	GetAccountByBankNumber(bankNumber int64) (*account.Account, error)
+	SetOverdraftLimit(id int, limit int64) error
+	SetRole(username, role string) error
+	GetAllowance(id int) (*limit.Allowance, error)
+	SetLimits(id int, limits limit.Limits, onlyLower bool) error
+	CreateHold(h *hold.Hold) error
+	GetHold(id int) (*hold.Hold, error)
+	GetHolds(accountID int) ([]*hold.Hold, error)
+	CaptureHold(id int, amount int) (*hold.Hold, error)
+	ReleaseHold(id int) (*hold.Hold, error)
+	ExpireHolds(now time.Time) error
+	ReverseTransfer(r *reversal.Reversal) error
 	GetAllTransfers() ([]*transfer.Transfer, error)
-	GetMyTransfers(id int) ([]*transfer.Transfer, error)
+	GetMyTransfers(id int, filter *transfer.HistoryFilter) ([]*transfer.Transfer, error)
+	UpdateTransferCategory(accountID, transferID int, category string) error
 }
 
 type QueryResult interface {
 	Scan(dest ...interface{}) error
 }
 
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so helpers can run either on their own
+// or as part of a larger transaction.
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// storeTx is a transaction on the store. The events appended in it are published to the
+// stream once it commits, and dropped with it if it rolls back.
+type storeTx struct {
+	*sql.Tx
+	publisher *publisher
+	events    []*event.Event
+}
+
+func (s *SQLiteStore) begin() (*storeTx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &storeTx{Tx: tx, publisher: s.publisher}, nil
+}
+
+// Commit commits the transaction, then publishes its events.
+func (tx *storeTx) Commit() error {
+	return tx.publisher.commit(tx)
+}
+
+// savepoint starts a savepoint called name. It returns how many events the transaction has
+// so far, for rollbackTo to drop the ones appended after it.
+func (tx *storeTx) savepoint(name string) (int, error) {
+	_, err := tx.Exec(fmt.Sprintf(`SAVEPOINT "%s"`, name))
+	return len(tx.events), err
+}
+
+// rollbackTo undoes everything since the savepoint called name, including the events that
+// would otherwise still be published when the transaction commits.
+func (tx *storeTx) rollbackTo(name string, events int) error {
+	if _, err := tx.Exec(fmt.Sprintf(`ROLLBACK TO "%s"`, name)); err != nil {
+		return err
+	}
+	tx.events = tx.events[:events]
+	return nil
+}
+
 type SQLiteStore struct {
-	db *sql.DB
+	db           *sql.DB
+	fees         *fee.Schedule
+	feeAccountID int
+	roleLimits   limit.RoleLimits
+	fraud        *fraud.Engine
+	sanctions    *sanctions.List
+	documents    *kyc.BlobStore
+	publisher    *publisher
+	meta         *audit.Meta
 }
 
 //
@@ -39,13 +203,21 @@ type SQLiteStore struct {
 //
 
 func NewDB() (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite3", "./db/bankApi.db")
+	// _txlock=immediate takes the write lock when a transaction begins, so two balance
+	// updates can never read the same starting balance.
+	db, err := sql.Open("sqlite3", "./db/bankApi.db?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	return &SQLiteStore{
-		db: db,
+		db:         db,
+		fees:       &fee.Schedule{},
+		roleLimits: limit.RoleLimits{},
+		fraud:      fraud.NewEngine(),
+		sanctions:  sanctions.NewList(),
+		documents:  kyc.NewBlobStore(kyc.BlobDir()),
+		publisher:  &publisher{db: db, bus: stream.NewBus()},
 	}, nil
 }
 
@@ -57,9 +229,138 @@ func (s *SQLiteStore) Init() error {
 	if err := s.CreateTransferTable(); err != nil {
 		return err
 	}
+	if err := s.addColumn("transfer", "channel", `TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "reference", `TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "from_bank_number", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "to_bank_number", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "memo", `TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "category", `TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "reversal_of", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "reversed_by", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.addColumn("transfer", "counterpart_id", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.backfillTransferBankNumbers(); err != nil {
+		return err
+	}
+	if err := s.backfillTransferCounterparts(); err != nil {
+		return err
+	}
+	if err := s.CreateBeneficiaryTable(); err != nil {
+		return err
+	}
+	if err := s.CreateStandingOrderTables(); err != nil {
+		return err
+	}
+	if err := s.CreateNotificationTable(); err != nil {
+		return err
+	}
+	if err := s.addColumn("account", "account_type", `TEXT NOT NULL DEFAULT 'current'`); err != nil {
+		return err
+	}
+	if err := s.CreateInterestTables(); err != nil {
+		return err
+	}
+	if err := s.addColumn("account", "role", `TEXT NOT NULL DEFAULT 'customer'`); err != nil {
+		return err
+	}
+	if err := s.addColumn("account", "overdraft_limit", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.addColumn("account", "status", `TEXT NOT NULL DEFAULT 'active'`); err != nil {
+		return err
+	}
+	if err := s.addColumn("account", "status_changed_at", `TIMESTAMP`); err != nil {
+		return err
+	}
+	if err := s.CreateLimitTable(); err != nil {
+		return err
+	}
+	if err := s.CreateHoldTable(); err != nil {
+		return err
+	}
+	if err := s.CreateReversalTable(); err != nil {
+		return err
+	}
+	if err := s.CreateJointTables(); err != nil {
+		return err
+	}
+	if err := s.CreateReviewTable(); err != nil {
+		return err
+	}
+	if err := s.CreateFraudDecisionTable(); err != nil {
+		return err
+	}
+	if err := s.CreateSanctionsHitTable(); err != nil {
+		return err
+	}
+	if err := s.CreateAMLTables(); err != nil {
+		return err
+	}
+	if err := s.CreateKYCTables(); err != nil {
+		return err
+	}
+	if err := s.CreateAuditTable(); err != nil {
+		return err
+	}
+	if err := s.CreateEventTable(); err != nil {
+		return err
+	}
+	if err := s.seedEvents(); err != nil {
+		return err
+	}
+	if err := s.startPublishing(); err != nil {
+		return err
+	}
+	if err := s.CreateWebhookTables(); err != nil {
+		return err
+	}
+	if err := s.CreateStatementTable(); err != nil {
+		return err
+	}
+	if err := s.ensureFeeIncomeAccount(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// addColumn brings tables created by older versions up to date. The column is only
+// added when it is missing, so it is safe to call on every start up.
+func (s *SQLiteStore) addColumn(table, column, definition string) error {
+	row, err := s.db.Query(`SELECT "name" FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer row.Close()
+	for row.Next() {
+		var name string
+		if err := row.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, table, column, definition))
+	return err
+}
+
 func (s *SQLiteStore) DropTable(name string) error {
 	_, tblErr := s.db.Exec("DROP TABLE " + name)
 
@@ -84,7 +385,12 @@ func (s *SQLiteStore) CreateAccountTable() error {
 			"password" VARCHAR(64) NOT NULL,
 			"bank_number" NUMBER NOT NULL UNIQUE,
 			"balance" NUMBER,
-			"created_at" TIMESTAMP
+			"created_at" TIMESTAMP,
+			"account_type" TEXT NOT NULL DEFAULT 'current',
+			"role" TEXT NOT NULL DEFAULT 'customer',
+			"overdraft_limit" INTEGER NOT NULL DEFAULT 0,
+			"status" TEXT NOT NULL DEFAULT 'active',
+			"status_changed_at" TIMESTAMP
 		)`,
 	)
 	if acctTblErr != nil {
@@ -93,8 +399,21 @@ func (s *SQLiteStore) CreateAccountTable() error {
 	return nil
 }
 
-func (s *SQLiteStore) CreateAccount(acc *account.Account) error {
-	stmt, err := s.db.Prepare(`
+// CreateAccount opens an account, screening its holder's name against the sanctions list
+// first. An account whose holder matches is opened in compliance review. The holder's KYC
+// profile, when given, is saved along with the account.
+func (s *SQLiteStore) CreateAccount(acc *account.Account, request *kyc.ProfileRequest) error {
+	match := s.sanctions.Screen(fullName(acc))
+	if match != nil {
+		acc.Status = account.StatusComplianceReview
+	}
+	acc.StatusChanged = acc.CreatedAt
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`
 	INSERT INTO "account" (
 	"first_name",
 	"last_name",
@@ -102,13 +421,18 @@ func (s *SQLiteStore) CreateAccount(acc *account.Account) error {
 	"password",
 	"bank_number" ,
 	"balance" ,
-	"created_at") values ( ?, ?, ?, ?, ?, ?,?)
+	"created_at",
+	"account_type",
+	"role",
+	"overdraft_limit",
+	"status",
+	"status_changed_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		fmt.Println("error preparing account table:", err)
 		return err
 	}
-	_, errorWithTable := stmt.Exec(
+	result, errorWithTable := stmt.Exec(
 		acc.FirstName,
 		acc.LastName,
 		acc.Username,
@@ -116,29 +440,253 @@ func (s *SQLiteStore) CreateAccount(acc *account.Account) error {
 		acc.BankNumber,
 		acc.Balance,
 		acc.CreatedAt,
+		acc.Type,
+		acc.Role,
+		acc.OverdraftLimit,
+		acc.Status,
+		acc.StatusChanged,
 	)
 	if errorWithTable != nil {
 		fmt.Println("error adding to account table:", errorWithTable)
 		return errorWithTable
 	}
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	acc.ID = int(id)
+	opened, err := event.Opened(acc, acc.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := appendEvent(tx, opened); err != nil {
+		return err
+	}
+	if match != nil {
+		if err := insertSanctionsHit(tx, sanctions.CreateHit(acc.ID, match)); err != nil {
+			return err
+		}
+	}
+	if err := s.audit(tx, "account.create", audit.TargetAccount, acc.ID, nil, acc); err != nil {
+		return err
+	}
+	if request != nil {
+		profile := kyc.EmptyProfile(acc.ID)
+		before := *profile
+		profile.Apply(request, acc.CreatedAt)
+		if err := saveKYCProfile(tx, profile); err != nil {
+			return err
+		}
+		if err := s.audit(tx, "kyc.update", audit.TargetKYC, acc.ID, &before, profile); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CloseAccount closes an account instead of deleting it, so its transfers keep pointing at
+// something. A balance left in the account is first paid out to another account, and its
+// standing orders are cancelled.
+func (s *SQLiteStore) CloseAccount(id int, request *account.CloseRequest) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	if err := account.ValidateTransition(acc.Status, account.StatusClosed); err != nil {
+		return err
+	}
+	before := *acc
+	held, err := heldAmount(tx, id)
+	if err != nil {
+		return err
+	}
+	if held > 0 {
+		return fmt.Errorf("account has card payments pending and cannot be closed yet")
+	}
+	switch {
+	case acc.Balance < 0:
+		return fmt.Errorf("account is overdrawn by %v and cannot be closed until it is repaid", -acc.Balance)
+	case acc.Balance > 0 && request.PayoutBankNumber == 0 && request.PayoutUsername == "":
+		return fmt.Errorf("account balance of %v must be paid out to another account before closing", acc.Balance)
+	case acc.Balance > 0:
+		if err := payOut(tx, acc, request); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE "standing_order" SET "status" = ? WHERE "account_id" = ? AND "status" IN (?, ?)`,
+		standingorder.StatusCancelled, id, standingorder.StatusActive, standingorder.StatusPaused); err != nil {
+		return err
+	}
+	if err := updateStatus(tx, acc, account.StatusClosed, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := s.audit(tx, "account.close", audit.TargetAccount, acc.ID, &before, acc); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *SQLiteStore) DeleteAccount(id int) error {
-	_, err := s.db.Exec(`DELETE FROM "account" WHERE id = ?`, id)
+// payOut moves everything left in acc to the account the customer asked for when closing it.
+func payOut(tx DBTX, acc *account.Account, request *account.CloseRequest) error {
+	recipient, err := resolveRecipient(tx, request.PayoutBankNumber, request.PayoutUsername)
 	if err != nil {
 		return err
 	}
+	if recipient.ID == acc.ID {
+		return fmt.Errorf("cannot pay out to the account being closed")
+	}
+	if err := recipient.Allows(account.OperationCredit); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	amount := int(acc.Balance)
+	previous, toPrevious := acc.Balance, recipient.Balance
+	acc.Balance = 0
+	recipient.Balance += int64(amount)
+	if err := updateBalance(tx, acc.Balance, acc.ID); err != nil {
+		return err
+	}
+	if err := updateBalance(tx, recipient.Balance, recipient.ID); err != nil {
+		return err
+	}
+	transferFrom := transfer.CreateTransfer(acc.ID, recipient.ID, amount, previous, acc.Balance, "withdrawal", now)
+	transferTo := transfer.CreateTransfer(acc.ID, recipient.ID, amount, toPrevious, recipient.Balance, "deposit", now)
+	for _, trans := range []*transfer.Transfer{transferFrom, transferTo} {
+		trans.FromBankNumber, trans.ToBankNumber = acc.BankNumber, recipient.BankNumber
+		trans.Reference = "ACCOUNT CLOSURE"
+	}
+	if err := insertTransferLegs(tx, transferFrom, transferTo); err != nil {
+		return fmt.Errorf("Could Not Add Transaction To Table")
+	}
 	return nil
 }
 
+// SetAccountStatus freezes, unfreezes or reactivates an account. Closing goes through CloseAccount.
+func (s *SQLiteStore) SetAccountStatus(id int, status string) error {
+	if status == account.StatusClosed {
+		return fmt.Errorf("accounts are closed through the close endpoint")
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	if acc.Status == account.StatusComplianceReview {
+		return fmt.Errorf("accounts in compliance review are decided through their sanctions hit")
+	}
+	if err := account.ValidateTransition(acc.Status, status); err != nil {
+		return err
+	}
+	before := *acc
+	if err := updateStatus(tx, acc, status, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := s.audit(tx, "account.status", audit.TargetAccount, acc.ID, &before, acc); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkDormant makes customer accounts that have had no money in or out for
+// account.DormantAfterDays dormant. Interest and fees the bank posted do not count as activity.
+func (s *SQLiteStore) MarkDormant(now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	since := now.AddDate(0, 0, -account.DormantAfterDays())
+	accounts, err := queryAccounts(tx, `
+		SELECT * FROM "account" WHERE "status" = ? AND "account_type" != ? AND "created_at" < ? AND NOT EXISTS (
+			SELECT 1 FROM "transfer" WHERE ("transfer"."from" = "account"."id" OR "transfer"."to" = "account"."id")
+			AND "transfer"."action" IN ('deposit', 'withdrawal') AND "transfer"."completed_at" >= ?
+		)`, account.StatusActive, account.TypeInternal, since, since)
+	if err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		before := *acc
+		if err := updateStatus(tx, acc, account.StatusDormant, now); err != nil {
+			return err
+		}
+		if err := s.audit(tx, "account.status", audit.TargetAccount, acc.ID, &before, acc); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// updateStatus records acc's new status and lets the account holder know.
+func updateStatus(db DBTX, acc *account.Account, status string, now time.Time) error {
+	if _, err := db.Exec(`UPDATE "account" SET "status" = ?, "status_changed_at" = ? WHERE "id" = ?`, status, now, acc.ID); err != nil {
+		return err
+	}
+	acc.Status, acc.StatusChanged = status, now
+	changed, err := event.New(acc.ID, event.TypeStatusChanged, event.StatusChanged{Status: status, ChangedAt: now}, now)
+	if err != nil {
+		return err
+	}
+	if err := appendEvent(db, changed); err != nil {
+		return err
+	}
+	return insertNotification(db, notification.CreateNotification(acc.ID, notification.KindAccountStatus, fmt.Sprintf("Your account is now %s", status)))
+}
+
 func (s *SQLiteStore) UpdateAccount(id int, update *account.UpdateAccountRequest) error {
-	_, err := s.db.Exec(`UPDATE account SET "first_name" = ?, "last_name" = ?, "username" = ?, "password" = ? WHERE "id" = ?`, update.FirstName, update.LastName, update.Username, update.Password, id)
+	return s.changeAccount("account.update", id, func(tx DBTX) (sql.Result, error) {
+		result, err := tx.Exec(`UPDATE account SET "first_name" = ?, "last_name" = ?, "username" = ?, "password" = ? WHERE "id" = ?`, update.FirstName, update.LastName, update.Username, update.Password, id)
+		if err != nil {
+			fmt.Printf("Could Not Update Account %v", err)
+		}
+		return result, err
+	})
+}
+
+// changeAccount makes a change to account id in a transaction, and records the account as it
+// was before and after the change in the audit log under action.
+func (s *SQLiteStore) changeAccount(action string, id int, change func(tx DBTX) (sql.Result, error)) error {
+	tx, err := s.begin()
 	if err != nil {
-		fmt.Printf("Could Not Update Account %v", err)
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+	before, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	result, err := change(tx)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(result, "Account Does Not Exist"); err != nil {
+		return err
+	}
+	after, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return err
+	}
+	changes, err := event.Changes(before, after, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := appendEvent(tx, change); err != nil {
+			return err
+		}
+	}
+	if err := s.audit(tx, action, audit.TargetAccount, id, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *SQLiteStore) GetAccountByID(id int) (*account.Account, error) {
@@ -151,11 +699,46 @@ func (s *SQLiteStore) GetAccountByID(id int) (*account.Account, error) {
 	return account, nil
 }
 
-func (s *SQLiteStore) GetAllAccounts() ([]*account.Account, error) {
+func (s *SQLiteStore) GetAccountByBankNumber(bankNumber int64) (*account.Account, error) {
+	return ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE "bank_number" = ?`, bankNumber))
+}
+
+func (s *SQLiteStore) SetOverdraftLimit(id int, limit int64) error {
+	if limit < 0 {
+		return fmt.Errorf("overdraft limit cannot be negative")
+	}
+	return s.changeAccount("account.overdraft", id, func(tx DBTX) (sql.Result, error) {
+		return tx.Exec(`UPDATE "account" SET "overdraft_limit" = ? WHERE "id" = ?`, limit, id)
+	})
+}
+
+func (s *SQLiteStore) SetRole(username, role string) error {
+	if err := account.ValidateRole(role); err != nil {
+		return err
+	}
+	var id int
+	if err := s.db.QueryRow(`SELECT "id" FROM "account" WHERE "username" = ?`, username).Scan(&id); err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	return s.changeAccount("account.role", id, func(tx DBTX) (sql.Result, error) {
+		return tx.Exec(`UPDATE "account" SET "role" = ? WHERE "id" = ?`, role, id)
+	})
+}
+
+// GetAllAccounts lists every customer account, or only those with status when one is given.
+// The bank's own internal accounts are never listed.
+func (s *SQLiteStore) GetAllAccounts(status string) ([]*account.Account, error) {
+	if status == "" {
+		return queryAccounts(s.db, `SELECT * FROM "account" WHERE "account_type" != ?`, account.TypeInternal)
+	}
+	return queryAccounts(s.db, `SELECT * FROM "account" WHERE "account_type" != ? AND "status" = ?`, account.TypeInternal, status)
+}
+
+func queryAccounts(db DBTX, query string, args ...interface{}) ([]*account.Account, error) {
 	accountArray := []*account.Account{}
-	row, err := s.db.Query(`SELECT * FROM "account"`)
+	row, err := db.Query(query, args...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	defer row.Close()
@@ -175,14 +758,17 @@ func (s *SQLiteStore) GetAllAccounts() ([]*account.Account, error) {
 //
 
 func (s *SQLiteStore) VerifyLogin(login account.LoginRequest) (*account.Account, error) {
-	account, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE username= ?`, login.Username))
+	acc, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE username= ?`, login.Username))
 	if err != nil {
 		return nil, fmt.Errorf("Account Does Not Exist")
 	}
-	if !misc.CheckPasswordHash(login.Password, account.Password) {
+	if !misc.CheckPasswordHash(login.Password, acc.Password) {
 		return nil, fmt.Errorf("Access Denied")
 	}
-	return account, nil
+	if err := acc.Allows(account.OperationLogin); err != nil {
+		return nil, err
+	}
+	return acc, nil
 }
 
 //
@@ -199,7 +785,16 @@ func (s *SQLiteStore) CreateTransferTable() error {
 			"action" TEXT NOT NULL,
 			"previous_balance" INTERGER NOT NULL,
 			"current_balance" INTEGER NOT NULL,
-			"completed_at" TIMESTAMP
+			"completed_at" TIMESTAMP,
+			"channel" TEXT NOT NULL DEFAULT '',
+			"reference" TEXT NOT NULL DEFAULT '',
+			"from_bank_number" INTEGER NOT NULL DEFAULT 0,
+			"to_bank_number" INTEGER NOT NULL DEFAULT 0,
+			"memo" TEXT NOT NULL DEFAULT '',
+			"category" TEXT NOT NULL DEFAULT '',
+			"reversal_of" INTEGER NOT NULL DEFAULT 0,
+			"reversed_by" INTEGER NOT NULL DEFAULT 0,
+			"counterpart_id" INTEGER NOT NULL DEFAULT 0
 		)`,
 	)
 	if transferTblErr != nil {
@@ -208,165 +803,4522 @@ func (s *SQLiteStore) CreateTransferTable() error {
 	return nil
 }
 
-func (s *SQLiteStore) CreateTransfer(trans *transfer.Transfer) error {
-	stmt, err := s.db.Prepare(`
-	INSERT INTO "transfer" (
-		"from",
-		"to",
-		"amount",
-		"action",
-		"previous_balance",
-		"current_balance",
-		"completed_at") values ( ?, ?, ?, ?, ?, ?, ?)
-	`)
+// backfillTransferBankNumbers fills in the bank numbers of transfers recorded before they
+// were stored alongside the internal account ids.
+func (s *SQLiteStore) backfillTransferBankNumbers() error {
+	_, err := s.db.Exec(`
+		UPDATE "transfer" SET
+			"from_bank_number" = COALESCE((SELECT "bank_number" FROM "account" WHERE "account"."id" = "transfer"."from"), 0),
+			"to_bank_number" = COALESCE((SELECT "bank_number" FROM "account" WHERE "account"."id" = "transfer"."to"), 0)
+		WHERE "from_bank_number" = 0 OR "to_bank_number" = 0`,
+	)
+	return err
+}
+
+// transferPairs maps the action of the first side written of each kind of transfer between
+// two accounts to the action of the second.
+var transferPairs = map[string]string{
+	"withdrawal":     "deposit",
+	"fee":            "fee-income",
+	"reversal-debit": "reversal-credit",
+}
+
+// backfillTransferCounterparts links the two sides of transfers recorded before they were
+// linked to each other.
+func (s *SQLiteStore) backfillTransferCounterparts() error {
+	tx, err := s.begin()
 	if err != nil {
-		fmt.Println("error preparing account table:", err)
 		return err
 	}
-	_, errorWithTable := stmt.Exec(
-		trans.From,
-		trans.To,
-		trans.Amount,
-		trans.Action,
-		trans.PreviousBalance,
-		trans.CurrentBalance,
-		trans.CompletedAt,
-	)
-	if errorWithTable != nil {
-		fmt.Println("error adding to account table:", errorWithTable)
-		return errorWithTable
+	defer tx.Rollback()
+	if err := linkTransferCounterparts(tx); err != nil {
+		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
-func (s *SQLiteStore) GetAllTransfers() ([]*transfer.Transfer, error) {
+// linkTransferCounterparts links every side of a transfer between two accounts that is not
+// linked yet to its other side: the first entry after it between the same accounts, for the
+// same amount and at the same moment, not already taken.
+func linkTransferCounterparts(db DBTX) error {
+	unlinked, err := queryTransfers(db, `SELECT * FROM "transfer" WHERE "counterpart_id" = 0 AND "from" <> "to" ORDER BY "id"`)
+	if err != nil {
+		return err
+	}
+	taken := map[int]bool{}
+	for i, first := range unlinked {
+		action, ok := transferPairs[first.Action]
+		if !ok || taken[first.ID] {
+			continue
+		}
+		for _, second := range unlinked[i+1:] {
+			if taken[second.ID] || second.Action != action || second.From != first.From || second.To != first.To ||
+				second.Amount != first.Amount || !second.CompletedAt.Equal(first.CompletedAt) {
+				continue
+			}
+			taken[second.ID] = true
+			if err := linkTransfers(db, first, second); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// insertTransferLegs writes the sender's and the recipient's side of a transfer between two
+// accounts, linked to each other so either can be found from the other.
+func insertTransferLegs(db DBTX, sent, received *transfer.Transfer) error {
+	if err := insertTransfer(db, sent); err != nil {
+		return err
+	}
+	received.CounterpartID = sent.ID
+	if err := insertTransfer(db, received); err != nil {
+		return err
+	}
+	sent.CounterpartID = received.ID
+	_, err := db.Exec(`UPDATE "transfer" SET "counterpart_id" = ? WHERE "id" = ?`, received.ID, sent.ID)
+	return err
+}
+
+func linkTransfers(db DBTX, first, second *transfer.Transfer) error {
+	for _, pair := range [][2]int{{first.ID, second.ID}, {second.ID, first.ID}} {
+		if _, err := db.Exec(`UPDATE "transfer" SET "counterpart_id" = ? WHERE "id" = ?`, pair[1], pair[0]); err != nil {
+			return err
+		}
+	}
+	first.CounterpartID, second.CounterpartID = second.ID, first.ID
+	return nil
+}
+
+func (s *SQLiteStore) CreateTransfer(trans *transfer.Transfer) error {
+	return insertTransfer(s.db, trans)
+}
+
+func insertTransfer(db DBTX, trans *transfer.Transfer) error {
+	stmt, err := db.Prepare(`
+	INSERT INTO "transfer" (
+		"from",
+		"to",
+		"amount",
+		"action",
+		"previous_balance",
+		"current_balance",
+		"completed_at",
+		"channel",
+		"reference",
+		"from_bank_number",
+		"to_bank_number",
+		"memo",
+		"category",
+		"reversal_of",
+		"counterpart_id") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		fmt.Println("error preparing transfer table:", err)
+		return err
+	}
+	defer stmt.Close()
+	result, errorWithTable := stmt.Exec(
+		trans.From,
+		trans.To,
+		trans.Amount,
+		trans.Action,
+		trans.PreviousBalance,
+		trans.CurrentBalance,
+		trans.CompletedAt,
+		trans.Channel,
+		trans.Reference,
+		trans.FromBankNumber,
+		trans.ToBankNumber,
+		trans.Memo,
+		trans.Category,
+		trans.ReversalOf,
+		trans.CounterpartID,
+	)
+	if errorWithTable != nil {
+		fmt.Println("error adding to transfer table:", errorWithTable)
+		return errorWithTable
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	trans.ID = int(id)
+	leg, err := event.ForTransfer(trans)
+	if err != nil {
+		return err
+	}
+	return appendEvent(db, leg)
+}
+
+func (s *SQLiteStore) GetAllTransfers() ([]*transfer.Transfer, error) {
 	transferArray := []*transfer.Transfer{}
 	row, err := s.db.Query(`SELECT * FROM "transfer"`)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(err)
+	}
+
+	defer row.Close()
+	for row.Next() {
+		transfer, err := ScanIntoTransfer(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in transfer table", err)
+			return nil, err
+		}
+		transferArray = append(transferArray, transfer)
+	}
+	return transferArray, nil
+}
+
+// myTransfersWhere matches the side of each transfer that belongs to account ?,
+// taking the account id four times.
+const myTransfersWhere = `(("from" = ? AND "action" IN ('withdrawal', 'fee', 'reversal-debit')) OR ("to" = ? AND "action" IN ('deposit', 'fee-income', 'reversal-credit')) OR ("from" = ? AND "to"= ?))`
+
+func (s *SQLiteStore) GetMyTransfers(id int, filter *transfer.HistoryFilter) ([]*transfer.Transfer, error) {
+	query := `SELECT * FROM "transfer" WHERE ` + myTransfersWhere
+	args := []interface{}{id, id, id, id}
+	if filter != nil && filter.Search != "" {
+		search := "%" + escapeLike(filter.Search) + "%"
+		query += ` AND ("memo" LIKE ? ESCAPE '\' OR "reference" LIKE ? ESCAPE '\')`
+		args = append(args, search, search)
+	}
+	if filter != nil && filter.Category != "" {
+		query += ` AND "category" = ?`
+		args = append(args, filter.Category)
+	}
+	if filter != nil && !filter.From.IsZero() {
+		query += ` AND "completed_at" >= ?`
+		args = append(args, filter.From)
+	}
+	if filter != nil && !filter.To.IsZero() {
+		query += ` AND "completed_at" < ?`
+		args = append(args, filter.To)
+	}
+	return queryTransfers(s.db, query, args...)
+}
+
+// UpdateTransferCategory recategorises the account holder's own side of a transfer.
+func (s *SQLiteStore) UpdateTransferCategory(accountID, transferID int, category string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	before, err := ScanIntoTransfer(tx.QueryRow(`SELECT * FROM "transfer" WHERE "id" = ? AND `+myTransfersWhere, transferID, accountID, accountID, accountID, accountID))
+	if err != nil {
+		return fmt.Errorf("Transfer Does Not Exist")
+	}
+	if _, err := tx.Exec(`UPDATE "transfer" SET "category" = ? WHERE "id" = ?`, category, transferID); err != nil {
+		return err
+	}
+	after := *before
+	after.Category = category
+	categorised, err := event.New(accountID, event.TypeTransferCategorised, event.TransferCategorised{TransferID: transferID, Category: category}, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if err := appendEvent(tx, categorised); err != nil {
+		return err
+	}
+	if err := s.audit(tx, "transfer.categorise", audit.TargetTransfer, transferID, before, &after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// escapeLike stops % and _ typed by a user from acting as LIKE wildcards.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func (s *SQLiteStore) GetAccountBalance(id int) (*transfer.MyBalance, error) {
+	account, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		fmt.Println("error retrieving account by ID from accounts table")
+		return nil, err
+	}
+	return myBalance(s.db, account)
+}
+
+// myBalance reports both the ledger balance of acc and what is available to spend once
+// holds and any overdraft are taken into account.
+func myBalance(db DBTX, acc *account.Account) (*transfer.MyBalance, error) {
+	funds, err := available(db, acc)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer.MyBalance{
+		Username:         acc.Username,
+		MyAccountNumber:  acc.BankNumber,
+		Balance:          acc.Balance,
+		LedgerBalance:    acc.Balance,
+		AvailableBalance: funds,
+	}, nil
+}
+
+func (s *SQLiteStore) Deposit(id int, request *transfer.DepositRequest) (*transfer.MyBalance, error) {
+	if err := transfer.ValidateDeposit(request); err != nil {
+		return nil, err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		fmt.Println("error retrieving account by ID from accounts table")
+		return nil, err
+	}
+	if err := acc.Allows(account.OperationCredit); err != nil {
+		return nil, err
+	}
+	previous := acc.Balance
+	acc.Balance += int64(request.Amount)
+	if err := updateBalance(tx, acc.Balance, id); err != nil {
+		fmt.Printf("Could Not Deposit into %v Account %v", id, err)
+		return nil, err
+	}
+	deposit := transfer.CreateDeposit(id, request, previous, acc.Balance, time.Now().UTC())
+	deposit.FromBankNumber, deposit.ToBankNumber = acc.BankNumber, acc.BankNumber
+	if err := insertTransfer(tx, deposit); err != nil {
+		return nil, fmt.Errorf("Could Not Add Transaction To Table")
+	}
+	if err := s.audit(tx, "transfer.deposit", audit.TargetTransfer, deposit.ID, nil, deposit); err != nil {
+		return nil, err
+	}
+	balance, err := myBalance(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (s *SQLiteStore) Withdraw(id int, request *transfer.WithdrawalRequest) (*transfer.MyBalance, error) {
+	if err := transfer.ValidateWithdrawal(request); err != nil {
+		return nil, err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		fmt.Println("error retrieving account by ID from accounts table")
+		return nil, err
+	}
+	if err := acc.Allows(account.OperationDebit); err != nil {
+		return nil, err
+	}
+	// there is no one to approve a withdrawal, so the signing rule's threshold is a ceiling
+	rule, err := signingRule(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule.ApprovalsNeeded(request.Amount) > 1 {
+		return nil, fmt.Errorf("payments above %v from this account need approval and cannot be made as a withdrawal", rule.Above)
+	}
+	if err := s.screenWithdrawal(tx, acc, request.Amount); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := s.checkLimits(tx, acc, request.Amount, now); err != nil {
+		return nil, err
+	}
+	quote, err := s.quoteFee(tx, id, fee.OperationWithdrawal, request.Amount, now)
+	if err != nil {
+		return nil, err
+	}
+	funds, err := available(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	if funds-int64(request.Amount)-int64(quote.Fee) < 0 {
+		return nil, fmt.Errorf("insufficent funds")
+	}
+	previous := acc.Balance
+	acc.Balance -= int64(request.Amount)
+	if err := updateBalance(tx, acc.Balance, id); err != nil {
+		fmt.Printf("Could Not Withdraw from %v Account %v", id, err)
+		return nil, err
+	}
+	withdrawal := transfer.CreateWithdrawal(id, request, previous, acc.Balance, now)
+	withdrawal.FromBankNumber, withdrawal.ToBankNumber = acc.BankNumber, acc.BankNumber
+	if err := insertTransfer(tx, withdrawal); err != nil {
+		return nil, fmt.Errorf("Could Not Add Transaction To Table")
+	}
+	if err := s.audit(tx, "transfer.withdraw", audit.TargetTransfer, withdrawal.ID, nil, withdrawal); err != nil {
+		return nil, err
+	}
+	if err := s.chargeFee(tx, acc, quote, request.Reference, now); err != nil {
+		return nil, err
+	}
+	balance, err := myBalance(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (s *SQLiteStore) ResolveRecipient(bankNumber int64, username string) (*account.Account, error) {
+	return resolveRecipient(s.db, bankNumber, username)
+}
+
+// resolveRecipient finds the account a customer is sending to from the details they know:
+// the recipient's bank number or their username, but not both. Internal accounts cannot be
+// paid directly.
+func resolveRecipient(db DBTX, bankNumber int64, username string) (*account.Account, error) {
+	var row *sql.Row
+	switch {
+	case bankNumber != 0 && username != "":
+		return nil, fmt.Errorf("give either a bank number or a username, not both")
+	case bankNumber != 0:
+		row = db.QueryRow(`SELECT * FROM "account" WHERE "bank_number" = ? AND "account_type" != ?`, bankNumber, account.TypeInternal)
+	case username != "":
+		row = db.QueryRow(`SELECT * FROM "account" WHERE "username" = ? AND "account_type" != ?`, username, account.TypeInternal)
+	default:
+		return nil, fmt.Errorf("a recipient bank number or username is required")
+	}
+	recipient, err := ScanIntoAccount(row)
+	if err != nil {
+		return nil, fmt.Errorf("Recipient Does Not Exist")
+	}
+	return recipient, nil
+}
+
+// resolveTransferRecipient finds the recipient of a transfer from account id, which can
+// also be one of the sender's saved payees once its cooling-off period has passed.
+func resolveTransferRecipient(db DBTX, id int, request *transfer.TransferRequest) (*account.Account, error) {
+	if request.PayeeID == 0 {
+		return resolveRecipient(db, request.ToBankNumber, request.ToUsername)
+	}
+	if request.ToBankNumber != 0 || request.ToUsername != "" {
+		return nil, fmt.Errorf("give either a payee id or a recipient, not both")
+	}
+	payee, err := getBeneficiary(db, id, request.PayeeID)
+	if err != nil {
+		return nil, err
+	}
+	if !payee.Active {
+		return nil, fmt.Errorf("payee %q cannot be paid until %v", payee.Nickname, payee.ActiveFrom.Format(time.RFC3339))
+	}
+	return resolveRecipient(db, payee.BankNumber, "")
+}
+
+// Transfer sends money from account id on behalf of actorID, the holder or one of the
+// co-owners. Accounts whose holder has not been verified are held to kyc.UnverifiedLimits.
+// When the account's signing rule needs more than one owner to approve it, the transfer is
+// held for approval instead of being sent. Otherwise it goes through the fraud
+// rules and large transfers are held for review.
+func (s *SQLiteStore) Transfer(id, actorID int, request *transfer.TransferRequest) (*transfer.TransferResponse, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rule, err := signingRule(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	var transferResponse *transfer.TransferResponse
+	if required := rule.ApprovalsNeeded(request.Amount); required > 1 {
+		transferResponse, err = s.requestApproval(tx, id, actorID, request, required)
+	} else {
+		transferResponse, err = s.screenAndSend(tx, id, actorID, request)
+	}
+	if err != nil {
+		tx.Rollback()
+		if saveErr := saveBlockedDecision(s.db, err); saveErr != nil {
+			return nil, saveErr
+		}
+		return nil, err
+	}
+	if err := s.audit(tx, "transfer.send", audit.TargetAccount, id, nil, request); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return transferResponse, nil
+}
+
+// makeTransfer moves money from account id to the recipient of the request and records both
+// sides of it. It must run inside a transaction, which the caller commits.
+func (s *SQLiteStore) makeTransfer(tx DBTX, id int, request *transfer.TransferRequest) (*transfer.TransferResponse, error) {
+	if request.Amount <= 0 {
+		return nil, fmt.Errorf("invalid amount - > cannot complete transaction")
+	}
+	if err := transfer.ValidateDetails(request.Memo, request.Reference, request.Category); err != nil {
+		return nil, err
+	}
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		fmt.Println("error retrieving account by ID from accounts table")
+		return nil, err
+	}
+	toAccount, err := resolveTransferRecipient(tx, id, request)
+	if err != nil {
+		return nil, err
+	}
+	if toAccount.ID == acc.ID {
+		return nil, fmt.Errorf("cannot transfer to your own account")
+	}
+	if err := acc.Allows(account.OperationDebit); err != nil {
+		return nil, err
+	}
+	if err := toAccount.Allows(account.OperationCredit); err != nil {
+		return nil, fmt.Errorf("recipient cannot receive payments")
+	}
+	now := time.Now().UTC()
+	if err := s.checkLimits(tx, acc, request.Amount, now); err != nil {
+		return nil, err
+	}
+	quote, err := s.quoteFee(tx, id, fee.OperationTransfer, request.Amount, now)
+	if err != nil {
+		return nil, err
+	}
+	funds, err := available(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	if funds-int64(request.Amount)-int64(quote.Fee) < 0 {
+		return nil, fmt.Errorf("insufficent funds - > cannot complete transaction")
+	}
+	previous, toPrevious := acc.Balance, toAccount.Balance
+	acc.Balance -= int64(request.Amount)
+	toAccount.Balance += int64(request.Amount)
+	if err := updateBalance(tx, acc.Balance, id); err != nil {
+		fmt.Printf("Could Not Withdraw from %v Account %v", id, err)
+		return nil, err
+	}
+	if err := updateBalance(tx, toAccount.Balance, toAccount.ID); err != nil {
+		fmt.Printf("Could Not Deposit into %v Account %v", toAccount.ID, err)
+		return nil, err
+	}
+	transferFrom := transfer.CreateTransfer(id, toAccount.ID, request.Amount, previous, acc.Balance, "withdrawal", now)
+	transferTo := transfer.CreateTransfer(id, toAccount.ID, request.Amount, toPrevious, toAccount.Balance, "deposit", now)
+	// the category is the sender's own label, the recipient can set theirs afterwards
+	transferFrom.Category = request.Category
+	for _, trans := range []*transfer.Transfer{transferFrom, transferTo} {
+		trans.FromBankNumber, trans.ToBankNumber = acc.BankNumber, toAccount.BankNumber
+		trans.Memo, trans.Reference = request.Memo, request.Reference
+	}
+	if err := insertTransferLegs(tx, transferFrom, transferTo); err != nil {
+		return nil, fmt.Errorf("Could Not Add Transaction To Table")
+	}
+	if err := s.chargeFee(tx, acc, quote, request.Reference, now); err != nil {
+		return nil, err
+	}
+	balance, err := myBalance(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	myAccount := &transfer.TransferResponse{
+		Account: *balance,
+		Recipient: transfer.Payee{
+			BankNumber: toAccount.BankNumber,
+			Name:       misc.MaskName(toAccount.FirstName + " " + toAccount.LastName),
+		},
+		Fee:  quote.Fee,
+		Sent: true,
+	}
+	return myAccount, nil
+}
+
+//
+// Beneficiary
+//
+
+func (s *SQLiteStore) CreateBeneficiaryTable() error {
+	_, beneficiaryTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "beneficiary" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"nickname" VARCHAR(64) NOT NULL,
+			"bank_number" NUMBER NOT NULL,
+			"name" VARCHAR(128) NOT NULL,
+			"created_at" TIMESTAMP,
+			"active_from" TIMESTAMP,
+			UNIQUE ("account_id", "bank_number")
+		)`,
+	)
+	if beneficiaryTblErr != nil {
+		return beneficiaryTblErr
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateBeneficiary(b *beneficiary.Beneficiary) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`
+	INSERT INTO "beneficiary" (
+		"account_id",
+		"nickname",
+		"bank_number",
+		"name",
+		"created_at",
+		"active_from") values ( ?, ?, ?, ?, ?, ?)
+	`, b.AccountID, b.Nickname, b.BankNumber, b.Name, b.CreatedAt, b.ActiveFrom)
+	if err != nil {
+		fmt.Println("error adding to beneficiary table:", err)
+		return fmt.Errorf("payee is already saved")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	b.ID = int(id)
+	if err := s.audit(tx, "beneficiary.create", audit.TargetBeneficiary, b.ID, nil, b); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetBeneficiaries(accountID int) ([]*beneficiary.Beneficiary, error) {
+	beneficiaryArray := []*beneficiary.Beneficiary{}
+	row, err := s.db.Query(`SELECT * FROM "beneficiary" WHERE "account_id" = ? ORDER BY "nickname"`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		b, err := ScanIntoBeneficiary(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in beneficiary table", err)
+			return nil, err
+		}
+		beneficiaryArray = append(beneficiaryArray, b)
+	}
+	return beneficiaryArray, nil
+}
+
+func (s *SQLiteStore) GetBeneficiary(accountID, id int) (*beneficiary.Beneficiary, error) {
+	return getBeneficiary(s.db, accountID, id)
+}
+
+func getBeneficiary(db DBTX, accountID, id int) (*beneficiary.Beneficiary, error) {
+	b, err := ScanIntoBeneficiary(db.QueryRow(`SELECT * FROM "beneficiary" WHERE "id" = ? AND "account_id" = ?`, id, accountID))
+	if err != nil {
+		return nil, fmt.Errorf("Payee Does Not Exist")
+	}
+	return b, nil
+}
+
+func (s *SQLiteStore) RenameBeneficiary(accountID, id int, nickname string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	before, err := getBeneficiary(tx, accountID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE "beneficiary" SET "nickname" = ? WHERE "id" = ?`, nickname, id); err != nil {
+		return err
+	}
+	after := *before
+	after.Nickname = nickname
+	if err := s.audit(tx, "beneficiary.rename", audit.TargetBeneficiary, id, before, &after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteBeneficiary(accountID, id int) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	before, err := getBeneficiary(tx, accountID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM "beneficiary" WHERE "id" = ?`, id); err != nil {
+		return err
+	}
+	if err := s.audit(tx, "beneficiary.delete", audit.TargetBeneficiary, id, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// expectOneRow turns an update or delete that matched nothing into an error.
+func expectOneRow(result sql.Result, message string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New(message)
+	}
+	return nil
+}
+
+//
+// Standing Order
+//
+
+func (s *SQLiteStore) CreateStandingOrderTables() error {
+	_, orderTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "standing_order" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"to_bank_number" INTEGER NOT NULL,
+			"payee_id" INTEGER NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"memo" TEXT NOT NULL,
+			"reference" TEXT NOT NULL,
+			"category" TEXT NOT NULL,
+			"frequency" TEXT NOT NULL,
+			"day_of_month" INTEGER NOT NULL,
+			"start_date" TIMESTAMP,
+			"end_date" TIMESTAMP,
+			"max_occurrences" INTEGER NOT NULL,
+			"executed" INTEGER NOT NULL,
+			"next_run_at" TIMESTAMP,
+			"attempt_at" TIMESTAMP,
+			"attempts" INTEGER NOT NULL,
+			"last_error" TEXT NOT NULL,
+			"status" TEXT NOT NULL,
+			"created_at" TIMESTAMP
+		)`,
+	)
+	if orderTblErr != nil {
+		return orderTblErr
+	}
+	// one row per occurrence paid or given up on, the unique key is what stops an
+	// occurrence being paid twice
+	_, runTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "standing_order_run" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"order_id" INTEGER NOT NULL,
+			"due_at" TIMESTAMP NOT NULL,
+			"status" TEXT NOT NULL,
+			"error" TEXT NOT NULL,
+			"ran_at" TIMESTAMP,
+			UNIQUE ("order_id", "due_at")
+		)`,
+	)
+	if runTblErr != nil {
+		return runTblErr
+	}
+	return nil
+}
+
+// CreateStandingOrder saves a new standing order. Standing orders are paid without anyone
+// to approve them, so they are refused for amounts the account's signing rule holds for approval.
+func (s *SQLiteStore) CreateStandingOrder(order *standingorder.StandingOrder) error {
+	rule, err := signingRule(s.db, order.AccountID)
+	if err != nil {
+		return err
+	}
+	if rule.ApprovalsNeeded(order.Amount) > 1 {
+		return fmt.Errorf("payments above %v from this account need approval and cannot be made by standing order", rule.Above)
+	}
+	if review.NeedsReview(order.Amount) {
+		return fmt.Errorf("payments of %v or more are reviewed before being sent and cannot be made by standing order", review.Threshold())
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`
+	INSERT INTO "standing_order" (
+		"account_id",
+		"to_bank_number",
+		"payee_id",
+		"amount",
+		"memo",
+		"reference",
+		"category",
+		"frequency",
+		"day_of_month",
+		"start_date",
+		"end_date",
+		"max_occurrences",
+		"executed",
+		"next_run_at",
+		"attempt_at",
+		"attempts",
+		"last_error",
+		"status",
+		"created_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		order.AccountID,
+		order.ToBankNumber,
+		order.PayeeID,
+		order.Amount,
+		order.Memo,
+		order.Reference,
+		order.Category,
+		order.Frequency,
+		order.DayOfMonth,
+		order.StartDate,
+		order.EndDate,
+		order.MaxOccurrences,
+		order.Executed,
+		order.NextRunAt,
+		order.AttemptAt,
+		order.Attempts,
+		order.LastError,
+		order.Status,
+		order.CreatedAt,
+	)
+	if err != nil {
+		fmt.Println("error adding to standing order table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	order.ID = int(id)
+	if err := s.audit(tx, "standing-order.create", audit.TargetStandingOrder, order.ID, nil, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetStandingOrders(accountID int) ([]*standingorder.StandingOrder, error) {
+	return queryStandingOrders(s.db, `SELECT * FROM "standing_order" WHERE "account_id" = ?`, accountID)
+}
+
+func (s *SQLiteStore) UpdateStandingOrderStatus(accountID, id int, status string) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	order, err := ScanIntoStandingOrder(tx.QueryRow(`SELECT * FROM "standing_order" WHERE "id" = ? AND "account_id" = ?`, id, accountID))
+	if err != nil {
+		return fmt.Errorf("Standing Order Does Not Exist")
+	}
+	switch {
+	case status == standingorder.StatusPaused && order.Status == standingorder.StatusActive:
+	case status == standingorder.StatusActive && order.Status == standingorder.StatusPaused:
+	case status == standingorder.StatusCancelled && (order.Status == standingorder.StatusActive || order.Status == standingorder.StatusPaused):
+	default:
+		return fmt.Errorf("cannot change a %s standing order to %s", order.Status, status)
+	}
+	if _, err := tx.Exec(`UPDATE "standing_order" SET "status" = ? WHERE "id" = ?`, status, id); err != nil {
+		return err
+	}
+	after := *order
+	after.Status = status
+	if err := s.audit(tx, "standing-order.status", audit.TargetStandingOrder, id, order, &after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDueStandingOrders lists the active orders that should be attempted at now.
+func (s *SQLiteStore) GetDueStandingOrders(now time.Time) ([]int, error) {
+	orders, err := queryStandingOrders(s.db, `SELECT * FROM "standing_order" WHERE "status" = ?`, standingorder.StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	due := []int{}
+	for _, order := range orders {
+		if !order.AttemptAt.After(now) {
+			due = append(due, order.ID)
+		}
+	}
+	return due, nil
+}
+
+// RunStandingOrder pays the occurrence of the order that is due through the same path as any
+// other transfer. The occurrence is recorded in the same transaction as the money moving, so
+// it can never be paid twice, even if the server stops halfway through.
+func (s *SQLiteStore) RunStandingOrder(id int, now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	order, err := ScanIntoStandingOrder(tx.QueryRow(`SELECT * FROM "standing_order" WHERE "id" = ?`, id))
+	if err != nil {
+		return err
+	}
+	if order.Status != standingorder.StatusActive || order.AttemptAt.After(now) {
+		return nil
+	}
+	// the signing rule may have been tightened since the order was set up, and an occurrence
+	// it now holds for approval is failed rather than paid without it
+	rule, err := signingRule(tx, order.AccountID)
+	if err != nil {
+		return err
+	}
+	if rule.ApprovalsNeeded(order.Amount) > 1 {
+		tx.Rollback()
+		return s.failStandingOrder(id, now, fmt.Errorf("payments above %v from this account need approval and cannot be made by standing order", rule.Above))
+	}
+	// standing orders are screened like any other transfer, one held for review still
+	// counts as this occurrence having run
+	response, transferErr := s.screenAndSend(tx, order.AccountID, order.AccountID, &transfer.TransferRequest{
+		ToBankNumber: order.ToBankNumber,
+		PayeeID:      order.PayeeID,
+		Amount:       order.Amount,
+		Memo:         order.Memo,
+		Reference:    order.Reference,
+		Category:     order.Category,
+	})
+	if transferErr != nil {
+		tx.Rollback()
+		if err := saveBlockedDecision(s.db, transferErr); err != nil {
+			return err
+		}
+		return s.failStandingOrder(id, now, transferErr)
+	}
+	status := "paid"
+	if !response.Sent {
+		status = "held"
+	}
+	if _, err := tx.Exec(`INSERT INTO "standing_order_run" ("order_id", "due_at", "status", "error", "ran_at") values (?, ?, ?, '', ?)`, order.ID, order.NextRunAt, status, now); err != nil {
+		return err
+	}
+	before := *order
+	order.Executed++
+	advanceStandingOrder(order)
+	if err := updateStandingOrder(tx, order); err != nil {
+		return err
+	}
+	if err := s.audit(tx, "standing-order.run", audit.TargetStandingOrder, order.ID, &before, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// failStandingOrder schedules a retry of the occurrence that could not be paid, or gives up on
+// it once it has been tried too many times. The account holder is told either way.
+func (s *SQLiteStore) failStandingOrder(id int, now time.Time, cause error) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	order, err := ScanIntoStandingOrder(tx.QueryRow(`SELECT * FROM "standing_order" WHERE "id" = ?`, id))
+	if err != nil {
+		return err
+	}
+	before := *order
+	order.Attempts++
+	order.LastError = cause.Error()
+	var message string
+	if order.Attempts < standingorder.MaxAttempts() {
+		order.AttemptAt = now.Add(standingorder.RetryDelay())
+		message = fmt.Sprintf("Standing order %v of %v could not be paid (%v), it will be tried again at %v", order.ID, order.Amount, cause, order.AttemptAt.Format(time.RFC3339))
+	} else {
+		if _, err := tx.Exec(`INSERT INTO "standing_order_run" ("order_id", "due_at", "status", "error", "ran_at") values (?, ?, 'failed', ?, ?)`, order.ID, order.NextRunAt, order.LastError, now); err != nil {
+			return err
+		}
+		message = fmt.Sprintf("Standing order %v of %v due %v was not paid after %v attempts (%v)", order.ID, order.Amount, order.NextRunAt.Format(time.RFC3339), order.Attempts, cause)
+		advanceStandingOrder(order)
+		if order.Frequency == standingorder.FrequencyOnce {
+			order.Status = standingorder.StatusFailed
+		}
+	}
+	if err := updateStandingOrder(tx, order); err != nil {
+		return err
+	}
+	if err := s.audit(tx, "standing-order.fail", audit.TargetStandingOrder, order.ID, &before, order); err != nil {
+		return err
+	}
+	if err := insertNotification(tx, notification.CreateNotification(order.AccountID, notification.KindStandingOrderFailed, message)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// advanceStandingOrder moves the order on to its next occurrence, completing it when there is none.
+func advanceStandingOrder(order *standingorder.StandingOrder) {
+	next := standingorder.NextRun(order, order.NextRunAt)
+	if standingorder.Finished(order, next) {
+		order.Status = standingorder.StatusCompleted
+		return
+	}
+	order.NextRunAt = next
+	order.AttemptAt = next
+	order.Attempts = 0
+	order.LastError = ""
+}
+
+func updateStandingOrder(db DBTX, order *standingorder.StandingOrder) error {
+	_, err := db.Exec(`UPDATE "standing_order" SET "executed" = ?, "next_run_at" = ?, "attempt_at" = ?, "attempts" = ?, "last_error" = ?, "status" = ? WHERE "id" = ?`,
+		order.Executed, order.NextRunAt, order.AttemptAt, order.Attempts, order.LastError, order.Status, order.ID)
+	return err
+}
+
+func queryStandingOrders(db DBTX, query string, args ...interface{}) ([]*standingorder.StandingOrder, error) {
+	orderArray := []*standingorder.StandingOrder{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		order, err := ScanIntoStandingOrder(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in standing order table", err)
+			return nil, err
+		}
+		orderArray = append(orderArray, order)
+	}
+	return orderArray, nil
+}
+
+//
+// Notification
+//
+
+func (s *SQLiteStore) CreateNotificationTable() error {
+	_, notificationTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "notification" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"kind" TEXT NOT NULL,
+			"message" TEXT NOT NULL,
+			"created_at" TIMESTAMP
+		)`,
+	)
+	if notificationTblErr != nil {
+		return notificationTblErr
+	}
+	return nil
+}
+
+func insertNotification(db DBTX, n *notification.Notification) error {
+	_, err := db.Exec(`INSERT INTO "notification" ("account_id", "kind", "message", "created_at") values (?, ?, ?, ?)`, n.AccountID, n.Kind, n.Message, n.CreatedAt)
+	if err != nil {
+		fmt.Println("error adding to notification table:", err)
+	}
+	return err
+}
+
+func (s *SQLiteStore) GetNotifications(accountID int) ([]*notification.Notification, error) {
+	notificationArray := []*notification.Notification{}
+	row, err := s.db.Query(`SELECT * FROM "notification" WHERE "account_id" = ? ORDER BY "id" DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		n := new(notification.Notification)
+		if err := row.Scan(&n.ID, &n.AccountID, &n.Kind, &n.Message, &n.CreatedAt); err != nil {
+			fmt.Println("error with scanning rows in notification table", err)
+			return nil, err
+		}
+		notificationArray = append(notificationArray, n)
+	}
+	return notificationArray, nil
+}
+
+//
+// Interest
+//
+
+func (s *SQLiteStore) CreateInterestTables() error {
+	_, rateTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "interest_rate" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_type" TEXT NOT NULL,
+			"apr_bps" INTEGER NOT NULL,
+			"effective_from" TEXT NOT NULL,
+			UNIQUE ("account_type", "effective_from")
+		)`,
+	)
+	if rateTblErr != nil {
+		return rateTblErr
+	}
+	_, accrualTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "interest_accrual" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"day" TEXT NOT NULL,
+			"balance" INTEGER NOT NULL,
+			"apr_bps" INTEGER NOT NULL,
+			"micro" INTEGER NOT NULL,
+			UNIQUE ("account_id", "day")
+		)`,
+	)
+	if accrualTblErr != nil {
+		return accrualTblErr
+	}
+	_, postingTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "interest_posting" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"month" TEXT NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"carried_micro" INTEGER NOT NULL,
+			UNIQUE ("account_id", "month")
+		)`,
+	)
+	if postingTblErr != nil {
+		return postingTblErr
+	}
+	_, runTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "interest_run" (
+			"day" TEXT PRIMARY KEY,
+			"completed_at" TIMESTAMP
+		)`,
+	)
+	if runTblErr != nil {
+		return runTblErr
+	}
+	return nil
+}
+
+// SaveInterestRates records the rate table so that every day is accrued at the rate that was
+// in effect on it, even when it is accrued late. Rates already stored are updated in place.
+func (s *SQLiteStore) SaveInterestRates(rates []interest.Rate) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, rate := range rates {
+		_, err := tx.Exec(`INSERT INTO "interest_rate" ("account_type", "apr_bps", "effective_from") values (?, ?, ?)
+			ON CONFLICT ("account_type", "effective_from") DO UPDATE SET "apr_bps" = excluded."apr_bps"`,
+			rate.AccountType, rate.APRBps, rate.EffectiveFrom)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LastInterestDay is the most recent day interest has been accrued for, if any.
+func (s *SQLiteStore) LastInterestDay() (time.Time, bool, error) {
+	var day sql.NullString
+	if err := s.db.QueryRow(`SELECT MAX("day") FROM "interest_run"`).Scan(&day); err != nil {
+		return time.Time{}, false, err
+	}
+	if !day.Valid {
+		return time.Time{}, false, nil
+	}
+	last, err := time.Parse(interest.DayFormat, day.String)
+	return last, err == nil, err
+}
+
+// AccrueInterest accrues one day of interest on every account whose type pays interest,
+// using each account's balance at the end of that day, and posts the month's interest on
+// its last day. A day that has already been accrued is left alone.
+func (s *SQLiteStore) AccrueInterest(day time.Time) error {
+	dayKey := day.Format(interest.DayFormat)
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var done int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM "interest_run" WHERE "day" = ?`, dayKey).Scan(&done); err != nil {
+		return err
+	}
+	if done > 0 {
+		return nil
+	}
+	accounts, err := queryAccounts(tx, `SELECT * FROM "account"`)
+	if err != nil {
+		return err
+	}
+	endOfDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	overdraftBps, err := rateOn(tx, interest.OverdraftRateType, dayKey)
+	if err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		if acc.Type == account.TypeInternal {
+			continue
+		}
+		balance, err := balanceAt(tx, acc.ID, endOfDay)
+		if err != nil {
+			return err
+		}
+		aprBps, err := rateOn(tx, acc.Type, dayKey)
+		if err != nil {
+			return err
+		}
+		micro := interest.DailyAccrual(balance, aprBps)
+		if balance < 0 {
+			aprBps = overdraftBps
+			micro = interest.DailyOverdraftCharge(balance, overdraftBps)
+		}
+		if micro != 0 {
+			_, err = tx.Exec(`INSERT OR IGNORE INTO "interest_accrual" ("account_id", "day", "balance", "apr_bps", "micro") values (?, ?, ?, ?, ?)`,
+				acc.ID, dayKey, balance, aprBps, micro)
+			if err != nil {
+				return err
+			}
+		}
+		if interest.LastDayOfMonth(day) {
+			if err := s.postInterest(tx, acc.ID, day); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO "interest_run" ("day", "completed_at") values (?, ?)`, dayKey, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rateOn is the APR in effect for an account type on day, or 0 when it has none.
+func rateOn(db DBTX, accountType, dayKey string) (int, error) {
+	var aprBps int
+	err := db.QueryRow(`SELECT "apr_bps" FROM "interest_rate" WHERE "account_type" = ? AND "effective_from" <= ? ORDER BY "effective_from" DESC LIMIT 1`, accountType, dayKey).Scan(&aprBps)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return aprBps, err
+}
+
+// postInterest settles the interest accrued over the month ending on day, along with whatever
+// was carried over from the month before, and carries the fraction left over. Interest earned is
+// credited as "interest" and interest on an overdrawn balance is debited as "overdraft-interest".
+func (s *SQLiteStore) postInterest(tx DBTX, id int, day time.Time) error {
+	month := day.Format("2006-01")
+	var accrued, carried int64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM("micro"), 0) FROM "interest_accrual" WHERE "account_id" = ? AND "day" LIKE ?`, id, month+"-%").Scan(&accrued); err != nil {
+		return err
+	}
+	err := tx.QueryRow(`SELECT "carried_micro" FROM "interest_posting" WHERE "account_id" = ? AND "month" < ? ORDER BY "month" DESC LIMIT 1`, id, month).Scan(&carried)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if accrued == 0 && carried == 0 {
+		return nil
+	}
+	amount, carry := interest.Split(accrued + carried)
+	result, err := tx.Exec(`INSERT OR IGNORE INTO "interest_posting" ("account_id", "month", "amount", "carried_micro") values (?, ?, ?, ?)`, id, month, amount, carry)
+	if err != nil {
+		return err
+	}
+	if posted, err := result.RowsAffected(); err != nil || posted == 0 || amount == 0 {
+		return err
+	}
+	account, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return err
+	}
+	previous := account.Balance
+	account.Balance += amount
+	if err := updateBalance(tx, account.Balance, id); err != nil {
+		return err
+	}
+	action := "interest"
+	if amount < 0 {
+		action, amount = "overdraft-interest", -amount
+	}
+	posting := transfer.CreateTransfer(id, id, int(amount), previous, account.Balance, action, time.Now().UTC())
+	posting.FromBankNumber, posting.ToBankNumber = account.BankNumber, account.BankNumber
+	posting.Reference = "INTEREST " + month
+	if err := insertTransfer(tx, posting); err != nil {
+		return err
+	}
+	return s.audit(tx, "transfer.interest", audit.TargetTransfer, posting.ID, nil, posting)
+}
+
+// balanceAt is the balance of account id just before at, taken from the last ledger entry
+// completed for it before then. Entries are ordered by when they completed, as statements
+// list them, and those completed at the same moment by id.
+func balanceAt(db DBTX, id int, at time.Time) (int64, error) {
+	var balance int64
+	err := db.QueryRow(`SELECT "current_balance" FROM "transfer" WHERE `+myTransfersWhere+` AND "completed_at" < ? ORDER BY "completed_at" DESC, "id" DESC LIMIT 1`, id, id, id, id, at).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return balance, err
+}
+
+func (s *SQLiteStore) GetInterestAccruals(accountID int) ([]*interest.Accrual, error) {
+	accrualArray := []*interest.Accrual{}
+	row, err := s.db.Query(`SELECT * FROM "interest_accrual" WHERE "account_id" = ? ORDER BY "day"`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		accrual := new(interest.Accrual)
+		if err := row.Scan(&accrual.ID, &accrual.AccountID, &accrual.Day, &accrual.Balance, &accrual.APRBps, &accrual.Micro); err != nil {
+			fmt.Println("error with scanning rows in interest accrual table", err)
+			return nil, err
+		}
+		accrualArray = append(accrualArray, accrual)
+	}
+	return accrualArray, nil
+}
+
+//
+// Fee
+//
+
+// feeIncomeUsername owns the internal account that fees are paid into.
+const feeIncomeUsername = "bank-fee-income"
+
+// ensureFeeIncomeAccount creates the fee income account the first time the server starts.
+// Nobody can log in to it, its password is a random value that is never shown.
+func (s *SQLiteStore) ensureFeeIncomeAccount() error {
+	income, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE "username" = ?`, feeIncomeUsername))
+	if err == sql.ErrNoRows {
+		password, err := misc.HashPassword(misc.Generate())
+		if err != nil {
+			return err
+		}
+		if err := s.CreateAccount(account.CreateAccount("Fee", "Income", feeIncomeUsername, password, account.TypeInternal), nil); err != nil {
+			return err
+		}
+		income, err = ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE "username" = ?`, feeIncomeUsername))
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	s.feeAccountID = income.ID
+	return nil
+}
+
+func (s *SQLiteStore) QuoteFee(id int, operation string, amount int) (*fee.Quote, error) {
+	if err := fee.ValidateOperation(operation); err != nil {
+		return nil, err
+	}
+	quote, err := s.quoteFee(s.db, id, operation, amount, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// quoteFee prices an operation against the fee schedule, counting the operations of the same
+// kind account id has already made in the calendar month of now. Card captures of holds are
+// never charged as withdrawals, so they don't use up the free ones either.
+func (s *SQLiteStore) quoteFee(db DBTX, id int, operation string, amount int, now time.Time) (fee.Quote, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	query := `SELECT COUNT(*) FROM "transfer" WHERE "from" = ? AND "action" = 'withdrawal' AND "completed_at" >= ? AND `
+	if operation == fee.OperationWithdrawal {
+		query += `"to" = "from" AND NOT EXISTS (SELECT 1 FROM "hold" WHERE "hold"."transfer_id" = "transfer"."id")`
+	} else {
+		query += `"to" <> "from"`
+	}
+	var made int
+	if err := db.QueryRow(query, id, monthStart).Scan(&made); err != nil {
+		return fee.Quote{}, err
+	}
+	return s.fees.Quote(operation, amount, made), nil
+}
+
+// chargeFee takes the quoted fee from account and pays it into the fee income account, as a
+// ledger entry of its own on each side. account.Balance is updated to match.
+func (s *SQLiteStore) chargeFee(tx DBTX, account *account.Account, quote fee.Quote, reference string, now time.Time) error {
+	if quote.Fee == 0 {
+		return nil
+	}
+	income, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, s.feeAccountID))
+	if err != nil {
+		return fmt.Errorf("fee income account is missing")
+	}
+	previous, incomePrevious := account.Balance, income.Balance
+	account.Balance -= int64(quote.Fee)
+	income.Balance += int64(quote.Fee)
+	if err := updateBalance(tx, account.Balance, account.ID); err != nil {
+		return err
+	}
+	if err := updateBalance(tx, income.Balance, income.ID); err != nil {
+		return err
+	}
+	charged := transfer.CreateTransfer(account.ID, income.ID, quote.Fee, previous, account.Balance, "fee", now)
+	received := transfer.CreateTransfer(account.ID, income.ID, quote.Fee, incomePrevious, income.Balance, "fee-income", now)
+	for _, trans := range []*transfer.Transfer{charged, received} {
+		trans.FromBankNumber, trans.ToBankNumber = account.BankNumber, income.BankNumber
+		trans.Reference = reference
+		trans.Memo = strings.Join(quote.Rules, ", ")
+	}
+	if err := insertTransferLegs(tx, charged, received); err != nil {
+		return fmt.Errorf("Could Not Add Transaction To Table")
+	}
+	return nil
+}
+
+//
+// Hold
+//
+
+func (s *SQLiteStore) CreateHoldTable() error {
+	_, holdTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "hold" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"captured" INTEGER NOT NULL,
+			"merchant" TEXT NOT NULL,
+			"reference" TEXT NOT NULL,
+			"status" TEXT NOT NULL,
+			"created_at" TIMESTAMP,
+			"expires_at" TIMESTAMP,
+			"settled_at" TIMESTAMP,
+			"transfer_id" INTEGER NOT NULL
+		)`,
+	)
+	if holdTblErr != nil {
+		return holdTblErr
+	}
+	return nil
+}
+
+// heldAmount is the money reserved on account id by holds that are still active.
+func heldAmount(db DBTX, id int) (int64, error) {
+	var held int64
+	err := db.QueryRow(`SELECT COALESCE(SUM("amount"), 0) FROM "hold" WHERE "account_id" = ? AND "status" = ?`, id, hold.StatusActive).Scan(&held)
+	return held, err
+}
+
+// available is what can still be taken from acc: its balance and overdraft less any holds.
+func available(db DBTX, acc *account.Account) (int64, error) {
+	held, err := heldAmount(db, acc.ID)
+	if err != nil {
+		return 0, err
+	}
+	return acc.Available() - held, nil
+}
+
+// CreateHold authorises a card payment, reserving the amount if it is available.
+func (s *SQLiteStore) CreateHold(h *hold.Hold) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, h.AccountID))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	if err := acc.Allows(account.OperationDebit); err != nil {
+		return err
+	}
+	if err := s.checkLimits(tx, acc, h.Amount, h.CreatedAt); err != nil {
+		return err
+	}
+	funds, err := available(tx, acc)
+	if err != nil {
+		return err
+	}
+	if funds < int64(h.Amount) {
+		return fmt.Errorf("insufficent funds")
+	}
+	result, err := tx.Exec(`
+	INSERT INTO "hold" (
+		"account_id",
+		"amount",
+		"captured",
+		"merchant",
+		"reference",
+		"status",
+		"created_at",
+		"expires_at",
+		"settled_at",
+		"transfer_id") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, h.AccountID, h.Amount, h.Captured, h.Merchant, h.Reference, h.Status, h.CreatedAt, h.ExpiresAt, h.SettledAt, h.TransferID)
+	if err != nil {
+		fmt.Println("error adding to hold table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	h.ID = int(id)
+	if err := s.audit(tx, "hold.create", audit.TargetHold, h.ID, nil, h); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetHold(id int) (*hold.Hold, error) {
+	h, err := ScanIntoHold(s.db.QueryRow(`SELECT * FROM "hold" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("Hold Does Not Exist")
+	}
+	return h, nil
+}
+
+func (s *SQLiteStore) GetHolds(accountID int) ([]*hold.Hold, error) {
+	holdArray := []*hold.Hold{}
+	row, err := s.db.Query(`SELECT * FROM "hold" WHERE "account_id" = ? ORDER BY "id" DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		h, err := ScanIntoHold(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in hold table", err)
+			return nil, err
+		}
+		holdArray = append(holdArray, h)
+	}
+	return holdArray, nil
+}
+
+// CaptureHold settles an active hold for amount, or all of it when amount is 0, as a card
+// withdrawal on the ledger. Whatever is not captured is released.
+func (s *SQLiteStore) CaptureHold(id int, amount int) (*hold.Hold, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	h, err := ScanIntoHold(tx.QueryRow(`SELECT * FROM "hold" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("Hold Does Not Exist")
+	}
+	if h.Status != hold.StatusActive {
+		return nil, fmt.Errorf("hold is already %s", h.Status)
+	}
+	now := time.Now().UTC()
+	before := *h
+	// a hold past its expiry is expired here rather than waiting for the next sweep, so it
+	// can never be captured late
+	if !now.Before(h.ExpiresAt) {
+		h.Status, h.SettledAt = hold.StatusExpired, now
+		if err := updateHold(tx, h); err != nil {
+			return nil, err
+		}
+		if err := s.audit(tx, "hold.expire", audit.TargetHold, h.ID, &before, h); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("hold is already %s", h.Status)
+	}
+	if amount == 0 {
+		amount = h.Amount
+	}
+	if amount < 0 || amount > h.Amount {
+		return nil, fmt.Errorf("capture must be between 1 and the held amount of %v", h.Amount)
+	}
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, h.AccountID))
+	if err != nil {
+		return nil, err
+	}
+	if err := acc.Allows(account.OperationDebit); err != nil {
+		return nil, err
+	}
+	previous := acc.Balance
+	acc.Balance -= int64(amount)
+	if err := updateBalance(tx, acc.Balance, acc.ID); err != nil {
+		return nil, err
+	}
+	withdrawal := transfer.CreateWithdrawal(acc.ID, &transfer.WithdrawalRequest{Amount: amount, Channel: transfer.ChannelCard, Reference: h.Reference}, previous, acc.Balance, now)
+	withdrawal.FromBankNumber, withdrawal.ToBankNumber = acc.BankNumber, acc.BankNumber
+	withdrawal.Memo = h.Merchant
+	if err := insertTransfer(tx, withdrawal); err != nil {
+		return nil, fmt.Errorf("Could Not Add Transaction To Table")
+	}
+	h.Captured, h.Status, h.SettledAt, h.TransferID = amount, hold.StatusCaptured, now, withdrawal.ID
+	if err := updateHold(tx, h); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "hold.capture", audit.TargetHold, h.ID, &before, h); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ReleaseHold gives the reserved money back without anything being paid.
+func (s *SQLiteStore) ReleaseHold(id int) (*hold.Hold, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	h, err := ScanIntoHold(tx.QueryRow(`SELECT * FROM "hold" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("Hold Does Not Exist")
+	}
+	if h.Status != hold.StatusActive {
+		return nil, fmt.Errorf("hold is already %s", h.Status)
+	}
+	before := *h
+	h.Status, h.SettledAt = hold.StatusReleased, time.Now().UTC()
+	if err := updateHold(tx, h); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "hold.release", audit.TargetHold, h.ID, &before, h); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ExpireHolds releases every active hold that has passed its expiry.
+func (s *SQLiteStore) ExpireHolds(now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	row, err := tx.Query(`SELECT * FROM "hold" WHERE "status" = ?`, hold.StatusActive)
+	if err != nil {
+		return err
+	}
+	expired := []*hold.Hold{}
+	for row.Next() {
+		h, err := ScanIntoHold(row)
+		if err != nil {
+			row.Close()
+			return err
+		}
+		if !h.ExpiresAt.After(now) {
+			expired = append(expired, h)
+		}
+	}
+	row.Close()
+	for _, h := range expired {
+		before := *h
+		h.Status, h.SettledAt = hold.StatusExpired, now
+		if err := updateHold(tx, h); err != nil {
+			return err
+		}
+		if err := s.audit(tx, "hold.expire", audit.TargetHold, h.ID, &before, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func updateHold(db DBTX, h *hold.Hold) error {
+	_, err := db.Exec(`UPDATE "hold" SET "captured" = ?, "status" = ?, "settled_at" = ?, "transfer_id" = ? WHERE "id" = ?`, h.Captured, h.Status, h.SettledAt, h.TransferID, h.ID)
+	return err
+}
+
+//
+// Reversal
+//
+
+func (s *SQLiteStore) CreateReversalTable() error {
+	_, reversalTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "reversal" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"transfer_id" INTEGER NOT NULL UNIQUE,
+			"reason" TEXT NOT NULL,
+			"actor_id" INTEGER NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"negative" BOOLEAN NOT NULL,
+			"created_at" TIMESTAMP
+		)`,
+	)
+	if reversalTblErr != nil {
+		return reversalTblErr
+	}
+	return nil
+}
+
+// transferLegs finds the sender's and the recipient's entries of the transfer that entry
+// id belongs to, which are linked to each other when they are written.
+func transferLegs(db DBTX, id int) (*transfer.Transfer, *transfer.Transfer, error) {
+	entry, err := ScanIntoTransfer(db.QueryRow(`SELECT * FROM "transfer" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Transfer Does Not Exist")
+	}
+	if entry.From == entry.To || (entry.Action != "withdrawal" && entry.Action != "deposit") {
+		return nil, nil, fmt.Errorf("only transfers between accounts can be reversed")
+	}
+	other, err := ScanIntoTransfer(db.QueryRow(`SELECT * FROM "transfer" WHERE "id" = ? AND "counterpart_id" = ?`, entry.CounterpartID, entry.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not find both sides of transfer %v", id)
+	}
+	if entry.Action == "deposit" {
+		return other, entry, nil
+	}
+	return entry, other, nil
+}
+
+// ReverseTransfer moves the money of a transfer back from the recipient to the sender. The
+// compensating entries point at the entries they undo and those are marked as reversed,
+// all in one transaction. Any fee charged for the original transfer is kept.
+func (s *SQLiteStore) ReverseTransfer(r *reversal.Reversal) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	sent, received, err := transferLegs(tx, r.TransferID)
+	if err != nil {
+		return err
+	}
+	if sent.ReversedBy != 0 {
+		return fmt.Errorf("transfer has already been reversed")
+	}
+	sender, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, sent.From))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	recipient, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, sent.To))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	if err := sender.Allows(account.OperationCredit); err != nil {
+		return err
+	}
+	if recipient.Status == account.StatusClosed {
+		return fmt.Errorf("account %v is closed", recipient.BankNumber)
+	}
+	funds, err := available(tx, recipient)
+	if err != nil {
+		return err
+	}
+	r.TransferID, r.Amount = sent.ID, sent.Amount
+	r.Negative = recipient.Balance-int64(sent.Amount) < 0
+	if funds < int64(sent.Amount) && reversal.Policy() == reversal.PolicyReject {
+		return fmt.Errorf("recipient no longer has the funds to reverse this transfer")
+	}
+	result, err := tx.Exec(`
+	INSERT INTO "reversal" (
+		"transfer_id",
+		"reason",
+		"actor_id",
+		"amount",
+		"negative",
+		"created_at") values ( ?, ?, ?, ?, ?, ?)
+	`, r.TransferID, r.Reason, r.ActorID, r.Amount, r.Negative, r.CreatedAt)
+	if err != nil {
+		fmt.Println("error adding to reversal table:", err)
+		return fmt.Errorf("transfer has already been reversed")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.ID = int(id)
+	recipientPrevious, senderPrevious := recipient.Balance, sender.Balance
+	recipient.Balance -= int64(r.Amount)
+	sender.Balance += int64(r.Amount)
+	if err := updateBalance(tx, recipient.Balance, recipient.ID); err != nil {
+		return err
+	}
+	if err := updateBalance(tx, sender.Balance, sender.ID); err != nil {
+		return err
+	}
+	debit := transfer.CreateTransfer(recipient.ID, sender.ID, r.Amount, recipientPrevious, recipient.Balance, "reversal-debit", r.CreatedAt)
+	debit.ReversalOf = received.ID
+	credit := transfer.CreateTransfer(recipient.ID, sender.ID, r.Amount, senderPrevious, sender.Balance, "reversal-credit", r.CreatedAt)
+	credit.ReversalOf = sent.ID
+	for _, trans := range []*transfer.Transfer{debit, credit} {
+		trans.FromBankNumber, trans.ToBankNumber = recipient.BankNumber, sender.BankNumber
+		trans.Memo, trans.Reference = r.Reason, sent.Reference
+	}
+	if err := insertTransferLegs(tx, debit, credit); err != nil {
+		return fmt.Errorf("Could Not Add Transaction To Table")
+	}
+	if _, err := tx.Exec(`UPDATE "transfer" SET "reversed_by" = ? WHERE "id" = ?`, credit.ID, sent.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE "transfer" SET "reversed_by" = ? WHERE "id" = ?`, debit.ID, received.ID); err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Transfer %v of %v has been reversed : %s", sent.ID, r.Amount, r.Reason)
+	for _, accountID := range []int{sender.ID, recipient.ID} {
+		if err := insertNotification(tx, notification.CreateNotification(accountID, notification.KindTransferReversed, message)); err != nil {
+			return err
+		}
+	}
+	if err := s.audit(tx, "transfer.reverse", audit.TargetTransfer, sent.ID, nil, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+//
+// Joint
+//
+
+func (s *SQLiteStore) CreateJointTables() error {
+	_, ownerTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "account_owner" (
+			"account_id" INTEGER NOT NULL,
+			"owner_id" INTEGER NOT NULL,
+			"added_at" TIMESTAMP,
+			PRIMARY KEY ("account_id", "owner_id")
+		)`,
+	)
+	if ownerTblErr != nil {
+		return ownerTblErr
+	}
+	_, ruleTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "signing_rule" (
+			"account_id" INTEGER PRIMARY KEY,
+			"rule" TEXT NOT NULL,
+			"required" INTEGER NOT NULL,
+			"above" INTEGER NOT NULL
+		)`,
+	)
+	if ruleTblErr != nil {
+		return ruleTblErr
+	}
+	_, pendingTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "pending_transfer" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"requested_by" INTEGER NOT NULL,
+			"to_bank_number" INTEGER NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"memo" TEXT NOT NULL,
+			"reference" TEXT NOT NULL,
+			"category" TEXT NOT NULL,
+			"required" INTEGER NOT NULL,
+			"status" TEXT NOT NULL,
+			"created_at" TIMESTAMP,
+			"decided_at" TIMESTAMP
+		)`,
+	)
+	if pendingTblErr != nil {
+		return pendingTblErr
+	}
+	// every owner decides once per pending transfer
+	_, approvalTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "transfer_approval" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"pending_id" INTEGER NOT NULL,
+			"owner_id" INTEGER NOT NULL,
+			"decision" TEXT NOT NULL,
+			"comment" TEXT NOT NULL,
+			"created_at" TIMESTAMP,
+			UNIQUE ("pending_id", "owner_id")
+		)`,
+	)
+	if approvalTblErr != nil {
+		return approvalTblErr
+	}
+	return nil
+}
+
+// AddOwner makes the customer with username a co-owner of account accountID.
+func (s *SQLiteStore) AddOwner(accountID int, username string) (*joint.Owner, error) {
+	owner, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE "username" = ?`, username))
+	if err != nil {
+		return nil, fmt.Errorf("Account Does Not Exist")
+	}
+	if owner.ID == accountID {
+		return nil, fmt.Errorf("account holder is already an owner")
+	}
+	if owner.Type == account.TypeInternal {
+		return nil, fmt.Errorf("account cannot be an owner")
+	}
+	o := &joint.Owner{
+		AccountID:  accountID,
+		OwnerID:    owner.ID,
+		Username:   owner.Username,
+		BankNumber: owner.BankNumber,
+		AddedAt:    time.Now().UTC(),
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO "account_owner" ("account_id", "owner_id", "added_at") values ( ?, ?, ?)`, o.AccountID, o.OwnerID, o.AddedAt)
+	if err != nil {
+		fmt.Println("error adding to account owner table:", err)
+		return nil, fmt.Errorf("%s is already an owner", username)
+	}
+	if err := s.audit(tx, "account.add-owner", audit.TargetAccount, accountID, nil, o); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (s *SQLiteStore) GetOwners(accountID int) ([]*joint.Owner, error) {
+	ownerArray := []*joint.Owner{}
+	row, err := s.db.Query(`
+		SELECT "account_owner"."account_id", "account_owner"."owner_id", "account"."username", "account"."bank_number", "account_owner"."added_at"
+		FROM "account_owner" JOIN "account" ON "account"."id" = "account_owner"."owner_id"
+		WHERE "account_owner"."account_id" = ? ORDER BY "account_owner"."added_at"`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		o := new(joint.Owner)
+		if err := row.Scan(&o.AccountID, &o.OwnerID, &o.Username, &o.BankNumber, &o.AddedAt); err != nil {
+			fmt.Println("error with scanning rows in account owner table", err)
+			return nil, err
+		}
+		ownerArray = append(ownerArray, o)
+	}
+	return ownerArray, nil
+}
+
+// IsOwner reports whether ownerID is a co-owner of accountID. The account holder is not
+// listed as a co-owner of their own account.
+func (s *SQLiteStore) IsOwner(accountID, ownerID int) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM "account_owner" WHERE "account_id" = ? AND "owner_id" = ?`, accountID, ownerID).Scan(&count)
+	return count > 0, err
+}
+
+// SetSigningRule changes who has to approve transfers from the account. The holder counts as
+// one of the owners the rule is checked against.
+func (s *SQLiteStore) SetSigningRule(rule *joint.SigningRule) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var owners int
+	if err := tx.QueryRow(`SELECT COUNT(*) + 1 FROM "account_owner" WHERE "account_id" = ?`, rule.AccountID).Scan(&owners); err != nil {
+		return err
+	}
+	if err := joint.ValidateRule(rule, owners); err != nil {
+		return err
+	}
+	before, err := signingRule(tx, rule.AccountID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	INSERT INTO "signing_rule" ("account_id", "rule", "required", "above") values ( ?, ?, ?, ?)
+	ON CONFLICT ("account_id") DO UPDATE SET "rule" = excluded."rule", "required" = excluded."required", "above" = excluded."above"
+	`, rule.AccountID, rule.Rule, rule.Required, rule.Above)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, "account.signing-rule", audit.TargetAccount, rule.AccountID, before, rule); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetSigningRule(accountID int) (*joint.SigningRule, error) {
+	return signingRule(s.db, accountID)
+}
+
+func signingRule(db DBTX, accountID int) (*joint.SigningRule, error) {
+	rule := joint.DefaultRule(accountID)
+	err := db.QueryRow(`SELECT "rule", "required", "above" FROM "signing_rule" WHERE "account_id" = ?`, accountID).Scan(
+		&rule.Rule,
+		&rule.Required,
+		&rule.Above)
+	if err == sql.ErrNoRows {
+		return rule, nil
+	}
+	return rule, err
+}
+
+// requestApproval holds a transfer until the owners of account id approve it.
+func (s *SQLiteStore) requestApproval(tx DBTX, id, requestedBy int, request *transfer.TransferRequest, required int) (*transfer.TransferResponse, error) {
+	acc, toAccount, err := checkTransferRequest(tx, id, request)
+	if err != nil {
+		return nil, err
+	}
+	p := joint.CreatePendingTransfer(id, requestedBy, toAccount.BankNumber, request, required)
+	result, err := tx.Exec(`
+	INSERT INTO "pending_transfer" (
+		"account_id",
+		"requested_by",
+		"to_bank_number",
+		"amount",
+		"memo",
+		"reference",
+		"category",
+		"required",
+		"status",
+		"created_at",
+		"decided_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.AccountID, p.RequestedBy, p.ToBankNumber, p.Amount, p.Memo, p.Reference, p.Category, p.Required, p.Status, p.CreatedAt, p.DecidedAt)
+	if err != nil {
+		fmt.Println("error adding to pending transfer table:", err)
+		return nil, err
+	}
+	pendingID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	p.ID = int(pendingID)
+	// asking for the transfer counts as the first approval
+	if err := insertApproval(tx, joint.CreateApproval(p.ID, requestedBy, joint.DecisionApprove, "")); err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("A transfer of %v to %v is waiting for your approval", p.Amount, p.ToBankNumber)
+	for _, ownerID := range ownerIDs(tx, id) {
+		if ownerID == requestedBy {
+			continue
+		}
+		if err := insertNotification(tx, notification.CreateNotification(ownerID, notification.KindApprovalRequested, message)); err != nil {
+			return nil, err
+		}
+	}
+	balance, err := myBalance(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer.TransferResponse{
+		Account: *balance,
+		Recipient: transfer.Payee{
+			BankNumber: toAccount.BankNumber,
+			Name:       misc.MaskName(toAccount.FirstName + " " + toAccount.LastName),
+		},
+		Sent:      false,
+		PendingID: p.ID,
+	}, nil
+}
+
+// checkTransferRequest checks what it can of a transfer that is going to wait before being
+// sent, so nobody is asked to approve a transfer that could never be sent. Limits and funds
+// are only checked when it is sent.
+func checkTransferRequest(tx DBTX, id int, request *transfer.TransferRequest) (*account.Account, *account.Account, error) {
+	if request.Amount <= 0 {
+		return nil, nil, fmt.Errorf("invalid amount - > cannot complete transaction")
+	}
+	if err := transfer.ValidateDetails(request.Memo, request.Reference, request.Category); err != nil {
+		return nil, nil, err
+	}
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := acc.Allows(account.OperationDebit); err != nil {
+		return nil, nil, err
+	}
+	toAccount, err := resolveTransferRecipient(tx, id, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if toAccount.ID == acc.ID {
+		return nil, nil, fmt.Errorf("cannot transfer to your own account")
+	}
+	if err := toAccount.Allows(account.OperationCredit); err != nil {
+		return nil, nil, fmt.Errorf("recipient cannot receive payments")
+	}
+	return acc, toAccount, nil
+}
+
+// ownerIDs is the holder of account id followed by its co-owners.
+func ownerIDs(db DBTX, id int) []int {
+	ids := []int{id}
+	row, err := db.Query(`SELECT "owner_id" FROM "account_owner" WHERE "account_id" = ?`, id)
+	if err != nil {
+		return ids
+	}
+	defer row.Close()
+	for row.Next() {
+		var ownerID int
+		if err := row.Scan(&ownerID); err == nil {
+			ids = append(ids, ownerID)
+		}
+	}
+	return ids
+}
+
+func insertApproval(db DBTX, a *joint.Approval) error {
+	result, err := db.Exec(`
+	INSERT INTO "transfer_approval" (
+		"pending_id",
+		"owner_id",
+		"decision",
+		"comment",
+		"created_at") values ( ?, ?, ?, ?, ?)
+	`, a.PendingID, a.OwnerID, a.Decision, a.Comment, a.CreatedAt)
+	if err != nil {
+		fmt.Println("error adding to transfer approval table:", err)
+		return fmt.Errorf("you have already decided on this transfer")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+	return nil
+}
+
+func (s *SQLiteStore) GetPendingTransfers(accountID int) ([]*joint.PendingTransfer, error) {
+	pendingArray := []*joint.PendingTransfer{}
+	row, err := s.db.Query(`SELECT * FROM "pending_transfer" WHERE "account_id" = ? ORDER BY "id" DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		p, err := ScanIntoPendingTransfer(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in pending transfer table", err)
+			return nil, err
+		}
+		pendingArray = append(pendingArray, p)
+	}
+	for _, p := range pendingArray {
+		if p.Approvals, err = getApprovals(s.db, p.ID); err != nil {
+			return nil, err
+		}
+	}
+	return pendingArray, nil
+}
+
+func getApprovals(db DBTX, pendingID int) ([]*joint.Approval, error) {
+	approvalArray := []*joint.Approval{}
+	row, err := db.Query(`SELECT * FROM "transfer_approval" WHERE "pending_id" = ? ORDER BY "id"`, pendingID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		a := new(joint.Approval)
+		if err := row.Scan(&a.ID, &a.PendingID, &a.OwnerID, &a.Decision, &a.Comment, &a.CreatedAt); err != nil {
+			fmt.Println("error with scanning rows in transfer approval table", err)
+			return nil, err
+		}
+		approvalArray = append(approvalArray, a)
+	}
+	return approvalArray, nil
+}
+
+// DecidePendingTransfer records ownerID approving or rejecting a pending transfer. The
+// approval that brings it up to the number required sends the transfer in the same
+// transaction. If it can no longer be sent, e.g. for lack of funds, it is marked failed.
+func (s *SQLiteStore) DecidePendingTransfer(accountID, pendingID, ownerID int, decision, comment string) (*joint.PendingTransfer, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	p, err := ScanIntoPendingTransfer(tx.QueryRow(`SELECT * FROM "pending_transfer" WHERE "id" = ? AND "account_id" = ?`, pendingID, accountID))
+	if err != nil {
+		return nil, fmt.Errorf("Pending Transfer Does Not Exist")
+	}
+	if p.Status != joint.StatusPending {
+		return nil, fmt.Errorf("transfer is already %s", p.Status)
+	}
+	before := *p
+	if err := insertApproval(tx, joint.CreateApproval(p.ID, ownerID, decision, comment)); err != nil {
+		return nil, err
+	}
+	var failure error
+	if decision == joint.DecisionReject {
+		p.Status = joint.StatusRejected
+	} else {
+		var approvals int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM "transfer_approval" WHERE "pending_id" = ? AND "decision" = ?`, p.ID, joint.DecisionApprove).Scan(&approvals); err != nil {
+			return nil, err
+		}
+		if approvals >= p.Required {
+			if failure, err = s.executePending(tx, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.Status != joint.StatusPending {
+		p.DecidedAt = time.Now().UTC()
+		if _, err := tx.Exec(`UPDATE "pending_transfer" SET "status" = ?, "decided_at" = ? WHERE "id" = ?`, p.Status, p.DecidedAt, p.ID); err != nil {
+			return nil, err
+		}
+	}
+	if p.Approvals, err = getApprovals(tx, p.ID); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "pending-transfer."+decision, audit.TargetPending, p.ID, &before, p); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if failure != nil {
+		return nil, fmt.Errorf("transfer was approved but could not be sent : %v", failure)
+	}
+	return p, nil
+}
+
+// executePending sends an approved transfer, or passes it on for review, and sets its status
+// to match. If it cannot be sent the failure is returned and the attempt is rolled back to a
+// savepoint, so the decision that led to it is still recorded.
+func (s *SQLiteStore) executePending(tx *storeTx, p *joint.PendingTransfer) (failure error, err error) {
+	events, err := tx.savepoint("execute_pending")
+	if err != nil {
+		return nil, err
+	}
+	response, failure := s.screenAndSend(tx, p.AccountID, p.RequestedBy, p.TransferRequest())
+	if failure != nil {
+		p.Status = joint.StatusFailed
+		if err := tx.rollbackTo("execute_pending", events); err != nil {
+			return nil, err
+		}
+		return failure, saveBlockedDecision(tx, failure)
+	}
+	p.Status = joint.StatusExecuted
+	if response.ReviewID != 0 {
+		p.Status = joint.StatusInReview
+	}
+	_, err = tx.Exec(`RELEASE "execute_pending"`)
+	return nil, err
+}
+
+//
+// Review
+//
+
+func (s *SQLiteStore) CreateReviewTable() error {
+	_, reviewTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "review_item" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"maker_id" INTEGER NOT NULL,
+			"to_bank_number" INTEGER NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"memo" TEXT NOT NULL,
+			"reference" TEXT NOT NULL,
+			"category" TEXT NOT NULL,
+			"status" TEXT NOT NULL,
+			"reviewer_id" INTEGER NOT NULL,
+			"comment" TEXT NOT NULL,
+			"created_at" TIMESTAMP,
+			"expires_at" TIMESTAMP,
+			"decided_at" TIMESTAMP,
+			"reason" TEXT NOT NULL DEFAULT ''
+		)`,
+	)
+	if reviewTblErr != nil {
+		return reviewTblErr
+	}
+	return s.addColumn("review_item", "reason", `TEXT NOT NULL DEFAULT ''`)
+}
+
+// sendOrReview sends a transfer from account id straight away, or holds it for a reviewer
+// when it is at or above the review threshold.
+func (s *SQLiteStore) sendOrReview(tx DBTX, id, makerID int, request *transfer.TransferRequest) (*transfer.TransferResponse, error) {
+	if !review.NeedsReview(request.Amount) {
+		return s.makeTransfer(tx, id, request)
+	}
+	acc, toAccount, err := checkTransferRequest(tx, id, request)
+	if err != nil {
+		return nil, err
+	}
+	return holdForReview(tx, acc, toAccount, makerID, request, fmt.Sprintf("%v is at or above the review threshold", request.Amount))
+}
+
+// holdForReview puts a transfer from acc to toAccount in the review queue, with the reason it needs reviewing.
+func holdForReview(tx DBTX, acc, toAccount *account.Account, makerID int, request *transfer.TransferRequest, reason string) (*transfer.TransferResponse, error) {
+	item := review.CreateItem(acc.ID, makerID, toAccount.BankNumber, request, reason)
+	result, err := tx.Exec(`
+	INSERT INTO "review_item" (
+		"account_id",
+		"maker_id",
+		"to_bank_number",
+		"amount",
+		"memo",
+		"reference",
+		"category",
+		"status",
+		"reviewer_id",
+		"comment",
+		"created_at",
+		"expires_at",
+		"decided_at",
+		"reason") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, item.AccountID, item.MakerID, item.ToBankNumber, item.Amount, item.Memo, item.Reference, item.Category,
+		item.Status, item.ReviewerID, item.Comment, item.CreatedAt, item.ExpiresAt, item.DecidedAt, item.Reason)
+	if err != nil {
+		fmt.Println("error adding to review item table:", err)
+		return nil, err
+	}
+	itemID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	balance, err := myBalance(tx, acc)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer.TransferResponse{
+		Account: *balance,
+		Recipient: transfer.Payee{
+			BankNumber: toAccount.BankNumber,
+			Name:       misc.MaskName(toAccount.FirstName + " " + toAccount.LastName),
+		},
+		Sent:     false,
+		ReviewID: int(itemID),
+	}, nil
+}
+
+// GetReviewItems lists the review queue, optionally only the items with status.
+func (s *SQLiteStore) GetReviewItems(status string) ([]*review.Item, error) {
+	if status == "" {
+		return queryReviewItems(s.db, `SELECT * FROM "review_item" ORDER BY "id"`)
+	}
+	return queryReviewItems(s.db, `SELECT * FROM "review_item" WHERE "status" = ? ORDER BY "id"`, status)
+}
+
+func (s *SQLiteStore) GetAccountReviewItems(accountID int) ([]*review.Item, error) {
+	return queryReviewItems(s.db, `SELECT * FROM "review_item" WHERE "account_id" = ? ORDER BY "id" DESC`, accountID)
+}
+
+func queryReviewItems(db DBTX, query string, args ...interface{}) ([]*review.Item, error) {
+	itemArray := []*review.Item{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		item, err := ScanIntoReviewItem(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in review item table", err)
+			return nil, err
+		}
+		itemArray = append(itemArray, item)
+	}
+	return itemArray, nil
+}
+
+// DecideReviewItem approves or rejects a held transfer. Approving it sends the transfer in the
+// same transaction, so the item is only marked approved if the money actually moved. Nobody
+// can decide on a transfer they asked for or that is sent from their own account.
+func (s *SQLiteStore) DecideReviewItem(id, reviewerID int, approve bool, comment string) (*review.Item, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	item, err := ScanIntoReviewItem(tx.QueryRow(`SELECT * FROM "review_item" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("Review Item Does Not Exist")
+	}
+	if item.Status != review.StatusPending {
+		return nil, fmt.Errorf("transfer has already been %s", item.Status)
+	}
+	if reviewerID == item.MakerID || reviewerID == item.AccountID {
+		return nil, fmt.Errorf("you cannot review your own transfer")
+	}
+	before := *item
+	now := time.Now().UTC()
+	if !now.Before(item.ExpiresAt) {
+		if err := finishReviewItem(tx, item, review.StatusExpired, 0, "", now); err != nil {
+			return nil, err
+		}
+		// the item ran out of time rather than being decided, so it is the system expiring it
+		if err := s.system().audit(tx, "review-item."+review.StatusExpired, audit.TargetReview, item.ID, &before, item); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transfer expired before it was reviewed")
+	}
+	status := review.StatusRejected
+	if approve {
+		status = review.StatusApproved
+		if _, err := s.makeTransfer(tx, item.AccountID, item.TransferRequest()); err != nil {
+			return nil, err
+		}
+	}
+	if err := finishReviewItem(tx, item, status, reviewerID, comment, now); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "review-item."+status, audit.TargetReview, item.ID, &before, item); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ExpireReviewItems expires every pending item nobody reviewed in time.
+func (s *SQLiteStore) ExpireReviewItems(now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	items, err := queryReviewItems(tx, `SELECT * FROM "review_item" WHERE "status" = ? AND "expires_at" <= ?`, review.StatusPending, now)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		before := *item
+		if err := finishReviewItem(tx, item, review.StatusExpired, 0, "", now); err != nil {
+			return err
+		}
+		if err := s.audit(tx, "review-item."+review.StatusExpired, audit.TargetReview, item.ID, &before, item); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// finishReviewItem records the outcome of a review and tells the account holder about it.
+func finishReviewItem(db DBTX, item *review.Item, status string, reviewerID int, comment string, now time.Time) error {
+	item.Status, item.ReviewerID, item.Comment, item.DecidedAt = status, reviewerID, comment, now
+	_, err := db.Exec(`UPDATE "review_item" SET "status" = ?, "reviewer_id" = ?, "comment" = ?, "decided_at" = ? WHERE "id" = ?`,
+		item.Status, item.ReviewerID, item.Comment, item.DecidedAt, item.ID)
+	if err != nil {
+		return err
+	}
+	if err := closeSanctionsHits(db, item); err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Your transfer of %v to %v was %s", item.Amount, item.ToBankNumber, status)
+	if comment != "" {
+		message += " : " + comment
+	}
+	return insertNotification(db, notification.CreateNotification(item.AccountID, notification.KindTransferReviewed, message))
+}
+
+//
+// Fraud
+//
+
+func (s *SQLiteStore) CreateFraudDecisionTable() error {
+	_, fraudTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "fraud_decision" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"to_bank_number" INTEGER NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"decision" TEXT NOT NULL,
+			"score" INTEGER NOT NULL,
+			"reasons" TEXT NOT NULL,
+			"created_at" TIMESTAMP
+		)`,
+	)
+	if fraudTblErr != nil {
+		return fraudTblErr
+	}
+	return nil
+}
+
+// screenAndSend screens both sides of a transfer from account id against the sanctions list
+// and runs the fraud rules over it before sending it. A transfer with a sanctions hit, or
+// one the fraud rules want reviewed, is held for review. One the fraud rules block is refused
+// with a *fraud.BlockedError. The decision is saved as part of tx, except for a block, which
+// the caller saves with saveBlockedDecision once the rest of tx has been undone.
+func (s *SQLiteStore) screenAndSend(tx DBTX, id, makerID int, request *transfer.TransferRequest) (*transfer.TransferResponse, error) {
+	acc, toAccount, err := checkTransferRequest(tx, id, request)
+	if err != nil {
+		return nil, err
+	}
+	for _, party := range []*account.Account{acc, toAccount} {
+		if match := s.sanctions.Screen(fullName(party)); match != nil {
+			return holdForSanctionsHit(tx, acc, toAccount, makerID, request, party, match)
+		}
+	}
+	assessment, err := s.assessTransfer(tx, acc, toAccount.BankNumber, request.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if assessment.Decision == fraud.DecisionBlock {
+		return nil, &fraud.BlockedError{Message: "transfer blocked : please contact us", Assessment: assessment}
+	}
+	if err := insertFraudDecision(tx, assessment); err != nil {
+		return nil, err
+	}
+	if assessment.Decision == fraud.DecisionReview {
+		return holdForReview(tx, acc, toAccount, makerID, request, "fraud rules : "+assessment.Summary())
+	}
+	return s.sendOrReview(tx, id, makerID, request)
+}
+
+// screenWithdrawal runs a withdrawal from acc past the sanctions list and the fraud rules.
+// Nobody can review cash once it has been handed over, so anything that would hold a
+// transfer for review refuses the withdrawal instead, and a sanctions hit puts the account into
+// compliance review until the hit is decided. The hit or decision behind a refusal is
+// committed on its own, and the caller's transaction must not be used afterwards.
+func (s *SQLiteStore) screenWithdrawal(tx *storeTx, acc *account.Account, amount int) error {
+	if match := s.sanctions.Screen(fullName(acc)); match != nil {
+		if err := insertSanctionsHit(tx, sanctions.CreateHit(acc.ID, match)); err != nil {
+			return err
+		}
+		before := *acc
+		if err := updateStatus(tx, acc, account.StatusComplianceReview, time.Now().UTC()); err != nil {
+			return err
+		}
+		if err := s.audit(tx, "account.status", audit.TargetAccount, acc.ID, &before, acc); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return fmt.Errorf("withdrawal refused : please contact us")
+	}
+	assessment, err := s.assessTransfer(tx, acc, 0, amount)
+	if err != nil {
+		return err
+	}
+	if err := insertFraudDecision(tx, assessment); err != nil {
+		return err
+	}
+	if assessment.Decision == fraud.DecisionAllow {
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return &fraud.BlockedError{Message: "withdrawal blocked : please contact us", Assessment: assessment}
+}
+
+// assessTransfer gathers what the fraud rules need to know about a transfer and runs them.
+// toBankNumber is 0 for a withdrawal.
+func (s *SQLiteStore) assessTransfer(db DBTX, acc *account.Account, toBankNumber int64, amount int) (*fraud.Assessment, error) {
+	now := time.Now().UTC()
+	funds, err := available(db, acc)
+	if err != nil {
+		return nil, err
+	}
+	in := &fraud.Input{
+		AccountID:    acc.ID,
+		ToBankNumber: toBankNumber,
+		Amount:       amount,
+		Available:    funds,
+		History:      []fraud.Payment{},
+		Now:          now,
+	}
+	err = db.QueryRow(`SELECT "created_at" FROM "beneficiary" WHERE "account_id" = ? AND "bank_number" = ?`, acc.ID, toBankNumber).Scan(&in.PayeeSavedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	row, err := db.Query(`SELECT "to_bank_number", "amount", "completed_at" FROM "transfer" WHERE "from" = ? AND "to" != ? AND "action" = 'withdrawal' AND "completed_at" >= ? ORDER BY "id"`,
+		acc.ID, acc.ID, now.AddDate(0, 0, -fraud.HistoryDays))
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		payment := fraud.Payment{}
+		if err := row.Scan(&payment.ToBankNumber, &payment.Amount, &payment.At); err != nil {
+			return nil, err
+		}
+		in.History = append(in.History, payment)
+	}
+	return s.fraud.Assess(in), nil
+}
+
+// saveBlockedDecision saves the decision behind err if err is a transfer being blocked.
+func saveBlockedDecision(db DBTX, err error) error {
+	var blocked *fraud.BlockedError
+	if !errors.As(err, &blocked) {
+		return nil
+	}
+	return insertFraudDecision(db, blocked.Assessment)
+}
+
+func insertFraudDecision(db DBTX, a *fraud.Assessment) error {
+	reasons, err := json.Marshal(a.Reasons)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(`
+	INSERT INTO "fraud_decision" (
+		"account_id",
+		"to_bank_number",
+		"amount",
+		"decision",
+		"score",
+		"reasons",
+		"created_at") values ( ?, ?, ?, ?, ?, ?, ?)
+	`, a.AccountID, a.ToBankNumber, a.Amount, a.Decision, a.Score, string(reasons), a.CreatedAt)
+	if err != nil {
+		fmt.Println("error adding to fraud decision table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+	return nil
+}
+
+func (s *SQLiteStore) GetFraudDecisions(accountID int) ([]*fraud.Assessment, error) {
+	decisionArray := []*fraud.Assessment{}
+	row, err := s.db.Query(`SELECT * FROM "fraud_decision" WHERE "account_id" = ? ORDER BY "id" DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		a := new(fraud.Assessment)
+		var reasons string
+		if err := row.Scan(&a.ID, &a.AccountID, &a.ToBankNumber, &a.Amount, &a.Decision, &a.Score, &reasons, &a.CreatedAt); err != nil {
+			fmt.Println("error with scanning rows in fraud decision table", err)
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(reasons), &a.Reasons); err != nil {
+			return nil, err
+		}
+		decisionArray = append(decisionArray, a)
+	}
+	return decisionArray, nil
+}
+
+//
+// Sanctions
+//
+
+func (s *SQLiteStore) CreateSanctionsHitTable() error {
+	_, hitTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "sanctions_hit" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"review_id" INTEGER NOT NULL DEFAULT 0,
+			"screened_name" TEXT NOT NULL,
+			"entry_uid" TEXT NOT NULL,
+			"entry_name" TEXT NOT NULL,
+			"score" REAL NOT NULL,
+			"status" TEXT NOT NULL DEFAULT 'open',
+			"reviewer_id" INTEGER NOT NULL DEFAULT 0,
+			"comment" TEXT NOT NULL DEFAULT '',
+			"created_at" TIMESTAMP,
+			"decided_at" TIMESTAMP
+		)`,
+	)
+	if hitTblErr != nil {
+		return hitTblErr
+	}
+	return nil
+}
+
+// ReloadSanctionsList reads the sanctions list from its file again. Names are screened
+// against the new list from then on.
+func (s *SQLiteStore) ReloadSanctionsList() (sanctions.ListInfo, error) {
+	before := s.sanctions.Info()
+	if err := s.sanctions.Load(sanctions.ListFile()); err != nil {
+		return s.sanctions.Info(), err
+	}
+	after := s.sanctions.Info()
+	tx, err := s.begin()
+	if err != nil {
+		return after, err
+	}
+	defer tx.Rollback()
+	if err := s.audit(tx, "sanctions-list.reload", audit.TargetSanctionsList, 0, before, after); err != nil {
+		return after, err
+	}
+	return after, tx.Commit()
+}
+
+func (s *SQLiteStore) SanctionsListInfo() sanctions.ListInfo {
+	return s.sanctions.Info()
+}
+
+func fullName(acc *account.Account) string {
+	return acc.FirstName + " " + acc.LastName
+}
+
+// holdForSanctionsHit holds a transfer for review because party, one side of it, matched the
+// sanctions list, and records the hit against the review item.
+func holdForSanctionsHit(tx DBTX, acc, toAccount *account.Account, makerID int, request *transfer.TransferRequest, party *account.Account, match *sanctions.Match) (*transfer.TransferResponse, error) {
+	response, err := holdForReview(tx, acc, toAccount, makerID, request, "sanctions screening : "+match.Summary())
+	if err != nil {
+		return nil, err
+	}
+	hit := sanctions.CreateHit(party.ID, match)
+	hit.ReviewID = response.ReviewID
+	if err := insertSanctionsHit(tx, hit); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func insertSanctionsHit(db DBTX, hit *sanctions.Hit) error {
+	result, err := db.Exec(`
+	INSERT INTO "sanctions_hit" (
+		"account_id",
+		"review_id",
+		"screened_name",
+		"entry_uid",
+		"entry_name",
+		"score",
+		"status",
+		"reviewer_id",
+		"comment",
+		"created_at",
+		"decided_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, hit.AccountID, hit.ReviewID, hit.ScreenedName, hit.EntryUID, hit.EntryName, hit.Score,
+		hit.Status, hit.ReviewerID, hit.Comment, hit.CreatedAt, hit.DecidedAt)
+	if err != nil {
+		fmt.Println("error adding to sanctions hit table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	hit.ID = int(id)
+	return nil
+}
+
+// GetSanctionsHits lists the hits waiting on compliance, or every hit with status.
+func (s *SQLiteStore) GetSanctionsHits(status string) ([]*sanctions.Hit, error) {
+	if status == "" {
+		return querySanctionsHits(s.db, `SELECT * FROM "sanctions_hit" ORDER BY "id"`)
+	}
+	return querySanctionsHits(s.db, `SELECT * FROM "sanctions_hit" WHERE "status" = ? ORDER BY "id"`, status)
+}
+
+func querySanctionsHits(db DBTX, query string, args ...interface{}) ([]*sanctions.Hit, error) {
+	hitArray := []*sanctions.Hit{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		hit, err := ScanIntoSanctionsHit(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in sanctions hit table", err)
+			return nil, err
+		}
+		hitArray = append(hitArray, hit)
+	}
+	return hitArray, nil
+}
+
+// DecideSanctionsHit clears a hit as a false match or confirms it. Clearing an account's hit
+// makes the account active and confirming it freezes the account. A transfer's hit is decided
+// along with its review item, so it can only be decided here once that item has expired.
+func (s *SQLiteStore) DecideSanctionsHit(id, reviewerID int, clear bool, comment string) (*sanctions.Hit, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	hit, err := ScanIntoSanctionsHit(tx.QueryRow(`SELECT * FROM "sanctions_hit" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("Sanctions Hit Does Not Exist")
+	}
+	if hit.Status != sanctions.HitOpen {
+		return nil, fmt.Errorf("hit has already been %s", hit.Status)
+	}
+	if reviewerID == hit.AccountID {
+		return nil, fmt.Errorf("you cannot decide a hit on your own account")
+	}
+	status := sanctions.HitConfirmed
+	if clear {
+		status = sanctions.HitCleared
+	}
+	before := *hit
+	now := time.Now().UTC()
+	if hit.ReviewID != 0 {
+		var itemStatus string
+		if err := tx.QueryRow(`SELECT "status" FROM "review_item" WHERE "id" = ?`, hit.ReviewID).Scan(&itemStatus); err != nil {
+			return nil, err
+		}
+		if itemStatus == review.StatusPending {
+			return nil, fmt.Errorf("decide the held transfer through review item %d", hit.ReviewID)
+		}
+	} else {
+		acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, hit.AccountID))
+		if err != nil {
+			return nil, fmt.Errorf("Account Does Not Exist")
+		}
+		if acc.Status == account.StatusComplianceReview {
+			accountStatus := account.StatusFrozen
+			if clear {
+				accountStatus = account.StatusActive
+			}
+			if err := updateStatus(tx, acc, accountStatus, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := updateSanctionsHit(tx, hit, status, reviewerID, comment, now); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "sanctions-hit."+status, audit.TargetSanctionsHit, hit.ID, &before, hit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hit, nil
+}
+
+// closeSanctionsHits decides the open hits on a review item along with the item itself. An
+// approved transfer clears them and a rejected one confirms them. Hits on an item that
+// expired are left open for compliance to decide.
+func closeSanctionsHits(db DBTX, item *review.Item) error {
+	var status string
+	switch item.Status {
+	case review.StatusApproved:
+		status = sanctions.HitCleared
+	case review.StatusRejected:
+		status = sanctions.HitConfirmed
+	default:
+		return nil
+	}
+	_, err := db.Exec(`UPDATE "sanctions_hit" SET "status" = ?, "reviewer_id" = ?, "comment" = ?, "decided_at" = ? WHERE "review_id" = ? AND "status" = ?`,
+		status, item.ReviewerID, item.Comment, item.DecidedAt, item.ID, sanctions.HitOpen)
+	return err
+}
+
+func updateSanctionsHit(db DBTX, hit *sanctions.Hit, status string, reviewerID int, comment string, now time.Time) error {
+	hit.Status, hit.ReviewerID, hit.Comment, hit.DecidedAt = status, reviewerID, comment, now
+	_, err := db.Exec(`UPDATE "sanctions_hit" SET "status" = ?, "reviewer_id" = ?, "comment" = ?, "decided_at" = ? WHERE "id" = ?`,
+		hit.Status, hit.ReviewerID, hit.Comment, hit.DecidedAt, hit.ID)
+	return err
+}
+
+//
+// AML
+//
+
+func (s *SQLiteStore) CreateAMLTables() error {
+	_, alertTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "aml_alert" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"rule" TEXT NOT NULL,
+			"amount" INTEGER NOT NULL,
+			"details" TEXT NOT NULL,
+			"status" TEXT NOT NULL DEFAULT 'open',
+			"disposition" TEXT NOT NULL DEFAULT '',
+			"assignee_id" INTEGER NOT NULL DEFAULT 0,
+			"comment" TEXT NOT NULL DEFAULT '',
+			"created_at" TIMESTAMP,
+			"updated_at" TIMESTAMP
+		)`,
+	)
+	if alertTblErr != nil {
+		return alertTblErr
+	}
+	// A transfer is only ever covered by one alert for each rule, so the job can run as often
+	// as it likes without raising the same alert twice.
+	_, coveredTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "aml_alert_transfer" (
+			"alert_id" INTEGER NOT NULL,
+			"transfer_id" INTEGER NOT NULL,
+			"rule" TEXT NOT NULL,
+			UNIQUE ("rule", "transfer_id")
+		)`,
+	)
+	if coveredTblErr != nil {
+		return coveredTblErr
+	}
+	return nil
+}
+
+// MonitorTransfers raises alerts for cash deposits at or above aml.CashThreshold, and for
+// runs of cash deposits just under it within the last aml.StructuringDays days. Deposits
+// already covered by an alert for the same rule are left out.
+func (s *SQLiteStore) MonitorTransfers(now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	threshold := aml.CashThreshold()
+	const uncovered = `"action" = 'deposit' AND "channel" = ? AND NOT EXISTS (
+		SELECT 1 FROM "aml_alert_transfer" WHERE "aml_alert_transfer"."transfer_id" = "transfer"."id" AND "aml_alert_transfer"."rule" = ?)`
+	large, err := queryTransfers(tx, `SELECT * FROM "transfer" WHERE `+uncovered+` AND "amount" >= ? ORDER BY "id"`,
+		transfer.ChannelCash, aml.RuleLargeCash, threshold)
+	if err != nil {
+		return err
+	}
+	structured, err := queryTransfers(tx, `SELECT * FROM "transfer" WHERE `+uncovered+` AND "amount" < ? AND "completed_at" >= ? ORDER BY "id"`,
+		transfer.ChannelCash, aml.RuleStructuring, threshold, now.AddDate(0, 0, -aml.StructuringDays()))
+	if err != nil {
+		return err
+	}
+	alerts := append(aml.LargeCash(large, threshold, now), aml.Structuring(structured, threshold, now)...)
+	for _, alert := range alerts {
+		if err := insertAMLAlert(tx, alert); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func queryTransfers(db DBTX, query string, args ...interface{}) ([]*transfer.Transfer, error) {
+	transferArray := []*transfer.Transfer{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		trans, err := ScanIntoTransfer(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in transfer table", err)
+			return nil, err
+		}
+		transferArray = append(transferArray, trans)
+	}
+	return transferArray, nil
+}
+
+func insertAMLAlert(db DBTX, alert *aml.Alert) error {
+	result, err := db.Exec(`
+	INSERT INTO "aml_alert" (
+		"account_id",
+		"rule",
+		"amount",
+		"details",
+		"status",
+		"disposition",
+		"assignee_id",
+		"comment",
+		"created_at",
+		"updated_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, alert.AccountID, alert.Rule, alert.Amount, alert.Details, alert.Status, alert.Disposition,
+		alert.AssigneeID, alert.Comment, alert.CreatedAt, alert.UpdatedAt)
+	if err != nil {
+		fmt.Println("error adding to aml alert table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	alert.ID = int(id)
+	for _, transferID := range alert.TransferIDs {
+		if _, err := db.Exec(`INSERT INTO "aml_alert_transfer" ("alert_id", "transfer_id", "rule") values (?, ?, ?)`, alert.ID, transferID, alert.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAMLAlerts lists the alerts with status, or every alert if status is empty.
+func (s *SQLiteStore) GetAMLAlerts(status string) ([]*aml.Alert, error) {
+	if status == "" {
+		return queryAMLAlerts(s.db, `SELECT * FROM "aml_alert" ORDER BY "id"`)
+	}
+	return queryAMLAlerts(s.db, `SELECT * FROM "aml_alert" WHERE "status" = ? ORDER BY "id"`, status)
+}
+
+func (s *SQLiteStore) GetAMLAlert(id int) (*aml.Alert, error) {
+	return getAMLAlert(s.db, id)
+}
+
+func getAMLAlert(db DBTX, id int) (*aml.Alert, error) {
+	alert, err := ScanIntoAMLAlert(db.QueryRow(`SELECT * FROM "aml_alert" WHERE "id" = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("Alert Does Not Exist")
+	}
+	if alert.TransferIDs, err = alertTransferIDs(db, alert.ID); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func queryAMLAlerts(db DBTX, query string, args ...interface{}) ([]*aml.Alert, error) {
+	alertArray := []*aml.Alert{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		alert, err := ScanIntoAMLAlert(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in aml alert table", err)
+			return nil, err
+		}
+		alertArray = append(alertArray, alert)
+	}
+	// The rows are closed before looking up transfer ids, as a transaction only has the one connection.
+	row.Close()
+	for _, alert := range alertArray {
+		if alert.TransferIDs, err = alertTransferIDs(db, alert.ID); err != nil {
+			return nil, err
+		}
+	}
+	return alertArray, nil
+}
+
+func alertTransferIDs(db DBTX, alertID int) ([]int, error) {
+	ids := []int{}
+	row, err := db.Query(`SELECT "transfer_id" FROM "aml_alert_transfer" WHERE "alert_id" = ? ORDER BY "transfer_id"`, alertID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		var id int
+		if err := row.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// UpdateAMLAlert moves an alert on. Whoever starts investigating an alert is assigned to it.
+func (s *SQLiteStore) UpdateAMLAlert(id, actorID int, request *aml.UpdateAlertRequest) (*aml.Alert, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	alert, err := getAMLAlert(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := aml.ValidateUpdate(alert, request); err != nil {
+		return nil, err
+	}
+	before := *alert
+	if request.Status == aml.StatusInvestigating {
+		alert.AssigneeID = actorID
+	}
+	alert.Status, alert.Disposition, alert.UpdatedAt = request.Status, request.Disposition, time.Now().UTC()
+	if request.Comment != "" {
+		alert.Comment = request.Comment
+	}
+	_, err = tx.Exec(`UPDATE "aml_alert" SET "status" = ?, "disposition" = ?, "assignee_id" = ?, "comment" = ?, "updated_at" = ? WHERE "id" = ?`,
+		alert.Status, alert.Disposition, alert.AssigneeID, alert.Comment, alert.UpdatedAt, alert.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "aml-alert.update", audit.TargetAMLAlert, alert.ID, &before, alert); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// GetAMLReport gathers what a suspicious activity report for an alert is written from.
+func (s *SQLiteStore) GetAMLReport(id int) (*aml.Report, error) {
+	alert, err := getAMLAlert(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE id = ?`, alert.AccountID))
+	if err != nil {
+		return nil, fmt.Errorf("Account Does Not Exist")
+	}
+	transfers, err := queryTransfers(s.db, `SELECT "transfer".* FROM "transfer" JOIN "aml_alert_transfer" ON "aml_alert_transfer"."transfer_id" = "transfer"."id"
+		WHERE "aml_alert_transfer"."alert_id" = ? ORDER BY "transfer"."id"`, alert.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &aml.Report{Alert: alert, Subject: subject, Transfers: transfers, GeneratedAt: time.Now().UTC()}, nil
+}
+
+//
+// KYC
+//
+
+func (s *SQLiteStore) CreateKYCTables() error {
+	_, profileTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "kyc_profile" (
+			"account_id" INTEGER PRIMARY KEY,
+			"date_of_birth" TEXT NOT NULL DEFAULT '',
+			"address_line_1" TEXT NOT NULL DEFAULT '',
+			"address_line_2" TEXT NOT NULL DEFAULT '',
+			"city" TEXT NOT NULL DEFAULT '',
+			"postcode" TEXT NOT NULL DEFAULT '',
+			"country" TEXT NOT NULL DEFAULT '',
+			"national_id" TEXT NOT NULL DEFAULT '',
+			"status" TEXT NOT NULL DEFAULT 'unverified',
+			"reviewer_id" INTEGER NOT NULL DEFAULT 0,
+			"comment" TEXT NOT NULL DEFAULT '',
+			"updated_at" TIMESTAMP,
+			"submitted_at" TIMESTAMP,
+			"decided_at" TIMESTAMP
+		)`,
+	)
+	if profileTblErr != nil {
+		return profileTblErr
+	}
+	_, documentTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "kyc_document" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"type" TEXT NOT NULL,
+			"filename" TEXT NOT NULL,
+			"content_type" TEXT NOT NULL,
+			"size" INTEGER NOT NULL,
+			"sha256" TEXT NOT NULL,
+			"uploaded_at" TIMESTAMP
+		)`,
+	)
+	if documentTblErr != nil {
+		return documentTblErr
+	}
+	return nil
+}
+
+// kycProfile is the identity profile of account id, or an empty unverified one if the holder
+// has not given any details yet.
+func kycProfile(db DBTX, id int) (*kyc.Profile, error) {
+	profile, err := ScanIntoKYCProfile(db.QueryRow(`SELECT * FROM "kyc_profile" WHERE "account_id" = ?`, id))
+	if err == sql.ErrNoRows {
+		return kyc.EmptyProfile(id), nil
+	}
+	return profile, err
+}
+
+func (s *SQLiteStore) GetKYC(accountID int) (*kyc.Record, error) {
+	profile, err := kycProfile(s.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := kycDocuments(s.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &kyc.Record{Profile: profile, Documents: documents}, nil
+}
+
+func kycDocuments(db DBTX, accountID int) ([]*kyc.Document, error) {
+	documentArray := []*kyc.Document{}
+	row, err := db.Query(`SELECT * FROM "kyc_document" WHERE "account_id" = ? ORDER BY "id"`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		document, err := ScanIntoKYCDocument(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in kyc document table", err)
+			return nil, err
+		}
+		documentArray = append(documentArray, document)
+	}
+	return documentArray, nil
+}
+
+// SaveKYCProfile fills in or corrects the holder's identity details. A profile that has been
+// submitted cannot be changed unless it was rejected.
+func (s *SQLiteStore) SaveKYCProfile(accountID int, request *kyc.ProfileRequest) (*kyc.Profile, error) {
+	now := time.Now().UTC()
+	if err := kyc.ValidateProfile(request, now); err != nil {
+		return nil, err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	profile, err := kycProfile(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := profile.Editable(); err != nil {
+		return nil, err
+	}
+	before := *profile
+	profile.Apply(request, now)
+	if err := saveKYCProfile(tx, profile); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "kyc.update", audit.TargetKYC, accountID, &before, profile); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func saveKYCProfile(db DBTX, p *kyc.Profile) error {
+	_, err := db.Exec(`
+	INSERT OR REPLACE INTO "kyc_profile" (
+		"account_id",
+		"date_of_birth",
+		"address_line_1",
+		"address_line_2",
+		"city",
+		"postcode",
+		"country",
+		"national_id",
+		"status",
+		"reviewer_id",
+		"comment",
+		"updated_at",
+		"submitted_at",
+		"decided_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.AccountID, p.DateOfBirth, p.AddressLine1, p.AddressLine2, p.City, p.Postcode, p.Country, p.NationalID,
+		p.Status, p.ReviewerID, p.Comment, p.UpdatedAt, p.SubmittedAt, p.DecidedAt)
+	if err != nil {
+		fmt.Println("error adding to kyc profile table:", err)
+	}
+	return err
+}
+
+// AddKYCDocument puts an uploaded document in the blob store and records it against the
+// account. Documents can only be added while the profile can still be changed.
+func (s *SQLiteStore) AddKYCDocument(accountID int, docType, filename string, content io.Reader) (*kyc.Document, error) {
+	if err := kyc.ValidateDocumentType(docType); err != nil {
+		return nil, err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	profile, err := kycProfile(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := profile.Editable(); err != nil {
+		return nil, err
+	}
+	contentType, content := kyc.Sniff(content)
+	if err := kyc.ValidateContentType(contentType); err != nil {
+		return nil, err
+	}
+	blob, err := s.documents.Put(content, kyc.MaxDocumentBytes())
+	if err != nil {
+		return nil, err
+	}
+	document := &kyc.Document{
+		AccountID:   accountID,
+		Type:        docType,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        blob.Size,
+		SHA256:      blob.Key,
+		UploadedAt:  time.Now().UTC(),
+	}
+	result, err := tx.Exec(`
+	INSERT INTO "kyc_document" (
+		"account_id",
+		"type",
+		"filename",
+		"content_type",
+		"size",
+		"sha256",
+		"uploaded_at") values ( ?, ?, ?, ?, ?, ?, ?)
+	`, document.AccountID, document.Type, document.Filename, document.ContentType, document.Size, document.SHA256, document.UploadedAt)
+	if err != nil {
+		fmt.Println("error adding to kyc document table:", err)
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	document.ID = int(id)
+	if err := s.audit(tx, "kyc.add-document", audit.TargetKYC, accountID, nil, document); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// OpenKYCDocument opens one of the account's documents from the blob store. The caller closes it.
+func (s *SQLiteStore) OpenKYCDocument(accountID, documentID int) (*kyc.Document, io.ReadCloser, error) {
+	document, err := ScanIntoKYCDocument(s.db.QueryRow(`SELECT * FROM "kyc_document" WHERE "id" = ? AND "account_id" = ?`, documentID, accountID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Document Does Not Exist")
+	}
+	file, err := s.documents.Open(document.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, file, nil
+}
+
+// SubmitKYC sends a complete profile to be reviewed.
+func (s *SQLiteStore) SubmitKYC(accountID int) (*kyc.Profile, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	profile, err := kycProfile(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := kycDocuments(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := kyc.ValidateSubmission(profile, documents); err != nil {
+		return nil, err
+	}
+	before := *profile
+	profile.Status, profile.SubmittedAt = kyc.StatusPending, time.Now().UTC()
+	profile.ReviewerID, profile.Comment, profile.DecidedAt = 0, "", time.Time{}
+	if err := saveKYCProfile(tx, profile); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "kyc.submit", audit.TargetKYC, accountID, &before, profile); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// GetKYCProfiles lists the profiles with status, or every profile if status is empty.
+func (s *SQLiteStore) GetKYCProfiles(status string) ([]*kyc.Profile, error) {
+	query, args := `SELECT * FROM "kyc_profile" ORDER BY "submitted_at"`, []interface{}{}
+	if status != "" {
+		query, args = `SELECT * FROM "kyc_profile" WHERE "status" = ? ORDER BY "submitted_at"`, []interface{}{status}
+	}
+	profileArray := []*kyc.Profile{}
+	row, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		profile, err := ScanIntoKYCProfile(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in kyc profile table", err)
+			return nil, err
+		}
+		profileArray = append(profileArray, profile)
+	}
+	return profileArray, nil
+}
+
+// DecideKYC verifies or rejects a submitted profile and lets the account holder know.
+func (s *SQLiteStore) DecideKYC(accountID, reviewerID int, verify bool, comment string) (*kyc.Profile, error) {
+	if reviewerID == accountID {
+		return nil, fmt.Errorf("you cannot review your own profile")
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	profile, err := kycProfile(tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Status != kyc.StatusPending {
+		return nil, fmt.Errorf("profile is %s, not waiting for review", profile.Status)
+	}
+	before := *profile
+	profile.Status = kyc.StatusRejected
+	if verify {
+		profile.Status = kyc.StatusVerified
+	}
+	profile.ReviewerID, profile.Comment, profile.DecidedAt = reviewerID, comment, time.Now().UTC()
+	if err := saveKYCProfile(tx, profile); err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("Your identity is now %s", profile.Status)
+	if !verify {
+		message += " : " + comment
+	}
+	if err := insertNotification(tx, notification.CreateNotification(accountID, notification.KindKYCStatus, message)); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "kyc."+profile.Status, audit.TargetKYC, accountID, &before, profile); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// checkKYCLimits stops a payment of amount from acc, which has already used usage today,
+// that would take it over kyc.UnverifiedLimits while its holder is not verified. The bank's
+// own accounts are exempt.
+func checkKYCLimits(db DBTX, acc *account.Account, amount int, usage limit.Usage) error {
+	if acc.Type == account.TypeInternal {
+		return nil
+	}
+	profile, err := kycProfile(db, acc.ID)
+	if err != nil || profile.Status == kyc.StatusVerified {
+		return err
+	}
+	err = limit.Check(kyc.UnverifiedLimits(), usage, int64(amount))
+	var exceeded *limit.ExceededError
+	if errors.As(err, &exceeded) {
+		exceeded.Message = "identity not verified : " + exceeded.Message
+		exceeded.Limit = "unverified-" + exceeded.Limit
+	}
+	return err
+}
+
+//
+// Audit
+//
+
+func (s *SQLiteStore) CreateAuditTable() error {
+	_, auditTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "audit_log" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"actor_id" INTEGER NOT NULL,
+			"action" TEXT NOT NULL,
+			"target_type" TEXT NOT NULL,
+			"target_id" INTEGER NOT NULL,
+			"diff" TEXT NOT NULL,
+			"request_id" TEXT NOT NULL,
+			"ip" TEXT NOT NULL,
+			"created_at" TIMESTAMP,
+			"prev_hash" TEXT NOT NULL,
+			"hash" TEXT NOT NULL
+		)`,
+	)
+	if auditTblErr != nil {
+		return auditTblErr
+	}
+	// The log can only be added to. Anyone getting round this with direct access to the
+	// database still breaks the hash chain, which VerifyAuditLog picks up.
+	for _, event := range []string{"UPDATE", "DELETE"} {
+		_, err := s.db.Exec(fmt.Sprintf(`
+			CREATE TRIGGER IF NOT EXISTS "audit_log_no_%s" BEFORE %s ON "audit_log"
+			BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END`, strings.ToLower(event), event))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// system is the store acting on its own account, for changes that happen because time ran
+// out rather than because the actor it is acting for asked for them.
+func (s *SQLiteStore) system() *SQLiteStore {
+	store := *s
+	store.meta = nil
+	return &store
+}
+
+// As is the store acting on behalf of the actor and request in meta, who every change it
+// makes is recorded against in the audit log.
+func (s *SQLiteStore) As(meta audit.Meta) Storage {
+	store := *s
+	store.meta = &meta
+	return &store
+}
+
+// audit records an action in the audit log. It must be given the transaction making the
+// change, so the entry is only kept if the change is. before and after are what was changed,
+// either of which can be nil.
+func (s *SQLiteStore) audit(db DBTX, action, targetType string, targetID int, before, after interface{}) error {
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	var prevHash string
+	err = db.QueryRow(`SELECT "hash" FROM "audit_log" ORDER BY "id" DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	meta := audit.Meta{}
+	if s.meta != nil {
+		meta = *s.meta
+	}
+	entry := audit.CreateEntry(meta, action, targetType, targetID, diff, prevHash, time.Now().UTC())
+	_, err = db.Exec(`
+	INSERT INTO "audit_log" (
+		"actor_id",
+		"action",
+		"target_type",
+		"target_id",
+		"diff",
+		"request_id",
+		"ip",
+		"created_at",
+		"prev_hash",
+		"hash") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, string(entry.Diff), entry.RequestID,
+		entry.IP, entry.CreatedAt, entry.PrevHash, entry.Hash)
+	if err != nil {
+		fmt.Println("error adding to audit log:", err)
+	}
+	return err
+}
+
+// GetAuditLog lists the entries matching filter, newest first.
+func (s *SQLiteStore) GetAuditLog(filter *audit.Filter) ([]*audit.Entry, error) {
+	query, args := `SELECT * FROM "audit_log" WHERE 1 = 1`, []interface{}{}
+	if filter.ActorID != 0 {
+		query += ` AND "actor_id" = ?`
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		query += ` AND "action" = ?`
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		query += ` AND "target_type" = ?`
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query += ` AND "target_id" = ?`
+		args = append(args, filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND "created_at" >= ?`
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += ` AND "created_at" < ?`
+		args = append(args, filter.To)
+	}
+	query += ` ORDER BY "id" DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	return queryAuditLog(s.db, query, args...)
+}
+
+// VerifyAuditLog checks the whole log still forms an unbroken hash chain, and returns how
+// many entries it checked.
+func (s *SQLiteStore) VerifyAuditLog() (int, error) {
+	entries, err := queryAuditLog(s.db, `SELECT * FROM "audit_log" ORDER BY "id"`)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), audit.Verify(entries)
+}
+
+func queryAuditLog(db DBTX, query string, args ...interface{}) ([]*audit.Entry, error) {
+	entryArray := []*audit.Entry{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		entry, err := ScanIntoAuditEntry(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in audit log", err)
+			return nil, err
+		}
+		entryArray = append(entryArray, entry)
+	}
+	return entryArray, nil
+}
+
+//
+// Event
+//
+
+func (s *SQLiteStore) CreateEventTable() error {
+	_, eventTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "event" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"version" INTEGER NOT NULL,
+			"type" TEXT NOT NULL,
+			"data" TEXT NOT NULL,
+			"occurred_at" TIMESTAMP,
+			UNIQUE ("account_id", "version")
+		)`,
+	)
+	if eventTblErr != nil {
+		return eventTblErr
+	}
+	return nil
+}
+
+// seedEvents gives a database from before events were kept a starting point. The first
+// time the server starts with an empty event store, every transfer is imported as it stands
+// and every account is opened with its current details and balance, so replaying the store
+// gives back the tables as they were.
+func (s *SQLiteStore) seedEvents() error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM "event"`).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	transfers, err := queryTransfers(tx, `SELECT * FROM "transfer" ORDER BY "id"`)
+	if err != nil {
+		return err
+	}
+	for _, trans := range transfers {
+		imported, err := event.Imported(trans, now)
+		if err != nil {
+			return err
+		}
+		if err := appendEvent(tx, imported); err != nil {
+			return err
+		}
+	}
+	accounts, err := queryAccounts(tx, `SELECT * FROM "account" ORDER BY "id"`)
+	if err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		opened, err := event.Opened(acc, now)
+		if err != nil {
+			return err
+		}
+		if err := appendEvent(tx, opened); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// appendEvent adds e to the end of its account's stream. It must be given the transaction
+// making the change the event records, which publishes it once committed.
+func appendEvent(db DBTX, e *event.Event) error {
+	if err := db.QueryRow(`SELECT COALESCE(MAX("version"), 0) + 1 FROM "event" WHERE "account_id" = ?`, e.AccountID).Scan(&e.Version); err != nil {
+		return err
+	}
+	result, err := db.Exec(`
+	INSERT INTO "event" (
+		"account_id",
+		"version",
+		"type",
+		"data",
+		"occurred_at") values ( ?, ?, ?, ?, ?)
+	`, e.AccountID, e.Version, e.Type, string(e.Data), e.OccurredAt)
+	if err != nil {
+		fmt.Println("error adding to event table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = int(id)
+	if tx, ok := db.(*storeTx); ok {
+		tx.events = append(tx.events, e)
+	}
+	if !webhook.Publishes(e.Type) {
+		return nil
+	}
+	return insertOutboxMessage(db, webhook.CreateMessage(e))
+}
+
+// GetEvents lists up to limit events after the event with id after, oldest first, for
+// building read models from.
+func (s *SQLiteStore) GetEvents(after, limit int) ([]*event.Event, error) {
+	return queryEvents(s.db, `SELECT * FROM "event" WHERE "id" > ? ORDER BY "id" LIMIT ?`, after, limit)
+}
+
+// GetAccountAt rebuilds account id as it stood at at from its events.
+func (s *SQLiteStore) GetAccountAt(id int, at time.Time) (*account.Account, error) {
+	events, err := queryEvents(s.db, `SELECT * FROM "event" WHERE "account_id" = ? AND "occurred_at" <= ? ORDER BY "id"`, id, at)
+	if err != nil {
+		return nil, err
+	}
+	projection := event.NewProjection()
+	for _, e := range events {
+		if err := projection.Apply(e); err != nil {
+			return nil, err
+		}
+	}
+	acc, ok := projection.Accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("account has no history as far back as %v", at.Format(time.RFC3339))
+	}
+	return acc, nil
+}
+
+// RebuildProjections replaces the account and transfer tables with what replaying every
+// event gives, and reports how many rows of each were written. Passwords, which events
+// don't carry, are kept as they are.
+func (s *SQLiteStore) RebuildProjections() (int, int, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	events, err := queryEvents(tx, `SELECT * FROM "event" ORDER BY "id"`)
+	if err != nil {
+		return 0, 0, err
+	}
+	projection := event.NewProjection()
+	for _, e := range events {
+		if err := projection.Apply(e); err != nil {
+			return 0, 0, err
+		}
+	}
+	accounts, transfers := projection.AccountList(), projection.TransferList()
+	// passwords are not in events, so each account keeps the one it has now
+	passwords := map[int]string{}
+	row, err := tx.Query(`SELECT "id", "password" FROM "account"`)
+	if err != nil {
+		return 0, 0, err
+	}
+	for row.Next() {
+		var id int
+		var password string
+		if err := row.Scan(&id, &password); err != nil {
+			row.Close()
+			return 0, 0, err
+		}
+		passwords[id] = password
+	}
+	row.Close()
+	for _, acc := range accounts {
+		acc.Password = passwords[acc.ID]
+	}
+	if _, err := tx.Exec(`DELETE FROM "transfer"`); err != nil {
+		return 0, 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM "account"`); err != nil {
+		return 0, 0, err
+	}
+	for _, acc := range accounts {
+		_, err := tx.Exec(`
+		INSERT INTO "account" (
+			"id",
+			"first_name",
+			"last_name",
+			"username",
+			"password",
+			"bank_number",
+			"balance",
+			"created_at",
+			"account_type",
+			"role",
+			"overdraft_limit",
+			"status",
+			"status_changed_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, acc.ID, acc.FirstName, acc.LastName, acc.Username, acc.Password, acc.BankNumber, acc.Balance,
+			acc.CreatedAt, acc.Type, acc.Role, acc.OverdraftLimit, acc.Status, sql.NullTime{Time: acc.StatusChanged, Valid: !acc.StatusChanged.IsZero()})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, trans := range transfers {
+		_, err := tx.Exec(`
+		INSERT INTO "transfer" (
+			"id",
+			"from",
+			"to",
+			"amount",
+			"action",
+			"previous_balance",
+			"current_balance",
+			"completed_at",
+			"channel",
+			"reference",
+			"from_bank_number",
+			"to_bank_number",
+			"memo",
+			"category",
+			"reversal_of",
+			"reversed_by",
+			"counterpart_id") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, trans.ID, trans.From, trans.To, trans.Amount, trans.Action, trans.PreviousBalance, trans.CurrentBalance,
+			trans.CompletedAt, trans.Channel, trans.Reference, trans.FromBankNumber, trans.ToBankNumber,
+			trans.Memo, trans.Category, trans.ReversalOf, trans.ReversedBy, trans.CounterpartID)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	// transfers imported from before their sides were linked are linked again as they were
+	if err := linkTransferCounterparts(tx); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return len(accounts), len(transfers), nil
+}
+
+func queryEvents(db DBTX, query string, args ...interface{}) ([]*event.Event, error) {
+	eventArray := []*event.Event{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		e, err := ScanIntoEvent(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in event table", err)
+			return nil, err
+		}
+		eventArray = append(eventArray, e)
+	}
+	return eventArray, nil
+}
+
+//
+// Webhook
+//
+
+func (s *SQLiteStore) CreateWebhookTables() error {
+	_, subscriptionTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "webhook_subscription" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"client_id" INTEGER NOT NULL,
+			"url" TEXT NOT NULL,
+			"event_types" TEXT NOT NULL,
+			"secret" TEXT NOT NULL,
+			"active" BOOLEAN NOT NULL,
+			"created_at" TIMESTAMP
+		)`,
+	)
+	if subscriptionTblErr != nil {
+		return subscriptionTblErr
+	}
+	_, outboxTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "outbox" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"event_id" INTEGER NOT NULL,
+			"type" TEXT NOT NULL,
+			"account_id" INTEGER NOT NULL,
+			"occurred_at" TIMESTAMP,
+			"data" TEXT NOT NULL,
+			"dispatched" BOOLEAN NOT NULL DEFAULT 0
+		)`,
+	)
+	if outboxTblErr != nil {
+		return outboxTblErr
+	}
+	_, deliveryTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "webhook_delivery" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"message_id" INTEGER NOT NULL,
+			"subscription_id" INTEGER NOT NULL,
+			"status" TEXT NOT NULL,
+			"attempts" INTEGER NOT NULL,
+			"next_attempt_at" TIMESTAMP,
+			"last_status_code" INTEGER NOT NULL,
+			"last_error" TEXT NOT NULL,
+			"delivered_at" TIMESTAMP,
+			"created_at" TIMESTAMP,
+			UNIQUE ("message_id", "subscription_id")
+		)`,
+	)
+	if deliveryTblErr != nil {
+		return deliveryTblErr
+	}
+	return nil
+}
+
+func insertOutboxMessage(db DBTX, m *webhook.Message) error {
+	result, err := db.Exec(`
+	INSERT INTO "outbox" (
+		"event_id",
+		"type",
+		"account_id",
+		"occurred_at",
+		"data") values ( ?, ?, ?, ?, ?)
+	`, m.EventID, m.Type, m.AccountID, m.OccurredAt, string(m.Data))
+	if err != nil {
+		fmt.Println("error adding to outbox table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	m.ID = int(id)
+	return nil
+}
+
+func (s *SQLiteStore) CreateWebhookSubscription(sub *webhook.Subscription) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`
+	INSERT INTO "webhook_subscription" (
+		"client_id",
+		"url",
+		"event_types",
+		"secret",
+		"active",
+		"created_at") values ( ?, ?, ?, ?, ?, ?)
+	`, sub.ClientID, sub.URL, strings.Join(sub.EventTypes, ","), sub.Secret, sub.Active, sub.CreatedAt)
+	if err != nil {
+		fmt.Println("error adding to webhook subscription table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	sub.ID = int(id)
+	if err := s.audit(tx, "webhook.subscribe", audit.TargetWebhook, sub.ID, nil, sub); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetWebhookSubscriptions lists the client's subscriptions, without their secrets.
+func (s *SQLiteStore) GetWebhookSubscriptions(clientID int) ([]*webhook.Subscription, error) {
+	subs, err := queryWebhookSubscriptions(s.db, `SELECT * FROM "webhook_subscription" WHERE "client_id" = ? ORDER BY "id"`, clientID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs, nil
+}
+
+// GetWebhookSubscription finds one of the client's subscriptions, without its secret.
+func (s *SQLiteStore) GetWebhookSubscription(clientID, id int) (*webhook.Subscription, error) {
+	sub, err := getWebhookSubscription(s.db, clientID, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+func getWebhookSubscription(db DBTX, clientID, id int) (*webhook.Subscription, error) {
+	sub, err := ScanIntoWebhookSubscription(db.QueryRow(`SELECT * FROM "webhook_subscription" WHERE "id" = ? AND "client_id" = ?`, id, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("Subscription Does Not Exist")
+	}
+	return sub, nil
+}
+
+// DisableWebhookSubscription stops anything more being sent to a subscription. Deliveries
+// still pending for it are left to finish.
+func (s *SQLiteStore) DisableWebhookSubscription(clientID, id int) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	sub, err := getWebhookSubscription(tx, clientID, id)
+	if err != nil {
+		return err
+	}
+	if !sub.Active {
+		return fmt.Errorf("subscription is already disabled")
+	}
+	if _, err := tx.Exec(`UPDATE "webhook_subscription" SET "active" = 0 WHERE "id" = ?`, id); err != nil {
+		return err
+	}
+	after := *sub
+	after.Active = false
+	if err := s.audit(tx, "webhook.unsubscribe", audit.TargetWebhook, id, sub, &after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetWebhookDeliveries lists the deliveries to one of the client's subscriptions with status,
+// or every delivery if status is empty, newest first.
+func (s *SQLiteStore) GetWebhookDeliveries(clientID, subscriptionID int, status string) ([]*webhook.Delivery, error) {
+	if _, err := getWebhookSubscription(s.db, clientID, subscriptionID); err != nil {
+		return nil, err
+	}
+	query, args := `SELECT * FROM "webhook_delivery" WHERE "subscription_id" = ?`, []interface{}{subscriptionID}
+	if status != "" {
+		query += ` AND "status" = ?`
+		args = append(args, status)
+	}
+	return queryWebhookDeliveries(s.db, query+` ORDER BY "id" DESC`, args...)
+}
+
+// RedeliverWebhook queues one of the client's deliveries to be sent again straight away,
+// whether it was delivered or is dead.
+func (s *SQLiteStore) RedeliverWebhook(clientID, deliveryID int) (*webhook.Delivery, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	delivery, err := ScanIntoWebhookDelivery(tx.QueryRow(`
+		SELECT "webhook_delivery".* FROM "webhook_delivery" JOIN "webhook_subscription" ON "webhook_subscription"."id" = "webhook_delivery"."subscription_id"
+		WHERE "webhook_delivery"."id" = ? AND "webhook_subscription"."client_id" = ?`, deliveryID, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("Delivery Does Not Exist")
+	}
+	if delivery.Status == webhook.StatusPending {
+		return nil, fmt.Errorf("delivery is already waiting to be sent")
+	}
+	before := *delivery
+	delivery.Redeliver(time.Now().UTC())
+	if err := updateWebhookDelivery(tx, delivery); err != nil {
+		return nil, err
+	}
+	if err := s.audit(tx, "webhook.redeliver", audit.TargetWebhook, delivery.SubscriptionID, &before, delivery); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// DispatchOutbox turns every message still in the outbox into a delivery for each active
+// subscription that wants it.
+func (s *SQLiteStore) DispatchOutbox(now time.Time) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	messages, err := queryOutbox(tx, `SELECT * FROM "outbox" WHERE "dispatched" = 0 ORDER BY "id"`)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+	subs, err := queryWebhookSubscriptions(tx, `SELECT * FROM "webhook_subscription" WHERE "active" = 1`)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		for _, sub := range subs {
+			if !sub.Wants(m.Type) {
+				continue
+			}
+			if err := insertWebhookDelivery(tx, webhook.CreateDelivery(m.ID, sub.ID, now)); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE "outbox" SET "dispatched" = 1 WHERE "id" = ?`, m.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertWebhookDelivery(db DBTX, d *webhook.Delivery) error {
+	result, err := db.Exec(`
+	INSERT OR IGNORE INTO "webhook_delivery" (
+		"message_id",
+		"subscription_id",
+		"status",
+		"attempts",
+		"next_attempt_at",
+		"last_status_code",
+		"last_error",
+		"delivered_at",
+		"created_at") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.MessageID, d.SubscriptionID, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt, d.CreatedAt)
+	if err != nil {
+		fmt.Println("error adding to webhook delivery table:", err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = int(id)
+	return nil
+}
+
+// GetDueWebhookAttempts lists up to limit pending deliveries whose next attempt is due at now,
+// oldest first, with what is needed to send them.
+func (s *SQLiteStore) GetDueWebhookAttempts(now time.Time, limit int) ([]*webhook.Attempt, error) {
+	deliveries, err := queryWebhookDeliveries(s.db, `SELECT * FROM "webhook_delivery" WHERE "status" = ? AND "next_attempt_at" <= ? ORDER BY "next_attempt_at", "id" LIMIT ?`,
+		webhook.StatusPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	attempts := []*webhook.Attempt{}
+	for _, d := range deliveries {
+		sub, err := ScanIntoWebhookSubscription(s.db.QueryRow(`SELECT * FROM "webhook_subscription" WHERE "id" = ?`, d.SubscriptionID))
+		if err != nil {
+			return nil, err
+		}
+		m, err := ScanIntoOutboxMessage(s.db.QueryRow(`SELECT * FROM "outbox" WHERE "id" = ?`, d.MessageID))
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, &webhook.Attempt{Delivery: d, Subscription: sub, Message: m})
 	}
+	return attempts, nil
+}
+
+// RecordWebhookAttempt saves the outcome of an attempt at a delivery.
+func (s *SQLiteStore) RecordWebhookAttempt(delivery *webhook.Delivery) error {
+	return updateWebhookDelivery(s.db, delivery)
+}
+
+func updateWebhookDelivery(db DBTX, d *webhook.Delivery) error {
+	_, err := db.Exec(`UPDATE "webhook_delivery" SET "status" = ?, "attempts" = ?, "next_attempt_at" = ?, "last_status_code" = ?, "last_error" = ?, "delivered_at" = ? WHERE "id" = ?`,
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt, d.ID)
+	return err
+}
 
+func queryWebhookSubscriptions(db DBTX, query string, args ...interface{}) ([]*webhook.Subscription, error) {
+	subscriptionArray := []*webhook.Subscription{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer row.Close()
 	for row.Next() {
-		transfer, err := ScanIntoTransfer(row)
+		sub, err := ScanIntoWebhookSubscription(row)
 		if err != nil {
-			fmt.Println("error with scanning rows in transfer table", err)
+			fmt.Println("error with scanning rows in webhook subscription table", err)
 			return nil, err
 		}
-		transferArray = append(transferArray, transfer)
+		subscriptionArray = append(subscriptionArray, sub)
 	}
-	return transferArray, nil
+	return subscriptionArray, nil
 }
 
-func (s *SQLiteStore) GetMyTransfers(id int) ([]*transfer.Transfer, error) {
-	transferArray := []*transfer.Transfer{}
-	row, err := s.db.Query(`SELECT * FROM "transfer" WHERE ("from" = ? AND "action" = 'withdrawal') OR ("to" = ? AND "action" = 'deposit') OR ("from" = ? AND "to"= ?)`, id, id, id, id)
+func queryWebhookDeliveries(db DBTX, query string, args ...interface{}) ([]*webhook.Delivery, error) {
+	deliveryArray := []*webhook.Delivery{}
+	row, err := db.Query(query, args...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
 	defer row.Close()
 	for row.Next() {
-		transfer, err := ScanIntoTransfer(row)
+		d, err := ScanIntoWebhookDelivery(row)
 		if err != nil {
-			fmt.Println("error with scanning rows in transfer table", err)
+			fmt.Println("error with scanning rows in webhook delivery table", err)
 			return nil, err
 		}
-		transferArray = append(transferArray, transfer)
+		deliveryArray = append(deliveryArray, d)
 	}
-	return transferArray, nil
+	return deliveryArray, nil
 }
 
-func (s *SQLiteStore) GetAccountBalance(id int) (*transfer.MyBalance, error) {
-	account, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+func queryOutbox(db DBTX, query string, args ...interface{}) ([]*webhook.Message, error) {
+	messageArray := []*webhook.Message{}
+	row, err := db.Query(query, args...)
 	if err != nil {
-		fmt.Println("error retrieving account by ID from accounts table")
 		return nil, err
 	}
-	myAccount := &transfer.MyBalance{
-		Username:        account.Username,
-		MyAccountNumber: account.BankNumber,
-		Balance:         account.Balance,
+	defer row.Close()
+	for row.Next() {
+		m, err := ScanIntoOutboxMessage(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in outbox table", err)
+			return nil, err
+		}
+		messageArray = append(messageArray, m)
 	}
-	return myAccount, nil
+	return messageArray, nil
 }
 
-func (s *SQLiteStore) DepositWithdrawIntoMyAccount(id int, deposit *transfer.TransferRequest) (*transfer.MyBalance, error) {
-	account, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+//
+// Stream
+//
+
+// publisher hands committed events to the stream bus. Commits go through it one at a time,
+// so events reach the bus in the order they were committed, which with SQLite allowing one
+// write at a time is id order.
+type publisher struct {
+	mu   sync.Mutex
+	db   *sql.DB
+	bus  *stream.Bus
+	last int
+}
+
+// startPublishing skips the events already in the store, which nothing is listening for yet.
+func (s *SQLiteStore) startPublishing() error {
+	p := s.publisher
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.db.QueryRow(`SELECT COALESCE(MAX("id"), 0) FROM "event"`).Scan(&p.last)
+}
+
+func (p *publisher) commit(tx *storeTx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	if len(tx.events) == 0 {
+		return nil
+	}
+	// a gap before this transaction's events is events committed by another process
+	if tx.events[0].ID > p.last+1 {
+		if err := p.catchUp(tx.events[0].ID); err != nil {
+			log.Printf("could not publish events before %d : %v", tx.events[0].ID, err)
+		}
+	}
+	for _, e := range tx.events {
+		p.publish(e)
+	}
+	return nil
+}
+
+func (p *publisher) publish(e *event.Event) {
+	if webhook.Publishes(e.Type) {
+		p.bus.Publish(e)
+	}
+	p.last = e.ID
+}
+
+// catchUp publishes the events committed since the last one published with an id below
+// before, or all of them when before is 0. It must be called holding the lock.
+func (p *publisher) catchUp(before int) error {
+	for {
+		events, err := queryEvents(p.db, `SELECT * FROM "event" WHERE "id" > ? ORDER BY "id" LIMIT ?`, p.last, event.MaxPageSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if before != 0 && e.ID >= before {
+				return nil
+			}
+			p.publish(e)
+		}
+		if len(events) < event.MaxPageSize {
+			return nil
+		}
+	}
+}
+
+// GetStreamEvents lists up to limit of the events streamed for the given accounts after the
+// event with id after, oldest first, for a client catching up on what it missed. Only the
+// events published to webhooks are streamed.
+func (s *SQLiteStore) GetStreamEvents(accountIDs []int, after, limit int) ([]*event.Event, error) {
+	if len(accountIDs) == 0 {
+		return []*event.Event{}, nil
+	}
+	args := []interface{}{after}
+	for _, id := range accountIDs {
+		args = append(args, id)
+	}
+	for _, eventType := range webhook.EventTypes {
+		args = append(args, eventType)
+	}
+	args = append(args, limit)
+	return queryEvents(s.db, fmt.Sprintf(`SELECT * FROM "event" WHERE "id" > ? AND "account_id" IN (%s) AND "type" IN (%s) ORDER BY "id" LIMIT ?`,
+		placeholders(len(accountIDs)), placeholders(len(webhook.EventTypes))), args...)
+}
+
+// SubscribeEvents starts listening for events on the given accounts as they are committed.
+func (s *SQLiteStore) SubscribeEvents(accountIDs []int) *stream.Subscription {
+	return s.publisher.bus.Subscribe(accountIDs)
+}
+
+// RelayEvents publishes events committed by other processes, such as the command line jobs,
+// which this one only finds out about by checking the store every interval. Events committed
+// here are published as they commit. It never returns.
+func (s *SQLiteStore) RelayEvents(interval time.Duration) {
+	p := s.publisher
+	for range time.Tick(interval) {
+		p.mu.Lock()
+		if err := p.catchUp(0); err != nil {
+			log.Printf("could not relay events : %v", err)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// placeholders is n comma separated query parameters, for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+//
+// Statement
+//
+
+func (s *SQLiteStore) CreateStatementTable() error {
+	_, statementTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "statement" (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"account_id" INTEGER NOT NULL,
+			"period_from" TIMESTAMP NOT NULL,
+			"period_to" TIMESTAMP NOT NULL,
+			"opening_balance" INTEGER NOT NULL,
+			"closing_balance" INTEGER NOT NULL,
+			"total_in" INTEGER NOT NULL,
+			"total_out" INTEGER NOT NULL,
+			"transactions" INTEGER NOT NULL,
+			"generated_at" TIMESTAMP,
+			"csv" BLOB NOT NULL,
+			"pdf" BLOB NOT NULL,
+			UNIQUE ("account_id", "period_from", "period_to")
+		)`,
+	)
+	return statementTblErr
+}
+
+// GetStatement puts together account id's statement for the days from to to inclusive.
+func (s *SQLiteStore) GetStatement(id int, from, to time.Time) (*statement.Statement, error) {
+	acc, err := s.GetAccountByID(id)
 	if err != nil {
-		fmt.Println("error retrieving account by ID from accounts table")
 		return nil, err
 	}
-	account.Balance += int64(deposit.Amount)
-	if account.Balance < 0 {
-		return nil, fmt.Errorf("insufficent funds")
-	}
-	if err := updateBalance(s, account.Balance, id); err != nil {
-		fmt.Printf("Could Not Withdraw from %v Account %v", id, err)
+	return buildStatement(s.db, acc, from, to, time.Now().UTC())
+}
+
+// buildStatement reads acc's transfers over the period and its balance at the start of it,
+// which is the balance after its last transfer before the period, or nothing if there was none.
+func buildStatement(db DBTX, acc *account.Account, from, to, now time.Time) (*statement.Statement, error) {
+	opening, err := balanceAt(db, acc.ID, from)
+	if err != nil {
 		return nil, err
 	}
-	myAccount := &transfer.MyBalance{
-		Username:        account.Username,
-		MyAccountNumber: account.BankNumber,
-		Balance:         account.Balance,
+	transfers, err := queryTransfers(db, `SELECT * FROM "transfer" WHERE `+myTransfersWhere+` AND "completed_at" >= ? AND "completed_at" < ? ORDER BY "completed_at", "id"`,
+		acc.ID, acc.ID, acc.ID, acc.ID, from, statement.End(to))
+	if err != nil {
+		return nil, err
 	}
-	return myAccount, nil
+	return statement.Build(acc, from, to, opening, transfers, now), nil
 }
 
-func (s *SQLiteStore) Transfer(id int, request *transfer.TransferRequest) (*transfer.TransferResponse, error) {
-	if request.Amount < 0 {
-		return nil, fmt.Errorf("invalid amount - > cannot complete transaction")
+// GetExport gathers account id's transfers over the days from to to inclusive, read with
+// GetMyTransfers so exports match the history clients see, along with its opening balance.
+func (s *SQLiteStore) GetExport(id int, from, to time.Time) (*export.Ledger, error) {
+	acc, err := s.GetAccountByID(id)
+	if err != nil {
+		return nil, err
 	}
-	account, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	opening, err := balanceAt(s.db, id, from)
 	if err != nil {
-		fmt.Println("error retrieving account by ID from accounts table")
 		return nil, err
 	}
-	toAccount, err := ScanIntoAccount(s.db.QueryRow(`SELECT * FROM "account" WHERE id = ?`, request.ToAccount))
+	transfers, err := s.GetMyTransfers(id, &transfer.HistoryFilter{From: from, To: statement.End(to)})
 	if err != nil {
-		fmt.Println("error retrieving account by ID from accounts table")
 		return nil, err
 	}
-	account.Balance -= int64(request.Amount)
-	toAccount.Balance += int64(request.Amount)
-	if account.Balance < 0 {
-		return nil, fmt.Errorf("insufficent funds - > cannot complete transaction")
+	return export.NewLedger(acc, from, to, opening, transfers, time.Now().UTC()), nil
+}
+
+// GenerateMonthlyStatements stores last month's statement for every customer account that
+// does not have one yet. Accounts opened after the month or closed before it are left out.
+func (s *SQLiteStore) GenerateMonthlyStatements(now time.Time) error {
+	from, to := statement.LastMonth(now)
+	accounts, err := queryAccounts(s.db, `
+		SELECT * FROM "account" WHERE "account_type" != ? AND "created_at" < ?
+		AND NOT ("status" = ? AND "status_changed_at" < ?)
+		AND NOT EXISTS (
+			SELECT 1 FROM "statement" WHERE "statement"."account_id" = "account"."id" AND "period_from" = ? AND "period_to" = ?
+		)`, account.TypeInternal, statement.End(to), account.StatusClosed, from, from, to)
+	if err != nil {
+		return err
 	}
-	if err := updateBalance(s, account.Balance, id); err != nil {
-		fmt.Printf("Could Not Withdraw from %v Account %v", id, err)
-		return nil, err
+	for _, acc := range accounts {
+		st, err := buildStatement(s.db, acc, from, to, now)
+		if err != nil {
+			return err
+		}
+		stored, err := statement.Store(st)
+		if err != nil {
+			return err
+		}
+		if err := insertStatement(s.db, stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertStatement(db DBTX, st *statement.Stored) error {
+	result, err := db.Exec(`
+	INSERT OR IGNORE INTO "statement" (
+		"account_id",
+		"period_from",
+		"period_to",
+		"opening_balance",
+		"closing_balance",
+		"total_in",
+		"total_out",
+		"transactions",
+		"generated_at",
+		"csv",
+		"pdf") values ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, st.AccountID, st.From, st.To, st.OpeningBalance, st.ClosingBalance, st.TotalIn, st.TotalOut, st.Transactions, st.GeneratedAt, st.CSV, st.PDF)
+	if err != nil {
+		fmt.Println("error adding to statement table:", err)
+		return err
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	st.ID = int(id)
+	return nil
+}
+
+// statementSummaryColumns are a stored statement's columns other than its files, which are
+// only read when a single statement is fetched.
+const statementSummaryColumns = `"id", "account_id", "period_from", "period_to", "opening_balance", "closing_balance", "total_in", "total_out", "transactions", "generated_at"`
+
+// GetStoredStatements lists the statements kept for an account, newest first, without their files.
+func (s *SQLiteStore) GetStoredStatements(accountID int) ([]*statement.Stored, error) {
+	return queryStatements(s.db, `SELECT `+statementSummaryColumns+` FROM "statement" WHERE "account_id" = ? ORDER BY "period_from" DESC`, accountID)
+}
 
-	if err := updateBalance(s, toAccount.Balance, request.ToAccount); err != nil {
-		fmt.Printf("Could Not Deposit into %v Account %v", request.ToAccount, err)
-		account.Balance += int64(request.Amount)
-		if err := updateBalance(s, account.Balance, id); err != nil {
-			fmt.Printf("Could Not Return Amount Back to %v Account %v", id, err)
+func (s *SQLiteStore) GetStoredStatement(accountID, id int) (*statement.Stored, error) {
+	st, err := ScanIntoStoredStatement(s.db.QueryRow(`SELECT * FROM "statement" WHERE "id" = ? AND "account_id" = ?`, id, accountID))
+	if err != nil {
+		return nil, fmt.Errorf("Statement Does Not Exist")
+	}
+	return st, nil
+}
+
+func queryStatements(db DBTX, query string, args ...interface{}) ([]*statement.Stored, error) {
+	statementArray := []*statement.Stored{}
+	row, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+	for row.Next() {
+		st, err := ScanIntoStatementSummary(row)
+		if err != nil {
+			fmt.Println("error with scanning rows in statement table", err)
 			return nil, err
 		}
+		statementArray = append(statementArray, st)
+	}
+	return statementArray, nil
+}
+
+//
+// Limit
+//
+
+func (s *SQLiteStore) CreateLimitTable() error {
+	_, limitTblErr := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS "account_limit" (
+			"account_id" INTEGER PRIMARY KEY,
+			"per_transaction" INTEGER NOT NULL,
+			"daily" INTEGER NOT NULL,
+			"daily_count" INTEGER NOT NULL
+		)`,
+	)
+	if limitTblErr != nil {
+		return limitTblErr
+	}
+	return nil
+}
+
+// limitsFor is the account's own limits if it has any, otherwise those of its role.
+func (s *SQLiteStore) limitsFor(db DBTX, acc *account.Account) (limit.Limits, error) {
+	limits := limit.Limits{}
+	err := db.QueryRow(`SELECT "per_transaction", "daily", "daily_count" FROM "account_limit" WHERE "account_id" = ?`, acc.ID).Scan(
+		&limits.PerTransaction,
+		&limits.Daily,
+		&limits.DailyCount)
+	if err == sql.ErrNoRows {
+		return s.roleLimits[acc.Role], nil
+	}
+	return limits, err
+}
+
+// usageSince adds up the money that has left account id since since, plus every hold still
+// active on it, so card authorisations can't add up to more than the limit before capture.
+func usageSince(db DBTX, id int, since time.Time) (limit.Usage, error) {
+	usage := limit.Usage{}
+	err := db.QueryRow(`SELECT COALESCE(SUM("amount"), 0), COUNT(*) FROM "transfer" WHERE "from" = ? AND "action" = 'withdrawal' AND "completed_at" >= ?`, id, since).Scan(
+		&usage.Amount,
+		&usage.Count)
+	if err != nil {
+		return usage, err
+	}
+	held := limit.Usage{}
+	err = db.QueryRow(`SELECT COALESCE(SUM("amount"), 0), COUNT(*) FROM "hold" WHERE "account_id" = ? AND "status" = ?`, id, hold.StatusActive).Scan(
+		&held.Amount,
+		&held.Count)
+	usage.Amount += held.Amount
+	usage.Count += held.Count
+	return usage, err
+}
+
+// checkLimits stops a payment of amount that would take acc over its limits, or over the
+// limits for unverified customers. It runs inside the same transaction as the payment so
+// two payments can't both fit into the same allowance. Every payment out of an account
+// goes through it.
+func (s *SQLiteStore) checkLimits(db DBTX, acc *account.Account, amount int, now time.Time) error {
+	limits, err := s.limitsFor(db, acc)
+	if err != nil {
+		return err
+	}
+	usage, err := usageSince(db, acc.ID, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if err := limit.Check(limits, usage, int64(amount)); err != nil {
+		return err
+	}
+	return checkKYCLimits(db, acc, amount, usage)
+}
+
+func (s *SQLiteStore) GetAllowance(id int) (*limit.Allowance, error) {
+	acc, err := s.GetAccountByID(id)
+	if err != nil {
 		return nil, err
 	}
-	myAccount := &transfer.TransferResponse{
-		Account: transfer.MyBalance{
-			Username:        account.Username,
-			MyAccountNumber: account.BankNumber,
-			Balance:         account.Balance,
-		},
-		Sent: true,
+	limits, err := s.limitsFor(s.db, acc)
+	if err != nil {
+		return nil, err
 	}
-	return myAccount, nil
+	usage, err := usageSince(s.db, id, time.Now().UTC().Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	allowance := limit.Remaining(limits, usage)
+	return &allowance, nil
+}
+
+// SetLimits gives account id limits of its own. When onlyLower is set, which is the case for
+// account holders changing their own limits, none of them may be raised.
+func (s *SQLiteStore) SetLimits(id int, limits limit.Limits, onlyLower bool) error {
+	if err := limit.Validate(limits); err != nil {
+		return err
+	}
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	acc, err := ScanIntoAccount(tx.QueryRow(`SELECT * FROM "account" WHERE id = ?`, id))
+	if err != nil {
+		return fmt.Errorf("Account Does Not Exist")
+	}
+	current, err := s.limitsFor(tx, acc)
+	if err != nil {
+		return err
+	}
+	if onlyLower && !limit.IsLower(current, limits) {
+		return fmt.Errorf("limits can only be lowered, ask an admin to raise them")
+	}
+	_, err = tx.Exec(`INSERT INTO "account_limit" ("account_id", "per_transaction", "daily", "daily_count") values (?, ?, ?, ?)
+		ON CONFLICT ("account_id") DO UPDATE SET "per_transaction" = excluded."per_transaction", "daily" = excluded."daily", "daily_count" = excluded."daily_count"`,
+		id, limits.PerTransaction, limits.Daily, limits.DailyCount)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(tx, "account.limits", audit.TargetAccount, id, current, limits); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func updateBalance(s *SQLiteStore, balance int64, id int) error {
-	_, err := s.db.Exec(`UPDATE account SET "balance" = ? WHERE "id" = ?`, balance, id)
+// updateBalance sets the balance of account id, and alerts the account holder when the
+// change takes them into or out of their overdraft.
+func updateBalance(db DBTX, balance int64, id int) error {
+	var previous int64
+	if err := db.QueryRow(`SELECT "balance" FROM "account" WHERE "id" = ?`, id).Scan(&previous); err != nil {
+		return fmt.Errorf("Transaction Error Please Try Again Later")
+	}
+	_, err := db.Exec(`UPDATE account SET "balance" = ? WHERE "id" = ?`, balance, id)
 	if err != nil {
 		return fmt.Errorf("Transaction Error Please Try Again Later")
 	}
+	switch {
+	case previous >= 0 && balance < 0:
+		return insertNotification(db, notification.CreateNotification(id, notification.KindOverdraftEntered, fmt.Sprintf("Your account is overdrawn, the balance is now %v", balance)))
+	case previous < 0 && balance >= 0:
+		return insertNotification(db, notification.CreateNotification(id, notification.KindOverdraftLeft, fmt.Sprintf("Your account is no longer overdrawn, the balance is now %v", balance)))
+	}
 	return nil
 }
 
 func ScanIntoAccount(row QueryResult) (*account.Account, error) {
 	account := new(account.Account)
+	// accounts from before statuses were recorded have no status_changed_at
+	var statusChanged sql.NullTime
 	err := row.Scan(
 		&account.ID,
 		&account.FirstName,
@@ -375,7 +5327,13 @@ func ScanIntoAccount(row QueryResult) (*account.Account, error) {
 		&account.Password,
 		&account.BankNumber,
 		&account.Balance,
-		&account.CreatedAt)
+		&account.CreatedAt,
+		&account.Type,
+		&account.Role,
+		&account.OverdraftLimit,
+		&account.Status,
+		&statusChanged)
+	account.StatusChanged = statusChanged.Time
 	// PrintAccount(account)
 	return account, err
 }
@@ -389,20 +5347,316 @@ func PrintAccount(account *account.Account) {
 		"password:=", account.Password,
 		"bank number:=", account.BankNumber,
 		"balance:=", account.Balance,
-		"created at:=", account.CreatedAt)
+		"created at:=", account.CreatedAt,
+		"account type:=", account.Type,
+		"role:=", account.Role,
+		"overdraft limit:=", account.OverdraftLimit,
+		"status:=", account.Status)
 }
 
 func ScanIntoTransfer(row QueryResult) (*transfer.Transfer, error) {
-	transfer := new(transfer.Transfer)
+	trans := new(transfer.Transfer)
 	err := row.Scan(
-		&transfer.ID,
-		&transfer.From,
-		&transfer.To,
-		&transfer.Amount,
-		&transfer.Action,
-		&transfer.PreviousBalance,
-		&transfer.CurrentBalance,
-		&transfer.CompletedAt)
-	// PrintAccount(account)
-	return transfer, err
+		&trans.ID,
+		&trans.From,
+		&trans.To,
+		&trans.Amount,
+		&trans.Action,
+		&trans.PreviousBalance,
+		&trans.CurrentBalance,
+		&trans.CompletedAt,
+		&trans.Channel,
+		&trans.Reference,
+		&trans.FromBankNumber,
+		&trans.ToBankNumber,
+		&trans.Memo,
+		&trans.Category,
+		&trans.ReversalOf,
+		&trans.ReversedBy,
+		&trans.CounterpartID)
+	trans.Status = transfer.StatusOf(trans)
+	return trans, err
+}
+
+func ScanIntoBeneficiary(row QueryResult) (*beneficiary.Beneficiary, error) {
+	b := new(beneficiary.Beneficiary)
+	err := row.Scan(
+		&b.ID,
+		&b.AccountID,
+		&b.Nickname,
+		&b.BankNumber,
+		&b.Name,
+		&b.CreatedAt,
+		&b.ActiveFrom)
+	b.Active = beneficiary.IsActive(b.ActiveFrom)
+	return b, err
+}
+
+func ScanIntoStandingOrder(row QueryResult) (*standingorder.StandingOrder, error) {
+	order := new(standingorder.StandingOrder)
+	err := row.Scan(
+		&order.ID,
+		&order.AccountID,
+		&order.ToBankNumber,
+		&order.PayeeID,
+		&order.Amount,
+		&order.Memo,
+		&order.Reference,
+		&order.Category,
+		&order.Frequency,
+		&order.DayOfMonth,
+		&order.StartDate,
+		&order.EndDate,
+		&order.MaxOccurrences,
+		&order.Executed,
+		&order.NextRunAt,
+		&order.AttemptAt,
+		&order.Attempts,
+		&order.LastError,
+		&order.Status,
+		&order.CreatedAt)
+	return order, err
+}
+
+func ScanIntoHold(row QueryResult) (*hold.Hold, error) {
+	h := new(hold.Hold)
+	err := row.Scan(
+		&h.ID,
+		&h.AccountID,
+		&h.Amount,
+		&h.Captured,
+		&h.Merchant,
+		&h.Reference,
+		&h.Status,
+		&h.CreatedAt,
+		&h.ExpiresAt,
+		&h.SettledAt,
+		&h.TransferID)
+	return h, err
+}
+
+func ScanIntoPendingTransfer(row QueryResult) (*joint.PendingTransfer, error) {
+	p := new(joint.PendingTransfer)
+	err := row.Scan(
+		&p.ID,
+		&p.AccountID,
+		&p.RequestedBy,
+		&p.ToBankNumber,
+		&p.Amount,
+		&p.Memo,
+		&p.Reference,
+		&p.Category,
+		&p.Required,
+		&p.Status,
+		&p.CreatedAt,
+		&p.DecidedAt)
+	return p, err
+}
+
+func ScanIntoReviewItem(row QueryResult) (*review.Item, error) {
+	item := new(review.Item)
+	err := row.Scan(
+		&item.ID,
+		&item.AccountID,
+		&item.MakerID,
+		&item.ToBankNumber,
+		&item.Amount,
+		&item.Memo,
+		&item.Reference,
+		&item.Category,
+		&item.Status,
+		&item.ReviewerID,
+		&item.Comment,
+		&item.CreatedAt,
+		&item.ExpiresAt,
+		&item.DecidedAt,
+		&item.Reason)
+	return item, err
+}
+
+func ScanIntoSanctionsHit(row QueryResult) (*sanctions.Hit, error) {
+	hit := new(sanctions.Hit)
+	err := row.Scan(
+		&hit.ID,
+		&hit.AccountID,
+		&hit.ReviewID,
+		&hit.ScreenedName,
+		&hit.EntryUID,
+		&hit.EntryName,
+		&hit.Score,
+		&hit.Status,
+		&hit.ReviewerID,
+		&hit.Comment,
+		&hit.CreatedAt,
+		&hit.DecidedAt)
+	return hit, err
+}
+
+func ScanIntoAMLAlert(row QueryResult) (*aml.Alert, error) {
+	alert := new(aml.Alert)
+	err := row.Scan(
+		&alert.ID,
+		&alert.AccountID,
+		&alert.Rule,
+		&alert.Amount,
+		&alert.Details,
+		&alert.Status,
+		&alert.Disposition,
+		&alert.AssigneeID,
+		&alert.Comment,
+		&alert.CreatedAt,
+		&alert.UpdatedAt)
+	return alert, err
+}
+
+func ScanIntoKYCProfile(row QueryResult) (*kyc.Profile, error) {
+	profile := new(kyc.Profile)
+	err := row.Scan(
+		&profile.AccountID,
+		&profile.DateOfBirth,
+		&profile.AddressLine1,
+		&profile.AddressLine2,
+		&profile.City,
+		&profile.Postcode,
+		&profile.Country,
+		&profile.NationalID,
+		&profile.Status,
+		&profile.ReviewerID,
+		&profile.Comment,
+		&profile.UpdatedAt,
+		&profile.SubmittedAt,
+		&profile.DecidedAt)
+	return profile, err
+}
+
+func ScanIntoKYCDocument(row QueryResult) (*kyc.Document, error) {
+	document := new(kyc.Document)
+	err := row.Scan(
+		&document.ID,
+		&document.AccountID,
+		&document.Type,
+		&document.Filename,
+		&document.ContentType,
+		&document.Size,
+		&document.SHA256,
+		&document.UploadedAt)
+	return document, err
+}
+
+func ScanIntoAuditEntry(row QueryResult) (*audit.Entry, error) {
+	entry := new(audit.Entry)
+	var diff string
+	err := row.Scan(
+		&entry.ID,
+		&entry.ActorID,
+		&entry.Action,
+		&entry.TargetType,
+		&entry.TargetID,
+		&diff,
+		&entry.RequestID,
+		&entry.IP,
+		&entry.CreatedAt,
+		&entry.PrevHash,
+		&entry.Hash)
+	entry.Diff = json.RawMessage(diff)
+	return entry, err
+}
+
+func ScanIntoEvent(row QueryResult) (*event.Event, error) {
+	e := new(event.Event)
+	var data string
+	err := row.Scan(
+		&e.ID,
+		&e.AccountID,
+		&e.Version,
+		&e.Type,
+		&data,
+		&e.OccurredAt)
+	e.Data = json.RawMessage(data)
+	return e, err
+}
+
+func ScanIntoWebhookSubscription(row QueryResult) (*webhook.Subscription, error) {
+	sub := new(webhook.Subscription)
+	var eventTypes string
+	err := row.Scan(
+		&sub.ID,
+		&sub.ClientID,
+		&sub.URL,
+		&eventTypes,
+		&sub.Secret,
+		&sub.Active,
+		&sub.CreatedAt)
+	sub.EventTypes = []string{}
+	if eventTypes != "" {
+		sub.EventTypes = strings.Split(eventTypes, ",")
+	}
+	return sub, err
+}
+
+func ScanIntoOutboxMessage(row QueryResult) (*webhook.Message, error) {
+	m := new(webhook.Message)
+	var data string
+	var dispatched bool
+	err := row.Scan(
+		&m.ID,
+		&m.EventID,
+		&m.Type,
+		&m.AccountID,
+		&m.OccurredAt,
+		&data,
+		&dispatched)
+	m.Data = json.RawMessage(data)
+	return m, err
+}
+
+func ScanIntoWebhookDelivery(row QueryResult) (*webhook.Delivery, error) {
+	d := new(webhook.Delivery)
+	err := row.Scan(
+		&d.ID,
+		&d.MessageID,
+		&d.SubscriptionID,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.CreatedAt)
+	return d, err
+}
+
+func ScanIntoStoredStatement(row QueryResult) (*statement.Stored, error) {
+	st := new(statement.Stored)
+	err := row.Scan(
+		&st.ID,
+		&st.AccountID,
+		&st.From,
+		&st.To,
+		&st.OpeningBalance,
+		&st.ClosingBalance,
+		&st.TotalIn,
+		&st.TotalOut,
+		&st.Transactions,
+		&st.GeneratedAt,
+		&st.CSV,
+		&st.PDF)
+	return st, err
+}
+
+// ScanIntoStatementSummary scans the statementSummaryColumns of a stored statement.
+func ScanIntoStatementSummary(row QueryResult) (*statement.Stored, error) {
+	st := new(statement.Stored)
+	err := row.Scan(
+		&st.ID,
+		&st.AccountID,
+		&st.From,
+		&st.To,
+		&st.OpeningBalance,
+		&st.ClosingBalance,
+		&st.TotalIn,
+		&st.TotalOut,
+		&st.Transactions,
+		&st.GeneratedAt)
+	return st, err
 }
@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/event"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	// HeartbeatInterval is how often an idle stream is sent a comment, so proxies keep it open
+	HeartbeatInterval = 15 * time.Second
+	// RetryDelay is how long clients are told to wait before reconnecting
+	RetryDelay = 3 * time.Second
+	// bufferSize is how many events a subscriber can fall behind by before it is dropped
+	bufferSize = 256
+)
+
+// Bus fans events out to every subscriber in this process watching the account they belong
+// to. Publishing never waits on a subscriber: one that has fallen too far behind is dropped,
+// and its channel closed, so it can reconnect and catch up from the event store.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]bool
+}
+
+// Subscription is one listener on the bus, watching a set of accounts.
+type Subscription struct {
+	bus      *Bus
+	accounts map[int]bool
+	events   chan *event.Event
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[*Subscription]bool{}}
+}
+
+// Subscribe starts listening for events on the given accounts. The subscription must be
+// closed once it is finished with.
+func (b *Bus) Subscribe(accountIDs []int) *Subscription {
+	sub := &Subscription{
+		bus:      b,
+		accounts: map[int]bool{},
+		events:   make(chan *event.Event, bufferSize),
+	}
+	for _, id := range accountIDs {
+		sub.accounts[id] = true
+	}
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish sends e to every subscriber watching its account.
+func (b *Bus) Publish(e *event.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if !sub.accounts[e.AccountID] {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Events is where the subscription's events arrive, oldest first. It is closed when the
+// subscription is closed or dropped for falling behind.
+func (s *Subscription) Events() <-chan *event.Event {
+	return s.events
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.bus.subscribers[s] {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Write sends e as a server-sent event. Its id is the event's id in the store, which a
+// client sends back as Last-Event-ID to carry on from where it left off.
+func Write(w io.Writer, e *event.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// WriteRetry tells the client how long to wait before reconnecting if the stream drops.
+func WriteRetry(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", RetryDelay.Milliseconds())
+	return err
+}
+
+// WriteHeartbeat sends a comment, which clients ignore, to keep an idle stream open.
+func WriteHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ": heartbeat\n\n")
+	return err
+}
+
+// PollInterval is how often the event store is checked for events committed by other
+// processes, read from the streamPollInterval environment variable, e.g. "5s". Events
+// committed by the server itself are published without waiting for it.
+func PollInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("streamPollInterval"))
+	if err != nil || interval <= 0 {
+		return defaultPollInterval
+	}
+	return interval
+}
@@ -1,32 +1,123 @@
 package transfer
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
 
+const (
+	maxMemoLength     = 140
+	maxCategoryLength = 32
+)
+
+// MaxReferenceLength is the longest reference payment schemes and statement formats carry.
+const MaxReferenceLength = 35
+
+// referencePattern is the structured payment reference format: up to 35 letters, digits,
+// spaces and the separators / - . : shared by most payment schemes.
+var referencePattern = regexp.MustCompile(`^[A-Za-z0-9/\-.: ]{1,35}$`)
+
+// Channels through which money can enter or leave an account.
+const (
+	ChannelCash         = "cash"
+	ChannelCard         = "card"
+	ChannelExternalBank = "external-bank"
+)
+
+// TransferRequest addresses the recipient by either their bank number, their username
+// or one of the sender's saved payees, never by the internal account id.
 type TransferRequest struct {
-	ToAccount int `json:"to-account"`
-	Amount    int `json:"amount"`
+	ToBankNumber int64  `json:"to-bank-number"`
+	ToUsername   string `json:"to-username"`
+	PayeeID      int    `json:"payee-id"`
+	Amount       int    `json:"amount"`
+	Memo         string `json:"memo"`
+	Reference    string `json:"reference"`
+	Category     string `json:"category"`
+}
+
+// HistoryFilter narrows down the transfers returned for an account. Empty fields match everything.
+// From and To bound when the transfers completed, From included and To not.
+type HistoryFilter struct {
+	Search   string    `json:"search"`
+	Category string    `json:"category"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+}
+
+type UpdateCategoryRequest struct {
+	Category string `json:"category"`
+}
+
+// Payee is what a sender is shown about a recipient before sending them money.
+type Payee struct {
+	BankNumber int64  `json:"bank-number"`
+	Name       string `json:"name"`
 }
 
+type DepositRequest struct {
+	Amount    int    `json:"amount"`
+	Source    string `json:"source"`
+	Reference string `json:"reference"`
+}
+
+type WithdrawalRequest struct {
+	Amount    int    `json:"amount"`
+	Channel   string `json:"channel"`
+	Reference string `json:"reference"`
+}
+
+// TransferResponse has Sent false and a PendingID when the transfer is waiting for the
+// other owners of a joint account to approve it, or a ReviewID when it is waiting for a reviewer.
 type TransferResponse struct {
-	Account MyBalance `json:"my-account"`
-	Sent    bool      `json:"sent"`
+	Account   MyBalance `json:"my-account"`
+	Recipient Payee     `json:"recipient"`
+	Fee       int       `json:"fee"`
+	Sent      bool      `json:"sent"`
+	PendingID int       `json:"pending-id"`
+	ReviewID  int       `json:"review-id"`
 }
 
+// MyBalance shows the ledger balance, which only changes when money actually moves, and the
+// available balance, which is what can be spent once holds and any overdraft are counted.
+// Balance is the ledger balance, kept for existing clients.
 type MyBalance struct {
-	Username        string `json:"username"`
-	MyAccountNumber int64  `json:"my-account"`
-	Balance         int64  `json:"balance"`
+	Username         string `json:"username"`
+	MyAccountNumber  int64  `json:"my-account"`
+	Balance          int64  `json:"balance"`
+	LedgerBalance    int64  `json:"ledger-balance"`
+	AvailableBalance int64  `json:"available-balance"`
 }
 
+// Whether a transfer still stands or has been undone by a reversal.
+const (
+	StatusCompleted = "completed"
+	StatusReversed  = "reversed"
+)
+
+// ReversalOf links a compensating entry to the entry it undoes, and ReversedBy links the
+// other way, so both sides of a reversal can be found from either one. CounterpartID links
+// the two sides of a transfer between accounts to each other.
 type Transfer struct {
 	ID              int       `json:"id"`
-	From            int       `json:"from"`
-	To              int       `json:"to"`
+	From            int       `json:"-"`
+	To              int       `json:"-"`
+	FromBankNumber  int64     `json:"from-account"`
+	ToBankNumber    int64     `json:"to-account"`
 	Amount          int       `json:"amount"`
 	Action          string    `json:"action"`
 	PreviousBalance int64     `json:"previous-balance"`
 	CurrentBalance  int64     `json:"current-balance"`
 	CompletedAt     time.Time `json:"completed-at"`
+	Channel         string    `json:"channel"`
+	Reference       string    `json:"reference"`
+	Memo            string    `json:"memo"`
+	Category        string    `json:"category"`
+	ReversalOf      int       `json:"reversal-of"`
+	ReversedBy      int       `json:"reversed-by"`
+	Status          string    `json:"status"`
+	CounterpartID   int       `json:"-"`
 }
 
 type MyTransfers struct {
@@ -45,3 +136,71 @@ func CreateTransfer(from, to, amount int, previous, new int64, action string, ti
 		CompletedAt:     time,
 	}
 }
+
+// StatusOf reports whether trans has been reversed.
+func StatusOf(trans *Transfer) string {
+	if trans.ReversedBy != 0 {
+		return StatusReversed
+	}
+	return StatusCompleted
+}
+
+func CreateDeposit(id int, request *DepositRequest, previous, new int64, time time.Time) *Transfer {
+	deposit := CreateTransfer(id, id, request.Amount, previous, new, "deposit", time)
+	deposit.Channel = request.Source
+	deposit.Reference = request.Reference
+	return deposit
+}
+
+func CreateWithdrawal(id int, request *WithdrawalRequest, previous, new int64, time time.Time) *Transfer {
+	withdrawal := CreateTransfer(id, id, request.Amount, previous, new, "withdrawal", time)
+	withdrawal.Channel = request.Channel
+	withdrawal.Reference = request.Reference
+	return withdrawal
+}
+
+// ValidateDeposit checks a deposit is for a positive amount, from a known source and carries a reference.
+func ValidateDeposit(request *DepositRequest) error {
+	return validateMovement(request.Amount, request.Source, request.Reference)
+}
+
+// ValidateWithdrawal checks a withdrawal is for a positive amount, to a known channel and carries a reference.
+func ValidateWithdrawal(request *WithdrawalRequest) error {
+	return validateMovement(request.Amount, request.Channel, request.Reference)
+}
+
+func validateMovement(amount int, channel, reference string) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	switch channel {
+	case ChannelCash, ChannelCard, ChannelExternalBank:
+	default:
+		return fmt.Errorf("invalid channel %q : must be one of %s, %s or %s", channel, ChannelCash, ChannelCard, ChannelExternalBank)
+	}
+	if reference == "" {
+		return fmt.Errorf("reference is required")
+	}
+	if len([]rune(reference)) > MaxReferenceLength {
+		return fmt.Errorf("reference cannot be longer than %d characters", MaxReferenceLength)
+	}
+	return nil
+}
+
+// ValidateDetails checks the optional memo, payment reference and category of a transfer.
+func ValidateDetails(memo, reference, category string) error {
+	if len([]rune(memo)) > maxMemoLength {
+		return fmt.Errorf("memo cannot be longer than %d characters", maxMemoLength)
+	}
+	if reference != "" && !referencePattern.MatchString(reference) {
+		return fmt.Errorf("reference must be at most 35 letters, digits, spaces or / - . :")
+	}
+	return ValidateCategory(category)
+}
+
+func ValidateCategory(category string) error {
+	if len([]rune(category)) > maxCategoryLength {
+		return fmt.Errorf("category cannot be longer than %d characters", maxCategoryLength)
+	}
+	return nil
+}
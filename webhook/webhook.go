@@ -0,0 +1,307 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jasonasante/bankAPI.git/event"
+)
+
+// Statuses a delivery moves through. A pending delivery is retried with exponential backoff
+// until it is delivered or has used up MaxAttempts, when it is dead until redelivered.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256, keyed with the
+// subscription's secret, of the timestamp header, a ".", and the body.
+const (
+	HeaderMessageID = "X-Webhook-Message-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultRetryBase   = 30 * time.Second
+	maxRetryDelay      = 6 * time.Hour
+	sendTimeout        = 10 * time.Second
+	// errorLength is how much of a failed response is kept to explain the failure
+	errorLength = 512
+)
+
+// EventTypes are the events published to webhooks: money moving in or out of an account,
+// an account changing status and a transfer being recategorised. Events that carry personal
+// details, such as an account being opened, are never published.
+var EventTypes = []string{
+	event.TypeDeposited,
+	event.TypeWithdrawn,
+	event.TypeTransferSent,
+	event.TypeTransferReceived,
+	event.TypeFeeCharged,
+	event.TypeFeeReceived,
+	event.TypeInterestPaid,
+	event.TypeInterestCharged,
+	event.TypeReversalDebited,
+	event.TypeReversalCredited,
+	event.TypeStatusChanged,
+	event.TypeTransferCategorised,
+}
+
+type SubscriptionRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event-types"`
+}
+
+// Subscription is a client's webhook URL and the events it wants. No event types means
+// every event. Secret is only shown when the subscription is created.
+type Subscription struct {
+	ID         int       `json:"id"`
+	ClientID   int       `json:"client-id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event-types"`
+	Secret     string    `json:"secret,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created-at"`
+}
+
+// Message is an event waiting in the outbox to be sent. It is written in the same
+// transaction as the change it describes, and its JSON is the body of every delivery.
+type Message struct {
+	ID         int             `json:"id"`
+	EventID    int             `json:"event-id"`
+	Type       string          `json:"type"`
+	AccountID  int             `json:"account-id"`
+	OccurredAt time.Time       `json:"occurred-at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Delivery is one message on its way to one subscription.
+type Delivery struct {
+	ID             int       `json:"id"`
+	MessageID      int       `json:"message-id"`
+	SubscriptionID int       `json:"subscription-id"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next-attempt-at"`
+	LastStatusCode int       `json:"last-status-code"`
+	LastError      string    `json:"last-error"`
+	DeliveredAt    time.Time `json:"delivered-at"`
+	CreatedAt      time.Time `json:"created-at"`
+}
+
+// Attempt is everything needed to make a delivery.
+type Attempt struct {
+	Delivery     *Delivery
+	Subscription *Subscription
+	Message      *Message
+}
+
+func CreateSubscription(clientID int, request *SubscriptionRequest) (*Subscription, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	eventTypes := request.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return &Subscription{
+		ClientID:   clientID,
+		URL:        request.URL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+func ValidateSubscription(request *SubscriptionRequest) error {
+	u, err := url.Parse(request.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https url")
+	}
+	for _, eventType := range request.EventTypes {
+		if !Publishes(eventType) {
+			return fmt.Errorf("invalid event type %q : must be one of %s", eventType, strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+func ValidateStatus(status string) error {
+	switch status {
+	case StatusPending, StatusDelivered, StatusDead:
+		return nil
+	}
+	return fmt.Errorf("invalid status %q : must be %s, %s or %s", status, StatusPending, StatusDelivered, StatusDead)
+}
+
+// Publishes reports whether events of eventType are sent to webhooks.
+func Publishes(eventType string) bool {
+	for _, published := range EventTypes {
+		if eventType == published {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports whether the subscription should be sent events of eventType.
+func (s *Subscription) Wants(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, wanted := range s.EventTypes {
+		if eventType == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+func CreateMessage(e *event.Event) *Message {
+	return &Message{
+		EventID:    e.ID,
+		Type:       e.Type,
+		AccountID:  e.AccountID,
+		OccurredAt: e.OccurredAt,
+		Data:       e.Data,
+	}
+}
+
+func CreateDelivery(messageID, subscriptionID int, now time.Time) *Delivery {
+	return &Delivery{
+		MessageID:      messageID,
+		SubscriptionID: subscriptionID,
+		Status:         StatusPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+}
+
+// Record updates the delivery with the outcome of an attempt at now. A failed attempt is
+// retried after Backoff, unless it was the last one allowed.
+func (d *Delivery) Record(statusCode int, failure error, now time.Time) {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	if failure == nil {
+		d.Status, d.LastError, d.DeliveredAt = StatusDelivered, "", now
+		return
+	}
+	d.LastError = failure.Error()
+	if d.Attempts >= MaxAttempts() {
+		d.Status = StatusDead
+		return
+	}
+	d.NextAttemptAt = now.Add(Backoff(d.Attempts))
+}
+
+// Redeliver puts the delivery back in the queue to be sent straight away, with a full set of
+// attempts.
+func (d *Delivery) Redeliver(now time.Time) {
+	d.Status, d.Attempts, d.NextAttemptAt = StatusPending, 0, now
+}
+
+// Backoff is how long to wait after the given number of failed attempts: RetryBase doubled
+// for each attempt after the first, up to a ceiling of six hours.
+func Backoff(attempts int) time.Duration {
+	delay := RetryBase()
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Sign is the signature of body sent at timestamp, for the signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, timestamp+".")
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret makes a random secret for signing a subscription's payloads.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Send posts the attempt's message to its subscription, signed with the subscription's
+// secret. Anything other than a 2xx response is a failure.
+func Send(client *http.Client, a *Attempt, now time.Time) (int, error) {
+	body, err := json.Marshal(a.Message)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, a.Subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageID, strconv.Itoa(a.Message.ID))
+	req.Header.Set(HeaderEvent, a.Message.Type)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(a.Subscription.Secret, timestamp, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := ioutil.ReadAll(io.LimitReader(resp.Body, errorLength))
+		return resp.StatusCode, fmt.Errorf("%s : %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return resp.StatusCode, nil
+}
+
+// NewClient is the HTTP client deliveries are sent with.
+func NewClient() *http.Client {
+	return &http.Client{Timeout: sendTimeout}
+}
+
+// MaxAttempts is how many times a delivery is tried before it is dead, read from the
+// webhookMaxAttempts environment variable.
+func MaxAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("webhookMaxAttempts"))
+	if err != nil || attempts < 1 {
+		return defaultMaxAttempts
+	}
+	return attempts
+}
+
+// RetryBase is how long to wait after the first failed attempt, read from the
+// webhookRetryBase environment variable, e.g. "30s" or "1m".
+func RetryBase() time.Duration {
+	base, err := time.ParseDuration(os.Getenv("webhookRetryBase"))
+	if err != nil || base <= 0 {
+		return defaultRetryBase
+	}
+	return base
+}